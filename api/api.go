@@ -3,8 +3,10 @@ package api
 import (
 	_ "app/api/docs"
 	"app/api/handler"
+	"app/api/models"
 	"app/config"
 	"app/pkg/logger"
+	"app/pkg/mailer"
 	"app/storage"
 	"github.com/gin-gonic/gin"
 
@@ -12,46 +14,114 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func NewApi(r *gin.Engine, cfg *config.Config, storage storage.StorageInterface, logger logger.LoggerI) {
-	NewHandler := handler.NewHandler(cfg, storage, logger)
+func NewApi(r *gin.Engine, cfg *config.Config, storage storage.StorageInterface, mailer mailer.Mailer, logger logger.LoggerI) {
+	NewHandler := handler.NewHandler(cfg, storage, mailer, logger)
 
 	r.Use(customCORSMiddleware())
 	r.Use(MaxAllowed(1000))
 
-	r.POST("/books", NewHandler.Validate, NewHandler.CreateBook)
+	staff := NewHandler.Authorize(models.RoleStaff, models.RoleAdmin)
+	admin := NewHandler.Authorize(models.RoleAdmin)
+
+	r.POST("/books", NewHandler.Validate, staff, NewHandler.CreateBook)
+	r.GET("/books/search", NewHandler.Validate, NewHandler.SearchBooks)
 	r.GET("/books/:id", NewHandler.Validate, NewHandler.GetByIdBook)
 	r.GET("/books", NewHandler.Validate, NewHandler.GetListBooks)
-	r.PUT("/books", NewHandler.Validate, NewHandler.UpdateBook)
-	r.DELETE("/books/:id", NewHandler.Validate, NewHandler.DeleteBook)
-
-	r.POST("/users", NewHandler.Validate, NewHandler.CreateUser)
+	r.PUT("/books", NewHandler.Validate, staff, NewHandler.UpdateBook)
+	r.PATCH("/books/:id", NewHandler.Validate, staff, NewHandler.PatchBook)
+	r.DELETE("/books/:id", NewHandler.Validate, staff, NewHandler.DeleteBook)
+	r.POST("/books/:id/categories", NewHandler.Validate, staff, NewHandler.AddBookCategories)
+	r.DELETE("/books/:id/categories/:category_id", NewHandler.Validate, staff, NewHandler.RemoveBookCategory)
+	r.POST("/books/:id/tags", NewHandler.Validate, staff, NewHandler.AddBookTags)
+	r.DELETE("/books/:id/tags/:tag", NewHandler.Validate, staff, NewHandler.RemoveBookTag)
+	r.POST("/books/:id/inventory", NewHandler.Validate, staff, NewHandler.AdjustInventory)
+	r.GET("/books/:id/inventory", NewHandler.Validate, staff, NewHandler.GetInventoryMovements)
+
+	r.POST("/users", NewHandler.Validate, admin, NewHandler.CreateUser)
+	// anyone signed in may look up a user, but only admins and the user itself get the private profile
 	r.GET("/users/:id", NewHandler.Validate, NewHandler.GetByIdUser)
-	r.GET("/users", NewHandler.Validate, NewHandler.GetListUsers)
-	r.PUT("/users", NewHandler.Validate, NewHandler.UpdateUser)
-	r.DELETE("/users/:id", NewHandler.Validate, NewHandler.DeleteUser)
+	r.GET("/users", NewHandler.Validate, admin, NewHandler.GetListUsers)
+	r.PUT("/users", NewHandler.Validate, admin, NewHandler.UpdateUser)
+	r.PATCH("/users/:id", NewHandler.Validate, admin, NewHandler.PatchUser)
+	r.DELETE("/users/:id", NewHandler.Validate, admin, NewHandler.DeleteUser)
 
+	// customers are limited to their own orders inside the handlers
 	r.POST("/orders", NewHandler.Validate, NewHandler.CreateOrder)
+	r.POST("/orders/checkout", NewHandler.Validate, NewHandler.CheckoutOrder)
 	r.GET("/orders/:id", NewHandler.Validate, NewHandler.GetByIdOrder)
 	r.GET("/orders", NewHandler.Validate, NewHandler.GetListOrders)
 	r.PUT("/orders", NewHandler.Validate, NewHandler.UpdateOrder)
+	r.PATCH("/orders/:id", NewHandler.Validate, NewHandler.PatchOrder)
 	r.DELETE("/orders/:id", NewHandler.Validate, NewHandler.DeleteOrder)
+	r.GET("/orders/:id/history", NewHandler.Validate, NewHandler.GetOrderHistory)
+	r.POST("/orders/:id/pay", NewHandler.Validate, staff, NewHandler.TransitionOrder(models.OrderStatusPaid))
+	r.POST("/orders/:id/ship", NewHandler.Validate, staff, NewHandler.TransitionOrder(models.OrderStatusShipped))
+	r.POST("/orders/:id/deliver", NewHandler.Validate, staff, NewHandler.TransitionOrder(models.OrderStatusDelivered))
+	r.POST("/orders/:id/cancel", NewHandler.Validate, NewHandler.TransitionOrder(models.OrderStatusCancelled))
+	r.POST("/orders/:id/refund", NewHandler.Validate, staff, NewHandler.TransitionOrder(models.OrderStatusRefunded))
 
 	r.POST("/order_items", NewHandler.Validate, NewHandler.CreateOrderItem)
 	r.GET("/order_items/:id", NewHandler.Validate, NewHandler.GetByIdOrderItem)
 	r.GET("/order_items", NewHandler.Validate, NewHandler.GetListOrderItems)
 	r.PUT("/order_items", NewHandler.Validate, NewHandler.UpdateOrderItem)
+	r.PATCH("/order_items/:id", NewHandler.Validate, NewHandler.PatchOrderItem)
 	r.DELETE("/order_items/:id", NewHandler.Validate, NewHandler.DeleteOrderItem)
 
-	r.POST("/categories", NewHandler.Validate, NewHandler.CreateCategory)
+	r.GET("/cart", NewHandler.Validate, NewHandler.GetCart)
+	r.DELETE("/cart", NewHandler.Validate, NewHandler.ClearCart)
+	r.POST("/cart/items", NewHandler.Validate, NewHandler.AddCartItem)
+	r.PUT("/cart/items/:book_id", NewHandler.Validate, NewHandler.UpdateCartItem)
+	r.DELETE("/cart/items/:book_id", NewHandler.Validate, NewHandler.RemoveCartItem)
+	r.POST("/cart/checkout", NewHandler.Validate, NewHandler.CheckoutCart)
+
+	// visitor carts are reached by their id alone and merged into the user's cart at login
+	r.POST("/carts", NewHandler.CreateCart)
+	r.GET("/carts/:id", NewHandler.GetCart)
+	r.DELETE("/carts/:id", NewHandler.ClearCart)
+	r.POST("/carts/:id/items", NewHandler.AddCartItem)
+	r.PUT("/carts/:id/items/:book_id", NewHandler.UpdateCartItem)
+	r.DELETE("/carts/:id/items/:book_id", NewHandler.RemoveCartItem)
+
+	r.POST("/categories", NewHandler.Validate, staff, NewHandler.CreateCategory)
+	r.GET("/categories/tree", NewHandler.Validate, NewHandler.GetCategoryTree)
 	r.GET("/categories/:id", NewHandler.Validate, NewHandler.GetByIdCategory)
+	r.GET("/categories/:id/ancestors", NewHandler.Validate, NewHandler.GetCategoryAncestors)
+	r.GET("/categories/:id/books", NewHandler.Validate, NewHandler.GetCategoryBooks)
 	r.GET("/categories", NewHandler.Validate, NewHandler.GetListCategories)
-	r.PUT("/categories", NewHandler.Validate, NewHandler.UpdateCategory)
-	r.DELETE("/categories/:id", NewHandler.Validate, NewHandler.DeleteCategory)
+	r.PUT("/categories", NewHandler.Validate, staff, NewHandler.UpdateCategory)
+	r.PATCH("/categories/:id", NewHandler.Validate, staff, NewHandler.PatchCategory)
+	r.DELETE("/categories/:id", NewHandler.Validate, staff, NewHandler.DeleteCategory)
+
+	r.POST("/authors", NewHandler.Validate, staff, NewHandler.CreateAuthor)
+	r.GET("/authors/:id", NewHandler.Validate, NewHandler.GetByIdAuthor)
+	r.GET("/authors/:id/books", NewHandler.Validate, NewHandler.GetAuthorBooks)
+	r.GET("/authors", NewHandler.Validate, NewHandler.GetListAuthors)
+	r.PUT("/authors", NewHandler.Validate, staff, NewHandler.UpdateAuthor)
+	r.PATCH("/authors/:id", NewHandler.Validate, staff, NewHandler.PatchAuthor)
+	r.DELETE("/authors/:id", NewHandler.Validate, staff, NewHandler.DeleteAuthor)
+
+	r.POST("/publishers", NewHandler.Validate, staff, NewHandler.CreatePublisher)
+	r.GET("/publishers/:id", NewHandler.Validate, NewHandler.GetByIdPublisher)
+	r.GET("/publishers/:id/books", NewHandler.Validate, NewHandler.GetPublisherBooks)
+	r.GET("/publishers", NewHandler.Validate, NewHandler.GetListPublishers)
+	r.PUT("/publishers", NewHandler.Validate, staff, NewHandler.UpdatePublisher)
+	r.PATCH("/publishers/:id", NewHandler.Validate, staff, NewHandler.PatchPublisher)
+	r.DELETE("/publishers/:id", NewHandler.Validate, staff, NewHandler.DeletePublisher)
 
 	r.POST("/upload", NewHandler.HandleUpload)
 
 	r.POST("/login", NewHandler.Login)
+	r.GET("/login_attempts", NewHandler.Validate, admin, NewHandler.GetListLoginAttempts)
 	r.POST("/register", NewHandler.Register)
+	r.POST("/refresh", NewHandler.Refresh)
+	r.POST("/logout", NewHandler.Validate, NewHandler.Logout)
+	r.GET("/me", NewHandler.Validate, NewHandler.GetMe)
+	r.PATCH("/me", NewHandler.Validate, NewHandler.UpdateMe)
+	r.POST("/me/password", NewHandler.Validate, NewHandler.ChangePassword)
+	r.POST("/password/forgot", NewHandler.ForgotPassword)
+	r.POST("/password/reset", NewHandler.ResetPassword)
+	r.GET("/verify-email", NewHandler.VerifyEmail)
+	r.POST("/verify-email/resend", NewHandler.Validate, NewHandler.ResendVerification)
 
 	url := ginSwagger.URL("swagger/doc.json") // The url pointing to API definition
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
@@ -90,7 +160,8 @@ func customCORSMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE, HEAD")
-		c.Header("Access-Control-Allow-Headers", "Platform-Id, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Platform-Id, Client-Type, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-Match, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Expose-Headers", "ETag, Retry-After")
 		c.Header("Access-Control-Max-Age", "3600")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"app/api/models"
+	"app/config"
+	"app/pkg/helper"
+	"app/pkg/logger"
+	"app/pkg/mailer"
+	"app/storage"
+	"app/storage/memory"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newTestApi(t *testing.T) (*gin.Engine, storage.StorageInterface) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		SecretKey:          "test",
+		AccessTokenTTL:     time.Minute,
+		RefreshTokenTTL:    time.Hour,
+		LoginMaxFailures:   10,
+		LoginIPMaxFailures: 50,
+	}
+	strg, err := memory.NewConnectionMemory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logger.NewLogger("test", logger.LevelError)
+	r := gin.New()
+	NewApi(r, cfg, strg, mailer.NewLogMailer(log), log)
+	return r, strg
+}
+
+// login creates a user with role and returns its id and access token
+func login(t *testing.T, r *gin.Engine, strg storage.StorageInterface, role string) (string, string) {
+	t.Helper()
+	hash, err := helper.HashPassword("Str0ng!pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := strg.Users().Create(context.Background(), &models.CreateUser{Username: role + "1", Email: role + "@example.com", Password: hash, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, data := serve(r, "POST", "/login", "", models.UserLoginRequest{Username: role + "1", Password: "Str0ng!pass"})
+	if status != http.StatusCreated {
+		t.Fatalf("login as %s = %d", role, status)
+	}
+	var tokens models.TokenResponse
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		t.Fatal(err)
+	}
+	return userId, tokens.AccessToken
+}
+
+func serve(r *gin.Engine, method, path, token string, body interface{}) (int, json.RawMessage) {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Data
+}
+
+func TestRouteRoles(t *testing.T) {
+	r, strg := newTestApi(t)
+	tokens := map[string]string{}
+	for _, role := range []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin} {
+		_, tokens[role] = login(t, r, strg, role)
+	}
+
+	id := uuid.New().String()
+	routes := []struct {
+		method, path string
+		roles        []string
+	}{
+		{"POST", "/books", []string{models.RoleStaff, models.RoleAdmin}},
+		{"PATCH", "/books/" + id, []string{models.RoleStaff, models.RoleAdmin}},
+		{"DELETE", "/books/" + id, []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/books/" + id + "/inventory", []string{models.RoleStaff, models.RoleAdmin}},
+		{"GET", "/books/" + id + "/inventory", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/categories", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/authors", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/publishers", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/orders/" + id + "/pay", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/orders/" + id + "/ship", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/orders/" + id + "/refund", []string{models.RoleStaff, models.RoleAdmin}},
+		{"POST", "/users", []string{models.RoleAdmin}},
+		{"GET", "/users", []string{models.RoleAdmin}},
+		{"GET", "/users/" + id, []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}},
+		{"DELETE", "/users/" + id, []string{models.RoleAdmin}},
+		{"GET", "/login_attempts", []string{models.RoleAdmin}},
+		{"GET", "/books", []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}},
+		{"GET", "/orders", []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}},
+		{"GET", "/me", []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}},
+	}
+	for _, route := range routes {
+		if status, _ := serve(r, route.method, route.path, "", nil); status != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token = %d, want 401", route.method, route.path, status)
+		}
+		for role, token := range tokens {
+			allowed := false
+			for _, want := range route.roles {
+				allowed = allowed || want == role
+			}
+			status, _ := serve(r, route.method, route.path, token, nil)
+			switch {
+			case allowed && (status == http.StatusForbidden || status == http.StatusUnauthorized):
+				t.Errorf("%s %s as %s = %d, want it allowed", route.method, route.path, role, status)
+			case !allowed && status != http.StatusForbidden:
+				t.Errorf("%s %s as %s = %d, want 403", route.method, route.path, role, status)
+			}
+		}
+	}
+}
+
+func TestValidateRejectsDeletedUser(t *testing.T) {
+	r, strg := newTestApi(t)
+	userId, token := login(t, r, strg, models.RoleCustomer)
+	if status, _ := serve(r, "GET", "/me", token, nil); status != http.StatusOK {
+		t.Fatalf("GET /me = %d, want 200", status)
+	}
+
+	err := strg.Users().Delete(context.Background(), &models.UserPrimaryKey{Id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := serve(r, "GET", "/me", token, nil); status != http.StatusUnauthorized {
+		t.Errorf("GET /me for a deleted user = %d, want 401", status)
+	}
+}
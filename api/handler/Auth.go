@@ -4,28 +4,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/dgrijalva/jwt-go"
-	"github.com/spf13/cast"
+	"github.com/google/uuid"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"app/api/models"
 	"app/pkg/helper"
+	"app/pkg/logger"
 )
 
 // Login godoc
 // @ID login
 // @Router /login [POST]
 // @Summary Login
-// @Description Login
+// @Description Login. A visitor cart_id, if given, is merged into the user's cart
 // @Tags Auth
 // @Accept json
 // @Procedure json
 // @Param login body models.UserLoginRequest true "UserLoginRequest"
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Success 201 {object} Response{data=models.TokenResponse} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 401 {object} Response{data=string} "Invalid username or password"
+// @Response 429 {object} Response{data=string} "Too many failed attempts"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) Login(c *gin.Context) {
 	var login models.UserLoginRequest
@@ -41,10 +45,30 @@ func (h *Handler) Login(c *gin.Context) {
 			http.StatusBadRequest, "Username is not valid")
 		return
 	}
-	resp, err := h.strg.Users().GetById(context.Background(), &models.UserPrimaryKey{Username: login.Username})
+	attemptId, err := h.reserveLoginAttempt(c, login.Username)
+	if err != nil {
+		h.handlerResponse(c, "Error while recording login attempt", http.StatusInternalServerError, err.Error())
+		return
+	}
+	wait, err := h.loginDelay(c.Request.Context(), attemptId, login.Username, c.ClientIP())
+	if err != nil {
+		h.handlerResponse(c, "Error while checking login attempts", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if wait > 0 {
+		h.resolveLoginAttempt(c, attemptId, "", models.LoginThrottled)
+		setRetryAfter(c, wait)
+		h.handlerResponse(c, "Too many failed login attempts, try again later", http.StatusTooManyRequests, nil)
+		return
+	}
+
+	// unknown users and wrong passwords get the same response so usernames cannot be probed
+	resp, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Username: login.Username})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
-			h.handlerResponse(c, "User does not exist", http.StatusBadRequest, err.Error())
+			helper.CompareDummyPassword(login.Password)
+			h.resolveLoginAttempt(c, attemptId, "", models.LoginUnknownUser)
+			h.handlerResponse(c, "Invalid username or password", http.StatusUnauthorized, nil)
 			return
 		}
 		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
@@ -52,15 +76,268 @@ func (h *Handler) Login(c *gin.Context) {
 	}
 
 	if !helper.CheckPasswordHash(login.Password, resp.Password) {
-		h.handlerResponse(c, "Password mismatch", http.StatusBadRequest, "Incorrect password")
+		h.resolveLoginAttempt(c, attemptId, resp.Id, models.LoginBadPassword)
+		h.handlerResponse(c, "Invalid username or password", http.StatusUnauthorized, nil)
+		return
+	}
+	h.resolveLoginAttempt(c, attemptId, resp.Id, models.LoginSuccess)
+	if login.CartId != "" {
+		h.mergeCart(c, login.CartId, resp.Id)
+	}
+
+	tokens, _, err := h.issueTokens(c.Request.Context(), h.tokenInfo(c, resp), "")
+	if err != nil {
+		h.handlerResponse(c, "Error while generating token", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.setTokenCookies(c, tokens)
+	h.handlerResponse(c, "token", http.StatusCreated, tokens)
+}
+
+// Refresh godoc
+// @ID refresh
+// @Router /refresh [POST]
+// @Summary Refresh
+// @Description Exchange a refresh token for a new access and refresh token pair
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param refresh body models.RefreshTokenRequest false "RefreshTokenRequest"
+// @Success 201 {object} Response{data=models.TokenResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 401 {object} Response{data=string} "Unauthorized"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken, _ = c.Cookie("RefreshToken")
+	}
+	if req.RefreshToken == "" {
+		h.handlerResponse(c, "Refresh token not present", http.StatusUnauthorized, "Refresh token not present")
+		return
+	}
+
+	ctx := c.Request.Context()
+	current, err := h.strg.RefreshToken().GetById(ctx, &models.RefreshTokenPrimaryKey{TokenHash: helper.HashToken(req.RefreshToken)})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Invalid refresh token", http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		h.handlerResponse(c, "Error while getting refresh token", http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	token, err := helper.GenerateJWT(map[string]interface{}{
-		"user_id": resp.Id,
-	}, time.Hour*72, h.cfg.SecretKey)
-	c.SetCookie("Authorization", token, 3600*72, "/", "localhost", false, true)
-	h.handlerResponse(c, "token", http.StatusCreated, token)
+	if current.RevokedAt != nil {
+		// a rotated token was presented again, so the whole family is compromised
+		h.revokeTokenFamily(c, current.FamilyId, "Refresh token reuse detected")
+		return
+	}
+	if time.Now().UTC().After(current.ExpiresAt) {
+		h.handlerResponse(c, "Refresh token expired", http.StatusUnauthorized, "Refresh token expired")
+		return
+	}
+
+	user, err := h.strg.Users().GetById(ctx, &models.UserPrimaryKey{Id: current.UserId})
+	if err != nil {
+		h.revokeTokenFamily(c, current.FamilyId, "User does not exist")
+		return
+	}
+
+	tokens, refreshId, err := h.issueTokens(ctx, h.tokenInfo(c, user), current.FamilyId)
+	if err != nil {
+		h.handlerResponse(c, "Error while generating token", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rotated, err := h.strg.RefreshToken().Revoke(ctx, &models.RevokeRefreshToken{Id: current.Id, ReplacedBy: refreshId})
+	if err != nil {
+		h.handlerResponse(c, "Error while rotating refresh token", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rotated == 0 {
+		// lost a race against another request presenting the same token
+		h.revokeTokenFamily(c, current.FamilyId, "Refresh token reuse detected")
+		return
+	}
+
+	h.setTokenCookies(c, tokens)
+	h.handlerResponse(c, "token", http.StatusCreated, tokens)
+}
+
+// Authorize lets the request through only when the user set by Validate has one of roles
+func (h *Handler) Authorize(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role = c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		h.abortResponse(c, "Forbidden", http.StatusForbidden, "Insufficient permissions")
+	}
+}
+
+// currentUser returns the user loaded by Validate
+func (h *Handler) currentUser(c *gin.Context) *models.User {
+	return c.MustGet("user").(*models.User)
+}
+
+// isStaff reports whether the current user may act on other users' resources
+func (h *Handler) isStaff(c *gin.Context) bool {
+	var role = c.GetString("role")
+	return role == models.RoleStaff || role == models.RoleAdmin
+}
+
+// Logout godoc
+// @ID logout
+// @Router /logout [POST]
+// @Summary Logout
+// @Description Revoke the current access token and its refresh token, or every session of the user when all is set
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param logout body models.LogoutRequest false "LogoutRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken, _ = c.Cookie("RefreshToken")
+	}
+
+	var (
+		ctx    = c.Request.Context()
+		userId = c.GetString("user_id")
+	)
+
+	if req.All {
+		err = h.logoutEverywhere(ctx, userId)
+		if err != nil {
+			h.handlerResponse(c, "Error while revoking tokens", http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		err = h.strg.RevokedToken().Create(ctx, &models.CreateRevokedToken{
+			Jti:       c.GetString("jti"),
+			UserId:    userId,
+			ExpiresAt: c.GetTime("token_exp"),
+		})
+		if err != nil {
+			h.handlerResponse(c, "Error while revoking token", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if req.RefreshToken != "" {
+			current, err := h.strg.RefreshToken().GetById(ctx, &models.RefreshTokenPrimaryKey{TokenHash: helper.HashToken(req.RefreshToken)})
+			if err == nil && current.UserId == userId {
+				_, err = h.strg.RefreshToken().Revoke(ctx, &models.RevokeRefreshToken{FamilyId: current.FamilyId})
+				if err != nil {
+					h.handlerResponse(c, "Error while revoking refresh token", http.StatusInternalServerError, err.Error())
+					return
+				}
+			}
+		}
+	}
+
+	h.setCookie(c, "Authorization", "", -1, "/")
+	h.setCookie(c, "RefreshToken", "", -1, "/refresh")
+	h.handlerResponse(c, "Logged out successfully", http.StatusOK, nil)
+}
+
+// logoutEverywhere revokes every access and refresh token issued to userId so
+// far. The revocation expires with the last access token it covers
+func (h *Handler) logoutEverywhere(ctx context.Context, userId string) error {
+	err := h.strg.RevokedToken().Create(ctx, &models.CreateRevokedToken{UserId: userId, ExpiresAt: time.Now().Add(h.cfg.AccessTokenTTL)})
+	if err != nil {
+		return err
+	}
+	_, err = h.strg.RefreshToken().Revoke(ctx, &models.RevokeRefreshToken{UserId: userId})
+	return err
+}
+
+// tokenInfo collects the claims for a token issued to user, taking the
+// client from the Client-Type and Platform-Id request headers
+func (h *Handler) tokenInfo(c *gin.Context, user *models.User) helper.TokenInfo {
+	return helper.TokenInfo{
+		UserID:     user.Id,
+		ClientType: c.GetHeader("Client-Type"),
+		PlatformID: c.GetHeader("Platform-Id"),
+		Role:       user.Role,
+	}
+}
+
+// issueTokens signs a new access token and persists a new refresh token in
+// familyId, starting a new family when familyId is empty
+func (h *Handler) issueTokens(ctx context.Context, info helper.TokenInfo, familyId string) (*models.TokenResponse, string, error) {
+	var userId = info.UserID
+
+	accessToken, err := helper.GenerateJWT(map[string]interface{}{
+		"user_id":     userId,
+		"client_type": info.ClientType,
+		"platform_id": info.PlatformID,
+		"role":        info.Role,
+	}, h.cfg.AccessTokenTTL, h.cfg.SecretKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if familyId == "" {
+		familyId = uuid.New().String()
+	}
+	refreshId, err := h.strg.RefreshToken().Create(ctx, &models.CreateRefreshToken{
+		UserId:    userId,
+		FamilyId:  familyId,
+		TokenHash: helper.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(h.cfg.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.cfg.AccessTokenTTL.Seconds()),
+	}, refreshId, nil
+}
+
+func (h *Handler) setTokenCookies(c *gin.Context, tokens *models.TokenResponse) {
+	h.setCookie(c, "Authorization", tokens.AccessToken, int(h.cfg.AccessTokenTTL.Seconds()), "/")
+	h.setCookie(c, "RefreshToken", tokens.RefreshToken, int(h.cfg.RefreshTokenTTL.Seconds()), "/refresh")
+}
+
+func (h *Handler) setCookie(c *gin.Context, name string, value string, maxAge int, path string) {
+	c.SetCookie(name, value, maxAge, path, h.cfg.CookieDomain, h.cfg.CookieSecure, true)
+}
+
+func (h *Handler) revokeTokenFamily(c *gin.Context, familyId string, description string) {
+	_, err := h.strg.RefreshToken().Revoke(c.Request.Context(), &models.RevokeRefreshToken{FamilyId: familyId})
+	if err != nil {
+		h.handlerResponse(c, "Error while revoking refresh tokens", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, description, http.StatusUnauthorized, "Refresh token revoked")
 }
 
 // Register godoc
@@ -72,7 +349,7 @@ func (h *Handler) Login(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param register body models.CreateUser true "CreateUserRequest"
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Success 201 {object} Response{data=models.PrivateUser} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) Register(c *gin.Context) {
@@ -90,12 +367,13 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	// TO-DO
-	// implement better password checker !!!
+	createUser.Email = strings.ToLower(strings.TrimSpace(createUser.Email))
+	if !helper.IsValidEmail(createUser.Email) {
+		h.handlerResponse(c, "Email is not valid", http.StatusBadRequest, "Email is not valid")
+		return
+	}
 
-	if len(createUser.Password) < 7 {
-		h.handlerResponse(c, "Password should include more than 7 elements",
-			http.StatusBadRequest, errors.New("Password len should include more than 7 elements"))
+	if !h.checkPassword(c, createUser.Password, createUser.Username) {
 		return
 	}
 
@@ -106,6 +384,16 @@ func (h *Handler) Register(c *gin.Context) {
 	}
 
 	createUser.Password = hashedPassword
+	createUser.Role = models.RoleCustomer
+
+	_, err = h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Email: createUser.Email})
+	if err == nil {
+		h.handlerResponse(c, "Email already in use", http.StatusConflict, nil)
+		return
+	} else if err.Error() != fmt.Errorf("no rows in result set").Error() {
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	resp, err := h.strg.Users().GetById(context.Background(), &models.UserPrimaryKey{Username: createUser.Username})
 	if err != nil {
@@ -124,45 +412,78 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 	resp, err = h.strg.Users().GetById(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	h.handlerResponse(c, "User successfully created", http.StatusCreated, resp)
+	err = h.sendVerification(c.Request.Context(), resp)
+	if err != nil {
+		h.logger.Error("verification mail", logger.String("user_id", resp.Id), logger.Error(err))
+	}
+
+	h.handlerResponse(c, "User successfully created", http.StatusCreated, privateUser(resp))
 }
 
+// Validate authenticates the request from an "Authorization: Bearer <token>"
+// header, falling back to the Authorization cookie set by Login
 func (h *Handler) Validate(c *gin.Context) {
-	tokenString, err := c.Cookie("Authorization")
+	var tokenString string
+
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, err := helper.ExtractToken(header)
+		if err != nil {
+			h.abortResponse(c, "Invalid", http.StatusUnauthorized, err.Error())
+			return
+		}
+		tokenString = token
+	} else {
+		token, err := c.Cookie("Authorization")
+		if err != nil {
+			h.abortResponse(c, "Authorization header not present", http.StatusUnauthorized, err.Error())
+			return
+		}
+		tokenString = token
+	}
+
+	info, err := helper.ParseClaims(tokenString, h.cfg.SecretKey)
 	if err != nil {
-		h.handlerResponse(c, "Authorization header not present", http.StatusUnauthorized, err.Error())
+		h.abortResponse(c, "Invalid", http.StatusUnauthorized, err.Error())
 		return
 	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(h.cfg.SecretKey), nil
+
+	revoked, err := h.strg.RevokedToken().IsRevoked(c.Request.Context(), &models.RevokedTokenPrimaryKey{
+		Jti:      info.Jti,
+		UserId:   info.UserID,
+		IssuedAt: info.IssuedAt,
 	})
 	if err != nil {
-		h.handlerResponse(c, "Invalid", http.StatusUnauthorized, err.Error())
+		h.abortResponse(c, "Error while checking token", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revoked {
+		h.abortResponse(c, "Token revoked", http.StatusUnauthorized, "Token revoked")
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		if float64(time.Now().Unix()) >= claims["exp"].(float64) {
-			h.handlerResponse(c, "Token expired", http.StatusUnauthorized, "Token expired")
-			return
-		}
-		var userId = cast.ToString(claims["user_id"])
-		user, err := h.strg.Users().GetById(c, &models.UserPrimaryKey{Id: userId})
-		if err != nil {
-			h.handlerResponse(c, "User does not exist", http.StatusNotFound, "User does not exist")
+	user, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: info.UserID})
+	if err != nil {
+		// a deleted user's tokens are no longer credentials
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.abortResponse(c, "User does not exist", http.StatusUnauthorized, "User does not exist")
 			return
 		}
-		c.Set("user_id", userId)
-		c.Set("user", user)
-		c.Next()
-	} else {
-		h.handlerResponse(c, "Authorization header not present", http.StatusUnauthorized, err.Error())
+		h.abortResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
 		return
 	}
+
+	c.Set("user_id", info.UserID)
+	c.Set("user", user)
+	// the stored role wins over the claim so demotions apply before the token expires
+	c.Set("role", user.Role)
+	c.Set("client_type", info.ClientType)
+	c.Set("platform_id", info.PlatformID)
+	c.Set("jti", info.Jti)
+	c.Set("token_exp", info.ExpiresAt)
+	c.Next()
 }
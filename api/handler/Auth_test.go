@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestServer(t)
+	hash, err := helper.HashPassword("Str0ng!pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.strg.Users().Create(context.Background(), &models.CreateUser{Username: "reader1", Email: "reader1@example.com", Password: hash, Role: models.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var login models.TokenResponse
+	s.expect(http.StatusCreated, "POST", "/login", models.UserLoginRequest{Username: "reader1", Password: "Str0ng!pass"}, &login)
+	var rotated models.TokenResponse
+	s.expect(http.StatusCreated, "POST", "/refresh", models.RefreshTokenRequest{RefreshToken: login.RefreshToken}, &rotated)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh returned %q, want a new refresh token", rotated.RefreshToken)
+	}
+
+	// the first token was rotated out, so presenting it again means it leaked
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", models.RefreshTokenRequest{RefreshToken: login.RefreshToken}, nil)
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", models.RefreshTokenRequest{RefreshToken: rotated.RefreshToken}, nil)
+}
+
+func TestLogoutEverywhereRevokesTokensOfTheSameSecond(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	userId, err := s.strg.Users().Create(ctx, &models.CreateUser{Username: "reader1", Email: "reader1@example.com", Role: models.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.router.GET("/validate", s.h.Validate, func(c *gin.Context) {
+		c.JSON(http.StatusOK, Response{Status: http.StatusOK})
+	})
+	validate := func(tokens *models.TokenResponse) int {
+		t.Helper()
+		return s.doWith("GET", "/validate", nil, map[string]string{"Authorization": "Bearer " + tokens.AccessToken}).Status
+	}
+
+	// leave room to issue and revoke within one second
+	for time.Now().Nanosecond() > 800*int(time.Millisecond) {
+		time.Sleep(time.Millisecond)
+	}
+	issued := time.Now()
+	before, _, err := s.h.issueTokens(ctx, helper.TokenInfo{UserID: userId, Role: models.RoleCustomer}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	err = s.h.logoutEverywhere(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !time.Now().Truncate(time.Second).Equal(issued.Truncate(time.Second)) {
+		t.Skip("the token and the revocation fell in different seconds")
+	}
+	if status := validate(before); status != http.StatusUnauthorized {
+		t.Errorf("token issued in the same second before logout = %d, want 401", status)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	after, _, err := s.h.issueTokens(ctx, helper.TokenInfo{UserID: userId, Role: models.RoleCustomer}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := validate(after); status != http.StatusOK {
+		t.Errorf("token issued after logout = %d, want 200", status)
+	}
+}
@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"app/api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// CreateAuthor godoc
+// @ID create_author
+// @Router /authors [POST]
+// @Summary Create Author
+// @Description Create Author
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param author body models.CreateAuthor true "CreateAuthorRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) CreateAuthor(c *gin.Context) {
+	var createAuthor *models.CreateAuthor
+	err := c.ShouldBindJSON(&createAuthor)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	if createAuthor.Name == "" {
+		h.handlerResponse(c, "Author name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	AuthorId, err := h.strg.Author().Create(c.Request.Context(), createAuthor)
+	if err != nil {
+		h.handlerResponse(c, "Error while creating Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	Author, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: AuthorId})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Author successfully created", http.StatusCreated, Author)
+}
+
+// UpdateAuthor godoc
+// @ID update_author
+// @Router /authors [PUT]
+// @Summary Update Author
+// @Description Update Author
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param author body models.UpdateAuthor true "UpdateAuthorRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) UpdateAuthor(c *gin.Context) {
+	var author models.UpdateAuthor
+	err := c.ShouldBindJSON(&author)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	author.Version = version
+	if author.Name == "" {
+		h.handlerResponse(c, "Author name is required", http.StatusBadRequest, nil)
+		return
+	}
+	_, err = h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: author.Id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Author does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp, err := h.strg.Author().Update(c.Request.Context(), &author)
+	if err != nil {
+		if h.versionConflict(c, err, "Author") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Author successfully updated", http.StatusCreated, resp)
+}
+
+// PatchAuthor godoc
+// @ID patch_author
+// @Router /authors/{id} [PATCH]
+// @Summary Patch Author
+// @Description Update only the supplied fields of an Author
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param author body models.PatchAuthor true "PatchAuthorRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Author} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchAuthor(c *gin.Context) {
+	var author models.PatchAuthor
+	err := c.ShouldBindJSON(&author)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	author.Version = version
+	author.Id = c.Param("id")
+	if _, err := uuid.Parse(author.Id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if author.Name != nil && *author.Name == "" {
+		h.handlerResponse(c, "Author name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	affected, err := h.strg.Author().Patch(c.Request.Context(), &author)
+	if err != nil {
+		if h.versionConflict(c, err, "Author") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Author does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	resp, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: author.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "Author successfully updated", http.StatusOK, resp)
+}
+
+// GetByIdAuthor godoc
+// @ID get_by_id_author
+// @Router /authors/{id} [GET]
+// @Summary Get By ID Author
+// @Description Get By ID Author
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Author} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetByIdAuthor(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	author, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Author does not exist", http.StatusNotFound, err.Error())
+			return
+		}
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, author.Version)
+	h.handlerResponse(c, "Author successfully retrieved", http.StatusOK, author)
+}
+
+// GetListAuthors godoc
+// @ID get_list_author
+// @Router /authors [GET]
+// @Summary Get List Authors
+// @Description Get List Authors
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[name][like]=tolstoy"
+// @Param sort query string false "comma separated fields, - for descending; e.g. name"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.AuthorGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetListAuthors(c *gin.Context) {
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
+		return
+	}
+	list, ok := h.listQuery(c, authorList)
+	if !ok {
+		return
+	}
+	resp, err := h.strg.Author().GetList(c.Request.Context(), &models.AuthorGetListRequest{
+		Offset:  offset,
+		Limit:   limit,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Authors", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Author successfully retrieved", http.StatusOK, resp)
+}
+
+// GetAuthorBooks godoc
+// @ID get_author_books
+// @Router /authors/{id}/books [GET]
+// @Summary Get Author Books
+// @Description Get the books an author is credited on in any role
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[lang]=en"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at,title"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.BookGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetAuthorBooks(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Author does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.listBooks(c, models.Filter{Field: "author_id", Op: models.FilterEq, Value: id})
+}
+
+// DeleteAuthor godoc
+// @ID delete_author
+// @Router /authors/{id} [DELETE]
+// @Summary Delete Author
+// @Description Delete an Author that is not credited on any book
+// @Tags Author
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) DeleteAuthor(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	_, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Author does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if used, ok := h.hasBooks(c, models.Filter{Field: "author_id", Op: models.FilterEq, Value: id}); !ok {
+		return
+	} else if used {
+		h.handlerResponse(c, "Author is credited on books, remove the credits first", http.StatusConflict, nil)
+		return
+	}
+
+	err = h.strg.Author().Delete(c.Request.Context(), &models.AuthorPrimaryKey{Id: id, Version: version})
+	if err != nil {
+		if h.versionConflict(c, err, "Author") {
+			return
+		}
+		h.handlerResponse(c, "Error while deleting Author", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Author deleted successfully", http.StatusOK, nil)
+}
+
+// hasBooks reports whether any live book matches filter
+func (h *Handler) hasBooks(c *gin.Context, filter models.Filter) (bool, bool) {
+	books, err := h.strg.Books().GetList(c.Request.Context(), &models.BookGetListRequest{
+		Limit:   1,
+		Filters: []models.Filter{filter},
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Books", http.StatusInternalServerError, err.Error())
+		return false, false
+	}
+	return len(books.Books) > 0, true
+}
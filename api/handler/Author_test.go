@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"testing"
+)
+
+// author creates an author named name
+func (s *testServer) author(name string) string {
+	s.t.Helper()
+	var author models.Author
+	s.expect(http.StatusCreated, "POST", "/authors", models.CreateAuthor{Name: name}, &author)
+	return author.Id
+}
+
+func TestBookAuthorCredits(t *testing.T) {
+	s := newTestServer(t)
+	tolstoy, maude := s.author("Leo Tolstoy"), s.author("Louise Maude")
+
+	s.expect(http.StatusBadRequest, "POST", "/books", models.CreateBook{Title: "War and Peace", Price: 1000, Currency: "USD",
+		Authors: []*models.BookAuthor{{AuthorId: tolstoy, Role: "ghostwriter"}}}, nil)
+	s.expect(http.StatusBadRequest, "POST", "/books", models.CreateBook{Title: "War and Peace", Price: 1000, Currency: "USD",
+		Authors: []*models.BookAuthor{{AuthorId: s.userId}}}, nil)
+
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{Title: "War and Peace", Price: 1000, Currency: "USD",
+		Authors: []*models.BookAuthor{{AuthorId: tolstoy}, {AuthorId: maude, Role: models.AuthorRoleTranslator}}}, &book)
+	if book.Author != "Leo Tolstoy" || len(book.Authors) != 2 {
+		t.Fatalf("book author %q credits %+v, want Tolstoy as author and two credits", book.Author, book.Authors)
+	}
+	for _, credit := range book.Authors {
+		if credit.AuthorId == maude && (credit.Name != "Louise Maude" || credit.Role != models.AuthorRoleTranslator) {
+			t.Errorf("translator credit = %+v", credit)
+		}
+	}
+
+	var list models.BookGetListResponse
+	s.expect(http.StatusOK, "GET", "/authors/"+maude+"/books", nil, &list)
+	if len(list.Books) != 1 || list.Books[0].Id != book.Id {
+		t.Fatalf("translator's books = %d, want the book", len(list.Books))
+	}
+
+	name := "Lev Tolstoy"
+	s.expectWith(http.StatusOK, "PATCH", "/authors/"+tolstoy, models.PatchAuthor{Name: &name}, ifMatch(0), nil)
+	s.expect(http.StatusOK, "GET", "/books/"+book.Id, nil, &book)
+	if book.Author != name {
+		t.Errorf("book author after the rename = %q, want %q", book.Author, name)
+	}
+
+	s.expectWith(http.StatusConflict, "DELETE", "/authors/"+tolstoy, nil, ifMatch(0), nil)
+	s.expectWith(http.StatusOK, "DELETE", "/authors/"+s.author("Nobody Yet"), nil, ifMatch(0), nil)
+}
@@ -2,10 +2,14 @@ package handler
 
 import (
 	"app/api/models"
+	"app/pkg/helper"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 // CreateBook godoc
@@ -21,12 +25,30 @@ import (
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) CreateBook(c *gin.Context) {
-	var createBook *models.CreateBook
+	var (
+		createBook *models.CreateBook
+		ok         bool
+	)
 	err := c.ShouldBindJSON(&createBook)
 	if err != nil {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
+	if !h.checkAuthors(c, createBook.Authors) || !h.checkPublisher(c, createBook.PublisherId) {
+		return
+	}
+	if !h.checkCategories(c, createBook.CategoryIds) {
+		return
+	}
+	if createBook.Tags, ok = h.checkTags(c, createBook.Tags); !ok {
+		return
+	}
+	if !h.checkPrice(c, createBook.Price) {
+		return
+	}
+	if createBook.Currency, ok = h.checkCurrency(c, createBook.Currency); !ok {
+		return
+	}
 
 	BookId, err := h.strg.Books().Create(c.Request.Context(), createBook)
 	if err != nil {
@@ -51,8 +73,11 @@ func (h *Handler) CreateBook(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param user body models.Book true "UpdateBookRequest"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) UpdateBook(c *gin.Context) {
 	var book models.UpdateBook
@@ -61,6 +86,26 @@ func (h *Handler) UpdateBook(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	book.Version = version
+	if !h.checkAuthors(c, book.Authors) || !h.checkPublisher(c, book.PublisherId) {
+		return
+	}
+	if !h.checkCategories(c, book.CategoryIds) {
+		return
+	}
+	if book.Tags, ok = h.checkTags(c, book.Tags); !ok {
+		return
+	}
+	if !h.checkPrice(c, book.Price) {
+		return
+	}
+	if book.Currency, ok = h.checkCurrency(c, book.Currency); !ok {
+		return
+	}
 	_, err = h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: book.Id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
@@ -72,12 +117,94 @@ func (h *Handler) UpdateBook(c *gin.Context) {
 	}
 	resp, err := h.strg.Books().Update(c.Request.Context(), &book)
 	if err != nil {
+		if h.versionConflict(c, err, "Book") {
+			return
+		}
 		h.handlerResponse(c, "Error while updating Book", http.StatusInternalServerError, err.Error())
 		return
 	}
 	h.handlerResponse(c, "Book successfully updated", http.StatusCreated, resp)
 }
 
+// PatchBook godoc
+// @ID patch_book
+// @Router /books/{id} [PATCH]
+// @Summary Patch Book
+// @Description Update only the supplied fields of a Book
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param book body models.PatchBook true "PatchBookRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Book} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchBook(c *gin.Context) {
+	var book models.PatchBook
+	err := c.ShouldBindJSON(&book)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	book.Version = version
+	book.Id = c.Param("id")
+	if _, err := uuid.Parse(book.Id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if book.Authors != nil && !h.checkAuthors(c, *book.Authors) {
+		return
+	}
+	if book.PublisherId != nil && !h.checkPublisher(c, *book.PublisherId) {
+		return
+	}
+	if book.CategoryIds != nil && !h.checkCategories(c, *book.CategoryIds) {
+		return
+	}
+	if book.Tags != nil {
+		if *book.Tags, ok = h.checkTags(c, *book.Tags); !ok {
+			return
+		}
+	}
+	if book.Price != nil && !h.checkPrice(c, *book.Price) {
+		return
+	}
+	if book.Currency != nil {
+		if *book.Currency, ok = h.checkCurrency(c, *book.Currency); !ok {
+			return
+		}
+	}
+
+	affected, err := h.strg.Books().Patch(c.Request.Context(), &book)
+	if err != nil {
+		if h.versionConflict(c, err, "Book") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Book", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Book does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	resp, err := h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: book.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "Book successfully updated", http.StatusOK, resp)
+}
+
 // GetByIdBook godoc
 // @ID get_by_id_book
 // @Router /books/{id} [GET]
@@ -106,6 +233,7 @@ func (h *Handler) GetByIdBook(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
 		return
 	}
+	setETag(c, book.Version)
 	h.handlerResponse(c, "Book successfully retrieved", http.StatusOK, book)
 }
 
@@ -117,10 +245,19 @@ func (h *Handler) GetByIdBook(c *gin.Context) {
 // @Tags Book
 // @Accept json
 // @Procedure jsonUser
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[author]=Tolstoy"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at,title"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) GetListBooks(c *gin.Context) {
+	h.listBooks(c)
+}
+
+// listBooks answers a book list request, narrowed further by filters
+func (h *Handler) listBooks(c *gin.Context, filters ...models.Filter) {
 	offset, err := h.getOffsetQuery(c.Query("offset"))
 	if err != nil {
 		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
@@ -131,9 +268,17 @@ func (h *Handler) GetListBooks(c *gin.Context) {
 		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
 		return
 	}
+	list, ok := h.listQuery(c, bookList)
+	if !ok {
+		return
+	}
 	resp, err := h.strg.Books().GetList(c.Request.Context(), &models.BookGetListRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:  offset,
+		Limit:   limit,
+		Filters: append(list.filters, filters...),
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
 	})
 	if err != nil {
 		h.handlerResponse(c, "Error while getting Books", http.StatusInternalServerError, err.Error())
@@ -142,6 +287,328 @@ func (h *Handler) GetListBooks(c *gin.Context) {
 	h.handlerResponse(c, "Book successfully retrieved", http.StatusOK, resp)
 }
 
+// SearchBooks godoc
+// @ID search_books
+// @Router /books/search [GET]
+// @Summary Search Books
+// @Description Full-text search over title, author and publisher, best matches first. Matched words are wrapped in <mark> tags in the highlight
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param q query string true "search query, supports \"phrases\", OR and -exclusions"
+// @Param lang query string false "only books in this language"
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Success 200 {object} Response{data=models.BookSearchResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) SearchBooks(c *gin.Context) {
+	var query = strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		h.handlerResponse(c, "Search query is required", http.StatusBadRequest, "q is required")
+		return
+	}
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
+		return
+	}
+	resp, err := h.strg.Books().Search(c.Request.Context(), &models.BookSearchRequest{
+		Query:  query,
+		Lang:   c.Query("lang"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while searching Books", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Books successfully found", http.StatusOK, resp)
+}
+
+// AddBookCategories godoc
+// @ID add_book_categories
+// @Router /books/{id}/categories [POST]
+// @Summary Add Book Categories
+// @Description Add a book to more categories, keeping the ones it is in
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param categories body models.BookCategories true "BookCategoriesRequest"
+// @Success 200 {object} Response{data=models.Book} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) AddBookCategories(c *gin.Context) {
+	var req models.BookCategories
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	req.BookId = c.Param("id")
+	if _, err := uuid.Parse(req.BookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkCategories(c, req.CategoryIds) {
+		return
+	}
+
+	affected, err := h.strg.Books().AddCategories(c.Request.Context(), &req)
+	if err != nil {
+		h.handlerResponse(c, "Error while adding Book categories", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.bookChanged(c, req.BookId, affected, "Book does not exist")
+}
+
+// RemoveBookCategory godoc
+// @ID remove_book_category
+// @Router /books/{id}/categories/{category_id} [DELETE]
+// @Summary Remove Book Category
+// @Description Remove a book from a category
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param category_id path string true "category_id"
+// @Success 200 {object} Response{data=models.Book} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) RemoveBookCategory(c *gin.Context) {
+	var req = models.BookCategoryPrimaryKey{BookId: c.Param("id"), CategoryId: c.Param("category_id")}
+	for _, id := range []string{req.BookId, req.CategoryId} {
+		if _, err := uuid.Parse(id); err != nil {
+			h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	affected, err := h.strg.Books().RemoveCategory(c.Request.Context(), &req)
+	if err != nil {
+		h.handlerResponse(c, "Error while removing Book category", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.bookChanged(c, req.BookId, affected, "Book is not in this category")
+}
+
+// AddBookTags godoc
+// @ID add_book_tags
+// @Router /books/{id}/tags [POST]
+// @Summary Add Book Tags
+// @Description Tag a book, keeping the tags it has. Tags are stored trimmed and lowercased
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param tags body models.BookTags true "BookTagsRequest"
+// @Success 200 {object} Response{data=models.Book} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) AddBookTags(c *gin.Context) {
+	var (
+		req models.BookTags
+		ok  bool
+	)
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	req.BookId = c.Param("id")
+	if _, err := uuid.Parse(req.BookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Tags, ok = h.checkTags(c, req.Tags); !ok {
+		return
+	}
+
+	affected, err := h.strg.Books().AddTags(c.Request.Context(), &req)
+	if err != nil {
+		h.handlerResponse(c, "Error while adding Book tags", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.bookChanged(c, req.BookId, affected, "Book does not exist")
+}
+
+// RemoveBookTag godoc
+// @ID remove_book_tag
+// @Router /books/{id}/tags/{tag} [DELETE]
+// @Summary Remove Book Tag
+// @Description Remove a tag from a book
+// @Tags Book
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param tag path string true "tag"
+// @Success 200 {object} Response{data=models.Book} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) RemoveBookTag(c *gin.Context) {
+	var req = models.BookTagPrimaryKey{BookId: c.Param("id"), Tag: normalizeTag(c.Param("tag"))}
+	if _, err := uuid.Parse(req.BookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	affected, err := h.strg.Books().RemoveTag(c.Request.Context(), &req)
+	if err != nil {
+		h.handlerResponse(c, "Error while removing Book tag", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.bookChanged(c, req.BookId, affected, "Book does not have this tag")
+}
+
+// bookChanged answers with the book after a change to its categories or
+// tags, or with 404 and notFound when the change matched nothing
+func (h *Handler) bookChanged(c *gin.Context, id string, affected int64, notFound string) {
+	if affected == 0 {
+		h.handlerResponse(c, notFound, http.StatusNotFound, nil)
+		return
+	}
+
+	book, err := h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, book.Version)
+	h.handlerResponse(c, "Book successfully updated", http.StatusOK, book)
+}
+
+// checkAuthors answers 400 unless every credit names a live author and a
+// known role; an empty role credits the author as such
+func (h *Handler) checkAuthors(c *gin.Context, authors []*models.BookAuthor) bool {
+	for _, author := range authors {
+		if author == nil {
+			h.handlerResponse(c, "Author is not valid", http.StatusBadRequest, nil)
+			return false
+		}
+		if author.Role == "" {
+			author.Role = models.AuthorRoleAuthor
+		}
+		if !contains(authorRoles, author.Role) {
+			h.handlerResponse(c, "Author role is not valid", http.StatusBadRequest, fmt.Sprintf("role must be one of %s", strings.Join(authorRoles, ", ")))
+			return false
+		}
+		if _, err := uuid.Parse(author.AuthorId); err != nil {
+			h.handlerResponse(c, "Author id is not valid", http.StatusBadRequest, err.Error())
+			return false
+		}
+		_, err := h.strg.Author().GetById(c.Request.Context(), &models.AuthorPrimaryKey{Id: author.AuthorId})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "Author does not exist", http.StatusBadRequest, author.AuthorId)
+				return false
+			}
+			h.handlerResponse(c, "Error while getting Author", http.StatusInternalServerError, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+var authorRoles = []string{models.AuthorRoleAuthor, models.AuthorRoleTranslator, models.AuthorRoleEditor}
+
+// checkPublisher answers 400 when publisherId is set but names no live publisher
+func (h *Handler) checkPublisher(c *gin.Context, publisherId string) bool {
+	if publisherId == "" {
+		return true
+	}
+	if _, err := uuid.Parse(publisherId); err != nil {
+		h.handlerResponse(c, "Publisher id is not valid", http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	_, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: publisherId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Publisher does not exist", http.StatusBadRequest, publisherId)
+			return false
+		}
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// checkCategories answers 400 unless every id names a live category
+func (h *Handler) checkCategories(c *gin.Context, ids []string) bool {
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			h.handlerResponse(c, "Category id is not valid", http.StatusBadRequest, err.Error())
+			return false
+		}
+		_, err := h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: id})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "Category does not exist", http.StatusBadRequest, id)
+				return false
+			}
+			h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// checkTags normalizes tags and answers 400 when one is empty, too long or
+// has a comma, which separates values in filter[tag][in]
+func (h *Handler) checkTags(c *gin.Context, tags []string) ([]string, bool) {
+	var normalized = make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			h.handlerResponse(c, "Tag is not valid", http.StatusBadRequest, fmt.Sprintf("tags must be 1 to %d characters without commas", maxTagLength))
+			return nil, false
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, true
+}
+
+const maxTagLength = 50
+
+// checkPrice answers 400 unless price is a non-negative amount of minor units
+func (h *Handler) checkPrice(c *gin.Context, price int64) bool {
+	if !helper.IsValidPrice(strconv.FormatInt(price, 10)) {
+		h.handlerResponse(c, "Price is not valid", http.StatusBadRequest, "price must be a non-negative integer of minor units")
+		return false
+	}
+	return true
+}
+
+// checkCurrency upper-cases currency and answers 400 unless it is a
+// three-letter code; an empty currency becomes the configured default
+func (h *Handler) checkCurrency(c *gin.Context, currency string) (string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return h.cfg.DefaultCurrency, true
+	}
+	if !currencyCode.MatchString(currency) {
+		h.handlerResponse(c, "Currency is not valid", http.StatusBadRequest, "currency must be a three-letter ISO 4217 code")
+		return "", false
+	}
+	return currency, true
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
 // DeleteBook godoc
 // @ID delete_book
 // @Router /books/{id} [DELETE]
@@ -151,8 +618,11 @@ func (h *Handler) GetListBooks(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) DeleteBook(c *gin.Context) {
 	var id = c.Param("id")
@@ -161,6 +631,11 @@ func (h *Handler) DeleteBook(c *gin.Context) {
 		return
 	}
 
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
 	_, err := h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
@@ -171,8 +646,11 @@ func (h *Handler) DeleteBook(c *gin.Context) {
 		return
 	}
 
-	err = h.strg.Books().Delete(c.Request.Context(), &models.BookPrimaryKey{Id: id})
+	err = h.strg.Books().Delete(c.Request.Context(), &models.BookPrimaryKey{Id: id, Version: version})
 	if err != nil {
+		if h.versionConflict(c, err, "Book") {
+			return
+		}
 		h.handlerResponse(c, "Error while deleting Book", http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPatchBookKeepsOmittedFields(t *testing.T) {
+	s := newTestServer(t)
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{Title: "Dune", NumPages: 412, Lang: "en", Price: 1000, Currency: "USD", Tags: []string{"classic"}}, &book)
+
+	price := int64(1500)
+	s.expectWith(http.StatusOK, "PATCH", "/books/"+book.Id, models.PatchBook{Price: &price}, ifMatch(book.Version), nil)
+
+	var got models.Book
+	s.expect(http.StatusOK, "GET", "/books/"+book.Id, nil, &got)
+	if got.Price != 1500 {
+		t.Errorf("price = %d, want 1500", got.Price)
+	}
+	if got.Title != "Dune" || got.NumPages != 412 || got.Lang != "en" || got.Currency != "USD" || len(got.Tags) != 1 {
+		t.Errorf("book after patching the price = %+v, want the other fields unchanged", got)
+	}
+}
+
+func TestBookWritesRequireIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{Title: "Dune", Price: 1000, Currency: "USD"}, &book)
+	title := "Dune Messiah"
+
+	s.expect(http.StatusPreconditionRequired, "PUT", "/books", models.UpdateBook{Id: book.Id, Title: title, Price: 1000, Currency: "USD"}, nil)
+	s.expect(http.StatusPreconditionRequired, "PATCH", "/books/"+book.Id, models.PatchBook{Title: &title}, nil)
+	s.expect(http.StatusPreconditionRequired, "DELETE", "/books/"+book.Id, nil, nil)
+
+	var patched models.Book
+	s.expectWith(http.StatusOK, "PATCH", "/books/"+book.Id, models.PatchBook{Title: &title}, ifMatch(book.Version), &patched)
+	s.expectWith(http.StatusPreconditionFailed, "PATCH", "/books/"+book.Id, models.PatchBook{Title: &title}, ifMatch(book.Version), nil)
+	s.expectWith(http.StatusPreconditionFailed, "DELETE", "/books/"+book.Id, nil, ifMatch(book.Version), nil)
+	s.expectWith(http.StatusPreconditionFailed, "DELETE", "/books/"+book.Id, nil, map[string]string{"If-Match": "W/\"1\""}, nil)
+
+	var got models.Book
+	s.expect(http.StatusOK, "GET", "/books/"+book.Id, nil, &got)
+	if got.Title != title || got.Version != patched.Version {
+		t.Fatalf("book = %+v, want the first patch only", got)
+	}
+	s.expectWith(http.StatusOK, "DELETE", "/books/"+book.Id, nil, ifMatch(got.Version), nil)
+}
+
+func TestBookCategoriesAndTags(t *testing.T) {
+	s := newTestServer(t)
+	programming, bestsellers, classics := s.category("Programming", ""), s.category("Bestsellers", ""), s.category("Classics", "")
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{
+		Title:       "SICP",
+		Price:       1000,
+		Currency:    "USD",
+		CategoryIds: []string{programming, bestsellers},
+		Tags:        []string{"Lisp", " lisp ", "textbook"},
+	}, &book)
+	check := func(book models.Book, categories []string, tags []string) {
+		t.Helper()
+		var names []string
+		for _, category := range book.Categories {
+			names = append(names, category.Name)
+		}
+		if !reflect.DeepEqual(names, categories) || !reflect.DeepEqual(book.Tags, tags) {
+			t.Fatalf("book categories %v tags %v, want %v and %v", names, book.Tags, categories, tags)
+		}
+	}
+	check(book, []string{"Bestsellers", "Programming"}, []string{"lisp", "textbook"})
+
+	s.expect(http.StatusOK, "POST", "/books/"+book.Id+"/categories", models.BookCategories{CategoryIds: []string{classics, programming}}, &book)
+	check(book, []string{"Bestsellers", "Classics", "Programming"}, []string{"lisp", "textbook"})
+	s.expect(http.StatusOK, "DELETE", "/books/"+book.Id+"/categories/"+bestsellers, nil, &book)
+	s.expect(http.StatusOK, "POST", "/books/"+book.Id+"/tags", models.BookTags{Tags: []string{"Classic", "LISP"}}, &book)
+	s.expect(http.StatusOK, "DELETE", "/books/"+book.Id+"/tags/Textbook", nil, &book)
+	check(book, []string{"Classics", "Programming"}, []string{"classic", "lisp"})
+
+	var list models.BookGetListResponse
+	for _, query := range []string{"/categories/" + programming + "/books", "/categories/" + classics + "/books", "/books?filter[tag]=classic"} {
+		s.expect(http.StatusOK, "GET", query, nil, &list)
+		if len(list.Books) != 1 || list.Books[0].Id != book.Id {
+			t.Errorf("GET %s found %d books, want the book", query, len(list.Books))
+		}
+	}
+	s.expect(http.StatusOK, "GET", "/categories/"+bestsellers+"/books", nil, &list)
+	if len(list.Books) != 0 {
+		t.Errorf("removed category still lists %d books", len(list.Books))
+	}
+}
@@ -0,0 +1,468 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/logger"
+	"app/storage"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// CreateCart godoc
+// @ID create_cart
+// @Router /carts [POST]
+// @Summary Create Cart
+// @Description Start a cart without logging in. Keep its cart_id to reach it and pass it to login to merge it into the user's cart
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Success 201 {object} Response{data=models.Cart} "Success Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) CreateCart(c *gin.Context) {
+	cartId, err := h.strg.Cart().Create(c.Request.Context(), &models.CreateCart{})
+	if err != nil {
+		h.handlerResponse(c, "Error while creating Cart", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.cartResponse(c, cartId, "Cart successfully created", http.StatusCreated)
+}
+
+// GetCart godoc
+// @ID get_cart
+// @Router /cart [GET]
+// @Router /carts/{id} [GET]
+// @Summary Get Cart
+// @Description Get the current user's cart, or a visitor cart by id, with the live price and stock of each book
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param id path string false "visitor cart id"
+// @Success 200 {object} Response{data=models.Cart} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetCart(c *gin.Context) {
+	cartId, ok := h.resolveCart(c, false)
+	if !ok {
+		return
+	}
+	h.cartResponse(c, cartId, "Cart successfully retrieved", http.StatusOK)
+}
+
+// AddCartItem godoc
+// @ID add_cart_item
+// @Router /cart/items [POST]
+// @Router /carts/{id}/items [POST]
+// @Summary Add Cart Item
+// @Description Add copies of a book to a cart; quantity defaults to 1 and adds to any already there
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param id path string false "visitor cart id"
+// @Param item body models.CartItemRequest true "CartItemRequest"
+// @Param If-Match header string false "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Cart} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) AddCartItem(c *gin.Context) {
+	var item models.CartItemRequest
+	err := c.ShouldBindJSON(&item)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkQuantity(c, &item.Quantity) {
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	cartId, ok := h.resolveCart(c, true)
+	if !ok {
+		return
+	}
+	item.CartId, item.Version = cartId, version
+	if !h.checkCartBook(c, cartId, item.BookId) {
+		return
+	}
+
+	_, err = h.strg.Cart().AddItem(c.Request.Context(), &item)
+	if err != nil {
+		if h.versionConflict(c, err, "Cart") {
+			return
+		}
+		h.handlerResponse(c, "Error while adding Cart item", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.cartResponse(c, cartId, "Cart successfully updated", http.StatusOK)
+}
+
+// UpdateCartItem godoc
+// @ID update_cart_item
+// @Router /cart/items/{book_id} [PUT]
+// @Router /carts/{id}/items/{book_id} [PUT]
+// @Summary Update Cart Item
+// @Description Set the quantity of a book in a cart
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param id path string false "visitor cart id"
+// @Param book_id path string true "book_id"
+// @Param item body models.CartItemRequest true "CartItemRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Cart} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) UpdateCartItem(c *gin.Context) {
+	var item models.CartItemRequest
+	err := c.ShouldBindJSON(&item)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	item.BookId = c.Param("book_id")
+	if _, err := uuid.Parse(item.BookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if item.Quantity < 1 {
+		h.handlerResponse(c, "Quantity is not valid", http.StatusBadRequest, "quantity must be at least 1")
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	cartId, ok := h.resolveCart(c, false)
+	if !ok {
+		return
+	}
+	item.CartId, item.Version = cartId, version
+
+	var affected int64
+	if cartId != "" {
+		affected, err = h.strg.Cart().SetItem(c.Request.Context(), &item)
+		if err != nil {
+			if h.versionConflict(c, err, "Cart") {
+				return
+			}
+			h.handlerResponse(c, "Error while updating Cart item", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Book is not in the Cart", http.StatusNotFound, nil)
+		return
+	}
+	h.cartResponse(c, cartId, "Cart successfully updated", http.StatusOK)
+}
+
+// RemoveCartItem godoc
+// @ID remove_cart_item
+// @Router /cart/items/{book_id} [DELETE]
+// @Router /carts/{id}/items/{book_id} [DELETE]
+// @Summary Remove Cart Item
+// @Description Take a book out of a cart
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param id path string false "visitor cart id"
+// @Param book_id path string true "book_id"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Cart} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) RemoveCartItem(c *gin.Context) {
+	var bookId = c.Param("book_id")
+	if _, err := uuid.Parse(bookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	cartId, ok := h.resolveCart(c, false)
+	if !ok {
+		return
+	}
+
+	var affected int64
+	if cartId != "" {
+		var err error
+		affected, err = h.strg.Cart().RemoveItem(c.Request.Context(), &models.CartItemPrimaryKey{CartId: cartId, BookId: bookId, Version: version})
+		if err != nil {
+			if h.versionConflict(c, err, "Cart") {
+				return
+			}
+			h.handlerResponse(c, "Error while removing Cart item", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Book is not in the Cart", http.StatusNotFound, nil)
+		return
+	}
+	h.cartResponse(c, cartId, "Cart successfully updated", http.StatusOK)
+}
+
+// ClearCart godoc
+// @ID clear_cart
+// @Router /cart [DELETE]
+// @Router /carts/{id} [DELETE]
+// @Summary Clear Cart
+// @Description Remove every book from a cart
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param id path string false "visitor cart id"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Cart} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) ClearCart(c *gin.Context) {
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	cartId, ok := h.resolveCart(c, false)
+	if !ok {
+		return
+	}
+	if cartId != "" {
+		_, err := h.strg.Cart().Clear(c.Request.Context(), &models.CartPrimaryKey{CartId: cartId, Version: version})
+		if err != nil {
+			if h.versionConflict(c, err, "Cart") {
+				return
+			}
+			h.handlerResponse(c, "Error while clearing Cart", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	h.cartResponse(c, cartId, "Cart successfully cleared", http.StatusOK)
+}
+
+// CheckoutCart godoc
+// @ID checkout_cart
+// @Router /cart/checkout [POST]
+// @Summary Checkout Cart
+// @Description Place an order for everything in the current user's cart at the books' current prices and empty the cart
+// @Tags Cart
+// @Accept json
+// @Procedure json
+// @Param If-Match header string false "ETag from a previous GET"
+// @Success 201 {object} Response{data=models.Order} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 403 {object} Response{data=string} "Forbidden"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) CheckoutCart(c *gin.Context) {
+	if !h.canOrder(c) {
+		h.handlerResponse(c, "Email is not verified", http.StatusForbidden, "Verify your email before ordering")
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	cartId, ok := h.resolveCart(c, false)
+	if !ok {
+		return
+	}
+	var checkout = models.Checkout{UserId: c.GetString("user_id"), TaxRate: h.cfg.TaxRate}
+	if cartId != "" {
+		cart, err := h.strg.Cart().GetById(c.Request.Context(), &models.CartPrimaryKey{CartId: cartId})
+		if err != nil {
+			h.handlerResponse(c, "Error while getting Cart", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if version > 0 && version != cart.Version {
+			h.versionConflict(c, storage.ErrVersionConflict, "Cart")
+			return
+		}
+		for _, item := range cart.Items {
+			checkout.Items = append(checkout.Items, &models.CheckoutItem{BookId: item.BookId, Quantity: item.Quantity})
+		}
+		checkout.CartId, checkout.CartVersion = cartId, cart.Version
+	}
+	if len(checkout.Items) == 0 {
+		h.handlerResponse(c, "Cart is empty", http.StatusBadRequest, nil)
+		return
+	}
+
+	// the cart is emptied with the order placed, unless it has changed since
+	// it was read
+	OrderId, err := h.strg.Order().Checkout(c.Request.Context(), &checkout)
+	if err != nil {
+		if h.versionConflict(c, err, "Cart") {
+			return
+		}
+		h.checkoutError(c, err)
+		return
+	}
+	h.placedOrder(c, OrderId)
+}
+
+// resolveCart returns the id of the visitor cart in the path, answering 404
+// for a missing or user-owned one, or of the current user's cart. A user
+// without a cart gets an empty id unless create is set
+func (h *Handler) resolveCart(c *gin.Context, create bool) (string, bool) {
+	if id := c.Param("id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+			return "", false
+		}
+		cart, err := h.strg.Cart().GetById(c.Request.Context(), &models.CartPrimaryKey{CartId: id})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "Cart does not exist", http.StatusNotFound, nil)
+				return "", false
+			}
+			h.handlerResponse(c, "Error while getting Cart", http.StatusInternalServerError, err.Error())
+			return "", false
+		}
+		if cart.UserId != "" {
+			h.handlerResponse(c, "Cart does not exist", http.StatusNotFound, nil)
+			return "", false
+		}
+		return id, true
+	}
+
+	var userId = c.GetString("user_id")
+	if create {
+		id, err := h.strg.Cart().Create(c.Request.Context(), &models.CreateCart{UserId: userId})
+		if err != nil {
+			h.handlerResponse(c, "Error while creating Cart", http.StatusInternalServerError, err.Error())
+			return "", false
+		}
+		return id, true
+	}
+	cart, err := h.strg.Cart().GetById(c.Request.Context(), &models.CartPrimaryKey{UserId: userId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			return "", true
+		}
+		h.handlerResponse(c, "Error while getting Cart", http.StatusInternalServerError, err.Error())
+		return "", false
+	}
+	return cart.CartId, true
+}
+
+// checkCartBook answers 400 unless bookId names a live book priced in the
+// currency of the other books in the cart
+func (h *Handler) checkCartBook(c *gin.Context, cartId, bookId string) bool {
+	if _, err := uuid.Parse(bookId); err != nil {
+		h.handlerResponse(c, "Book id is not valid", http.StatusBadRequest, err.Error())
+		return false
+	}
+	book, err := h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: bookId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Book does not exist", http.StatusBadRequest, bookId)
+			return false
+		}
+		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	cart, err := h.cart(c, cartId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Cart", http.StatusInternalServerError, err.Error())
+		return false
+	}
+	for _, item := range cart.Items {
+		if item.BookId != bookId && item.Book != nil && item.Book.Currency != book.Currency {
+			h.handlerResponse(c, "Books are priced in different currencies, order them separately", http.StatusBadRequest, bookId)
+			return false
+		}
+	}
+	return true
+}
+
+// cart returns the cart with each item's live book, price and stock, and an
+// empty cart for an empty id. A book deleted since it was added stays in the
+// cart without a book and out of stock
+func (h *Handler) cart(c *gin.Context, cartId string) (*models.Cart, error) {
+	if cartId == "" {
+		return &models.Cart{UserId: c.GetString("user_id"), Items: []*models.CartItem{}, InStock: true}, nil
+	}
+	cart, err := h.strg.Cart().GetById(c.Request.Context(), &models.CartPrimaryKey{CartId: cartId})
+	if err != nil {
+		return nil, err
+	}
+
+	cart.InStock = true
+	if len(cart.Items) == 0 {
+		return cart, nil
+	}
+	var ids = make([]interface{}, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		ids = append(ids, item.BookId)
+	}
+	resp, err := h.strg.Books().GetList(c.Request.Context(), &models.BookGetListRequest{
+		Limit:   len(ids),
+		Filters: []models.Filter{{Field: "id", Op: models.FilterIn, Value: ids}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var books = make(map[string]*models.Book, len(resp.Books))
+	for _, book := range resp.Books {
+		books[book.Id] = book
+	}
+	for _, item := range cart.Items {
+		item.Book = books[item.BookId]
+		if item.Book != nil {
+			item.UnitPrice = item.Book.Price
+			item.LineTotal = int64(item.Quantity) * item.Book.Price
+			item.Available = item.Book.StockQuantity - item.Book.ReservedQuantity
+			item.InStock = item.Available >= item.Quantity
+			if cart.Currency == "" {
+				cart.Currency = item.Book.Currency
+			}
+		}
+		cart.Subtotal += item.LineTotal
+		cart.InStock = cart.InStock && item.InStock
+	}
+	return cart, nil
+}
+
+// cartResponse answers with the cart as cart() builds it
+func (h *Handler) cartResponse(c *gin.Context, cartId string, message string, code int) {
+	cart, err := h.cart(c, cartId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Cart", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if cartId != "" {
+		setETag(c, cart.Version)
+	}
+	h.handlerResponse(c, message, code, cart)
+}
+
+// mergeCart moves a visitor cart into the user's cart at login. Login still
+// succeeds when it fails
+func (h *Handler) mergeCart(c *gin.Context, cartId string, userId string) {
+	if _, err := uuid.Parse(cartId); err != nil {
+		return
+	}
+	_, err := h.strg.Cart().Merge(c.Request.Context(), &models.MergeCart{CartId: cartId, UserId: userId})
+	if err != nil {
+		h.logger.Error("error while merging cart", logger.String("cart_id", cartId), logger.String("user_id", userId), logger.Error(err))
+	}
+}
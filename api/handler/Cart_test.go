@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckoutCartEmptiesCart(t *testing.T) {
+	s := newTestServer(t)
+	bookId := s.book(1000, 10)
+
+	s.expect(http.StatusOK, "POST", "/cart/items", models.CartItemRequest{BookId: bookId, Quantity: 3}, nil)
+	var order models.Order
+	s.expect(http.StatusCreated, "POST", "/cart/checkout", nil, &order)
+	if len(order.Items) != 1 || order.Items[0].Quantity != 3 {
+		t.Fatalf("order items = %+v, want 3 of the book", order.Items)
+	}
+
+	var cart models.Cart
+	s.expect(http.StatusOK, "GET", "/cart", nil, &cart)
+	if len(cart.Items) != 0 {
+		t.Fatalf("cart items = %+v after checkout, want none", cart.Items)
+	}
+	if stock, reserved := s.stock(bookId); stock != 10 || reserved != 3 {
+		t.Fatalf("stock %d reserved %d, want 10 and 3", stock, reserved)
+	}
+}
+
+func TestCheckoutKeepsChangedCart(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	bookId, otherId := s.book(1000, 10), s.book(500, 10)
+
+	var cart models.Cart
+	s.expect(http.StatusOK, "POST", "/cart/items", models.CartItemRequest{BookId: bookId, Quantity: 1}, &cart)
+	// a book added after the cart was read for checkout
+	s.expect(http.StatusOK, "POST", "/cart/items", models.CartItemRequest{BookId: otherId, Quantity: 1}, nil)
+
+	_, err := s.strg.Order().Checkout(ctx, &models.Checkout{
+		UserId:      s.userId,
+		Items:       []*models.CheckoutItem{{BookId: bookId, Quantity: 1}},
+		CartId:      cart.CartId,
+		CartVersion: cart.Version,
+	})
+	if !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("checkout of a stale cart = %v, want ErrVersionConflict", err)
+	}
+
+	s.expect(http.StatusOK, "GET", "/cart", nil, &cart)
+	if len(cart.Items) != 2 {
+		t.Fatalf("cart items = %+v, want both books kept", cart.Items)
+	}
+	if _, reserved := s.stock(bookId); reserved != 0 {
+		t.Fatalf("reserved %d after a refused checkout, want 0", reserved)
+	}
+}
+
+func TestCartWritesCheckIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	bookId := s.book(1000, 10)
+
+	var cart models.Cart
+	s.expect(http.StatusOK, "POST", "/cart/items", models.CartItemRequest{BookId: bookId, Quantity: 1}, &cart)
+	stale := cart.Version
+	s.expectWith(http.StatusOK, "PUT", "/cart/items/"+bookId, models.CartItemRequest{Quantity: 2}, ifMatch(0), nil)
+
+	for _, req := range []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/cart/items", models.CartItemRequest{BookId: bookId, Quantity: 1}},
+		{"PUT", "/cart/items/" + bookId, models.CartItemRequest{Quantity: 5}},
+		{"DELETE", "/cart/items/" + bookId, nil},
+		{"DELETE", "/cart", nil},
+		{"POST", "/cart/checkout", nil},
+	} {
+		resp := s.doWith(req.method, req.path, req.body, ifMatch(stale))
+		if resp.Status != http.StatusPreconditionFailed {
+			t.Errorf("%s %s with a stale If-Match = %d, want 412", req.method, req.path, resp.Status)
+		}
+	}
+
+	s.expect(http.StatusOK, "GET", "/cart", nil, &cart)
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 2 {
+		t.Fatalf("cart items = %+v, want 2 of the book", cart.Items)
+	}
+	s.expectWith(http.StatusOK, "DELETE", "/cart", nil, ifMatch(cart.Version), nil)
+}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
+	"strconv"
 )
 
 // CreateCategory godoc
@@ -27,6 +30,9 @@ func (h *Handler) CreateCategory(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
+	if !h.checkParent(c, createCategory.ParentId) {
+		return
+	}
 
 	CategoryId, err := h.strg.Category().Create(c.Request.Context(), createCategory)
 	if err != nil {
@@ -52,8 +58,12 @@ func (h *Handler) CreateCategory(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param user body models.UpdateCategory true "UpdateCategoryRequest"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) UpdateCategory(c *gin.Context) {
 	var category models.UpdateCategory
@@ -62,6 +72,11 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	category.Version = version
 	_, err = h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: category.Id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
@@ -71,14 +86,81 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if !h.checkParent(c, category.ParentId) {
+		return
+	}
 	resp, err := h.strg.Category().Update(c.Request.Context(), &category)
 	if err != nil {
+		if h.versionConflict(c, err, "Category") || h.categoryCycle(c, err) {
+			return
+		}
 		h.handlerResponse(c, "Error while updating Category", http.StatusInternalServerError, err.Error())
 		return
 	}
 	h.handlerResponse(c, "Category successfully updated", http.StatusCreated, resp)
 }
 
+// PatchCategory godoc
+// @ID patch_category
+// @Router /categories/{id} [PATCH]
+// @Summary Patch Category
+// @Description Update only the supplied fields of a Category
+// @Tags Category
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param category body models.PatchCategory true "PatchCategoryRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Category} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchCategory(c *gin.Context) {
+	var category models.PatchCategory
+	err := c.ShouldBindJSON(&category)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	category.Version = version
+	category.Id = c.Param("id")
+	if _, err := uuid.Parse(category.Id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if category.ParentId != nil && !h.checkParent(c, *category.ParentId) {
+		return
+	}
+
+	affected, err := h.strg.Category().Patch(c.Request.Context(), &category)
+	if err != nil {
+		if h.versionConflict(c, err, "Category") || h.categoryCycle(c, err) {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Category", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Category does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	resp, err := h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: category.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "Category successfully updated", http.StatusOK, resp)
+}
+
 // GetByIdCategory godoc
 // @ID get_by_id_category
 // @Router /categories/{id} [GET]
@@ -107,6 +189,7 @@ func (h *Handler) GetByIdCategory(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
 		return
 	}
+	setETag(c, category.Version)
 	h.handlerResponse(c, "Category successfully retrieved", http.StatusOK, category)
 }
 
@@ -118,6 +201,10 @@ func (h *Handler) GetByIdCategory(c *gin.Context) {
 // @Tags Category
 // @Accept json
 // @Procedure jsonUser
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[created_at][gte]=2024-01-01"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
@@ -132,9 +219,17 @@ func (h *Handler) GetListCategories(c *gin.Context) {
 		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
 		return
 	}
+	list, ok := h.listQuery(c, categoryList)
+	if !ok {
+		return
+	}
 	resp, err := h.strg.Category().GetList(c.Request.Context(), &models.CategoryGetListRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:  offset,
+		Limit:   limit,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
 	})
 	if err != nil {
 		h.handlerResponse(c, "Error while getting Categories", http.StatusInternalServerError, err.Error())
@@ -152,8 +247,12 @@ func (h *Handler) GetListCategories(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) DeleteCategory(c *gin.Context) {
 	var id = c.Param("id")
@@ -162,6 +261,11 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
 	_, err := h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
@@ -172,11 +276,202 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	err = h.strg.Category().Delete(c.Request.Context(), &models.CategoryPrimaryKey{Id: id})
+	subtree, err := h.strg.Category().GetTree(c.Request.Context(), &models.CategoryTreeRequest{RootId: id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(subtree) > 1 {
+		h.handlerResponse(c, "Category has subcategories, move or delete them first", http.StatusConflict, nil)
+		return
+	}
+
+	err = h.strg.Category().Delete(c.Request.Context(), &models.CategoryPrimaryKey{Id: id, Version: version})
 	if err != nil {
+		if h.versionConflict(c, err, "Category") {
+			return
+		}
 		h.handlerResponse(c, "Error while deleting Category", http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	h.handlerResponse(c, "Category deleted successfully", http.StatusOK, nil)
 }
+
+// GetCategoryTree godoc
+// @ID get_category_tree
+// @Router /categories/tree [GET]
+// @Summary Get Category Tree
+// @Description Get the categories nested under their parents, the whole tree or the subtree under root
+// @Tags Category
+// @Accept json
+// @Procedure json
+// @Param root query string false "id of the subtree root"
+// @Success 200 {object} Response{data=[]models.CategoryNode} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetCategoryTree(c *gin.Context) {
+	var root = c.Query("root")
+	if root != "" {
+		if _, err := uuid.Parse(root); err != nil {
+			h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	categories, err := h.strg.Category().GetTree(c.Request.Context(), &models.CategoryTreeRequest{RootId: root})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Categories", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if root != "" && len(categories) == 0 {
+		h.handlerResponse(c, "Category does not exist", http.StatusNotFound, nil)
+		return
+	}
+	h.handlerResponse(c, "Category tree successfully retrieved", http.StatusOK, categoryTree(categories))
+}
+
+// GetCategoryAncestors godoc
+// @ID get_category_ancestors
+// @Router /categories/{id}/ancestors [GET]
+// @Summary Get Category Ancestors
+// @Description Get the breadcrumbs of a category, from its top-level ancestor down to the category itself
+// @Tags Category
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=[]models.Category} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetCategoryAncestors(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path, err := h.strg.Category().GetAncestors(c.Request.Context(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Categories", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(path) == 0 {
+		h.handlerResponse(c, "Category does not exist", http.StatusNotFound, nil)
+		return
+	}
+	h.handlerResponse(c, "Category ancestors successfully retrieved", http.StatusOK, path)
+}
+
+// GetCategoryBooks godoc
+// @ID get_category_books
+// @Router /categories/{id}/books [GET]
+// @Summary Get Category Books
+// @Description Get the books of a category, optionally with the books of all its subcategories
+// @Tags Category
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param descendants query bool false "include books of all subcategories"
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[author]=Tolstoy"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at,title"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.BookGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetCategoryBooks(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	descendants, err := strconv.ParseBool(c.DefaultQuery("descendants", "false"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing descendants", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ids = []interface{}{id}
+	if descendants {
+		subtree, err := h.strg.Category().GetTree(c.Request.Context(), &models.CategoryTreeRequest{RootId: id})
+		if err != nil {
+			h.handlerResponse(c, "Error while getting Categories", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(subtree) == 0 {
+			h.handlerResponse(c, "Category does not exist", http.StatusNotFound, nil)
+			return
+		}
+		ids = ids[:0]
+		for _, category := range subtree {
+			ids = append(ids, category.Id)
+		}
+	} else {
+		_, err := h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: id})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "Category does not exist", http.StatusNotFound, nil)
+				return
+			}
+			h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	h.listBooks(c, models.Filter{Field: "category", Op: models.FilterIn, Value: ids})
+}
+
+// checkParent answers 400 when parentId is set but names no live category
+func (h *Handler) checkParent(c *gin.Context, parentId string) bool {
+	if parentId == "" {
+		return true
+	}
+	if _, err := uuid.Parse(parentId); err != nil {
+		h.handlerResponse(c, "Parent category is not valid", http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	_, err := h.strg.Category().GetById(c.Request.Context(), &models.CategoryPrimaryKey{Id: parentId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Parent category does not exist", http.StatusBadRequest, nil)
+			return false
+		}
+		h.handlerResponse(c, "Error while getting Category", http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// categoryCycle answers 409 when a move would put a category under itself
+func (h *Handler) categoryCycle(c *gin.Context, err error) bool {
+	if errors.Is(err, storage.ErrCategoryCycle) {
+		h.handlerResponse(c, "Category cannot be moved under itself or its subcategories", http.StatusConflict, err.Error())
+		return true
+	}
+	return false
+}
+
+// categoryTree nests categories under their parents. Categories whose parent
+// is not in the list become the top-level nodes
+func categoryTree(categories []*models.Category) []*models.CategoryNode {
+	var (
+		nodes = make(map[string]*models.CategoryNode, len(categories))
+		tree  = []*models.CategoryNode{}
+	)
+	for _, category := range categories {
+		nodes[category.Id] = &models.CategoryNode{Category: category, Children: []*models.CategoryNode{}}
+	}
+	for _, category := range categories {
+		node := nodes[category.Id]
+		if parent, ok := nodes[category.ParentId]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			tree = append(tree, node)
+		}
+	}
+	return tree
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPatchCategoryKeepsOmittedFields(t *testing.T) {
+	s := newTestServer(t)
+	var parent, category models.Category
+	s.expect(http.StatusCreated, "POST", "/categories", models.CreateCategory{Name: "Fiction", Type: "genre"}, &parent)
+	s.expect(http.StatusCreated, "POST", "/categories", models.CreateCategory{ParentId: parent.Id, Name: "Sci-fi", Type: "genre", Picture: "scifi.png"}, &category)
+
+	name := "Science fiction"
+	s.expectWith(http.StatusOK, "PATCH", "/categories/"+category.Id, models.PatchCategory{Name: &name}, ifMatch(category.Version), nil)
+
+	var got models.Category
+	s.expect(http.StatusOK, "GET", "/categories/"+category.Id, nil, &got)
+	if got.Name != name || got.ParentId != parent.Id || got.Type != "genre" || got.Picture != "scifi.png" {
+		t.Errorf("category after patching the name = %+v, want only the name changed", got)
+	}
+}
+
+// category creates a category named name under parentId
+func (s *testServer) category(name, parentId string) string {
+	s.t.Helper()
+	var category models.Category
+	s.expect(http.StatusCreated, "POST", "/categories", models.CreateCategory{ParentId: parentId, Name: name}, &category)
+	return category.Id
+}
+
+func TestMoveCategorySubtree(t *testing.T) {
+	s := newTestServer(t)
+	fiction, nonfiction := s.category("Fiction", ""), s.category("Nonfiction", "")
+	scifi := s.category("Sci-fi", fiction)
+	space := s.category("Space opera", scifi)
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{Title: "Dune", Price: 1000, Currency: "USD", CategoryIds: []string{space}}, &book)
+
+	move := func(status int, id, parentId string) {
+		t.Helper()
+		s.expectWith(status, "PATCH", "/categories/"+id, models.PatchCategory{ParentId: &parentId}, ifMatch(0), nil)
+	}
+	move(http.StatusConflict, fiction, fiction)
+	move(http.StatusConflict, fiction, space)
+	move(http.StatusConflict, scifi, space)
+	move(http.StatusOK, scifi, nonfiction)
+
+	var path []*models.Category
+	s.expect(http.StatusOK, "GET", "/categories/"+space+"/ancestors", nil, &path)
+	var names []string
+	for _, category := range path {
+		names = append(names, category.Name)
+	}
+	if want := []string{"Nonfiction", "Sci-fi", "Space opera"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ancestors after the move = %v, want %v", names, want)
+	}
+
+	var tree []*models.CategoryNode
+	s.expect(http.StatusOK, "GET", "/categories/tree?root="+nonfiction, nil, &tree)
+	if len(tree) != 1 || len(tree[0].Children) != 1 || tree[0].Children[0].Id != scifi ||
+		len(tree[0].Children[0].Children) != 1 || tree[0].Children[0].Children[0].Id != space {
+		t.Errorf("tree under Nonfiction does not hold the moved subtree")
+	}
+
+	books := func(categoryId string) int {
+		t.Helper()
+		var list models.BookGetListResponse
+		s.expect(http.StatusOK, "GET", "/categories/"+categoryId+"/books?descendants=true", nil, &list)
+		return len(list.Books)
+	}
+	if got := books(nonfiction); got != 1 {
+		t.Errorf("books under Nonfiction = %d, want the moved book", got)
+	}
+	if got := books(fiction); got != 0 {
+		t.Errorf("books under Fiction = %d, want none after the move", got)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/storage"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// inventoryReasons maps each reason an adjustment may be made for to the sign
+// its delta must have, 0 when either is allowed
+var inventoryReasons = map[string]int{
+	models.InventoryReasonRestock:    1,
+	models.InventoryReasonReturn:     1,
+	models.InventoryReasonDamage:     -1,
+	models.InventoryReasonLoss:       -1,
+	models.InventoryReasonCorrection: 0,
+}
+
+// AdjustInventory godoc
+// @ID adjust_inventory
+// @Router /books/{id}/inventory [POST]
+// @Summary Adjust Inventory
+// @Description Change the stock of a book and record the movement. restock and return add stock, damage and loss remove it, correction may do either
+// @Tags Inventory
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param adjustment body models.AdjustInventory true "AdjustInventoryRequest"
+// @Success 201 {object} Response{data=models.InventoryMovement} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) AdjustInventory(c *gin.Context) {
+	var req models.AdjustInventory
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	req.BookId = c.Param("id")
+	if _, err := uuid.Parse(req.BookId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	sign, ok := inventoryReasons[req.Reason]
+	if !ok {
+		h.handlerResponse(c, "Reason is not valid", http.StatusBadRequest, "reason must be one of restock, return, damage, loss, correction")
+		return
+	}
+	if req.Delta == 0 || sign > 0 && req.Delta < 0 || sign < 0 && req.Delta > 0 {
+		h.handlerResponse(c, "Delta is not valid", http.StatusBadRequest, fmt.Sprintf("delta must be non-zero and agree with the %s reason", req.Reason))
+		return
+	}
+	req.UserId = h.currentUser(c).Id
+
+	movement, err := h.strg.Inventory().Adjust(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, storage.ErrInsufficientStock) {
+			h.handlerResponse(c, "Not enough stock, the book cannot go below its reserved quantity", http.StatusConflict, err.Error())
+			return
+		}
+		h.handlerResponse(c, "Error while adjusting Inventory", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if movement == nil {
+		h.handlerResponse(c, "Book does not exist", http.StatusNotFound, nil)
+		return
+	}
+	h.handlerResponse(c, "Inventory successfully adjusted", http.StatusCreated, movement)
+}
+
+// GetInventoryMovements godoc
+// @ID get_inventory_movements
+// @Router /books/{id}/inventory [GET]
+// @Summary Get Inventory Movements
+// @Description Get the stock ledger of a book, newest first
+// @Tags Inventory
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[reason]=restock"
+// @Param sort query string false "comma separated fields, - for descending; e.g. created_at"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.InventoryMovementGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetInventoryMovements(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
+		return
+	}
+	list, ok := h.listQuery(c, movementList)
+	if !ok {
+		return
+	}
+
+	_, err = h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Book does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := h.strg.Inventory().GetMovements(c.Request.Context(), &models.InventoryMovementGetListRequest{
+		BookId:  id,
+		Offset:  offset,
+		Limit:   limit,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Inventory movements", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Inventory movements successfully retrieved", http.StatusOK, resp)
+}
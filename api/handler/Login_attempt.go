@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app/api/models"
+	"app/pkg/logger"
+)
+
+// GetListLoginAttempts godoc
+// @ID get_list_login_attempts
+// @Router /login_attempts [GET]
+// @Summary Get List Login Attempts
+// @Description Get List Login Attempts, newest first
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Param username query string false "username"
+// @Param ip query string false "ip"
+// @Success 200 {object} Response{data=models.LoginAttemptGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetListLoginAttempts(c *gin.Context) {
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
+		return
+	}
+	resp, err := h.strg.LoginAttempt().GetList(c.Request.Context(), &models.LoginAttemptGetListRequest{
+		Offset:   offset,
+		Limit:    limit,
+		Username: c.Query("username"),
+		Ip:       c.Query("ip"),
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Login Attempts", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Login Attempts successfully retrieved", http.StatusOK, resp)
+}
+
+// loginDelay returns how long the caller has to wait before the login attemptId
+// for username from ip is allowed. Every other failure counts, including
+// attempts still pending, so concurrent guesses cannot all see a clean record.
+// Failures back off exponentially and lock the username out after
+// LoginMaxFailures; an ip is only locked out
+func (h *Handler) loginDelay(ctx context.Context, attemptId, username, ip string) (time.Duration, error) {
+	var (
+		now   = time.Now().UTC()
+		since = now.Add(-h.cfg.LoginFailureWindow)
+		wait  time.Duration
+	)
+
+	byUser, err := h.strg.LoginAttempt().GetFailures(ctx, &models.LoginFailureRequest{Username: username, Since: since, ExceptId: attemptId})
+	if err != nil {
+		return 0, err
+	}
+	if byUser.Count >= h.cfg.LoginMaxFailures {
+		wait = byUser.Last.Add(h.cfg.LoginLockoutDuration).Sub(now)
+	} else if byUser.Count > 0 {
+		wait = byUser.Last.Add(h.loginBackoff(byUser.Count)).Sub(now)
+	}
+
+	byIp, err := h.strg.LoginAttempt().GetFailures(ctx, &models.LoginFailureRequest{Ip: ip, Since: since, ExceptId: attemptId})
+	if err != nil {
+		return 0, err
+	}
+	if byIp.Count >= h.cfg.LoginIPMaxFailures {
+		if ipWait := byIp.Last.Add(h.cfg.LoginLockoutDuration).Sub(now); ipWait > wait {
+			wait = ipWait
+		}
+	}
+
+	return wait, nil
+}
+
+func (h *Handler) loginBackoff(failures int) time.Duration {
+	backoff := time.Duration(float64(h.cfg.LoginBackoffBase) * math.Pow(2, float64(failures-1)))
+	if backoff <= 0 || backoff > h.cfg.LoginBackoffMax {
+		backoff = h.cfg.LoginBackoffMax
+	}
+	return backoff
+}
+
+// reserveLoginAttempt writes a pending audit row before the password is
+// checked; the login is refused when it cannot be written
+func (h *Handler) reserveLoginAttempt(c *gin.Context, username string) (string, error) {
+	return h.strg.LoginAttempt().Create(c.Request.Context(), &models.CreateLoginAttempt{
+		Username:  username,
+		Ip:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Reason:    models.LoginPending,
+	})
+}
+
+// resolveLoginAttempt records the outcome of a pending attempt; a failed write
+// is logged and leaves the attempt counted as a failure
+func (h *Handler) resolveLoginAttempt(c *gin.Context, attemptId, userId, reason string) {
+	_, err := h.strg.LoginAttempt().Update(c.Request.Context(), &models.UpdateLoginAttempt{
+		Id:      attemptId,
+		UserId:  userId,
+		Success: reason == models.LoginSuccess,
+		Reason:  reason,
+	})
+	if err != nil {
+		h.logger.Error("error while recording login attempt", logger.Error(err))
+	}
+}
+
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loginServer serves logins for reader1 with password Str0ng!pass, backing off
+// base after the first failure up to ceiling
+func loginServer(t *testing.T, base, ceiling time.Duration) *testServer {
+	t.Helper()
+	s := newTestServer(t)
+	s.h.cfg.LoginFailureWindow = time.Hour
+	s.h.cfg.LoginLockoutDuration = time.Hour
+	s.h.cfg.LoginBackoffBase = base
+	s.h.cfg.LoginBackoffMax = ceiling
+
+	hash, err := helper.HashPassword("Str0ng!pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.strg.Users().Create(context.Background(), &models.CreateUser{Username: "reader1", Email: "reader1@example.com", Password: hash, Role: models.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func loginRequest(username, password string) models.UserLoginRequest {
+	return models.UserLoginRequest{Username: username, Password: password}
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	s := loginServer(t, time.Nanosecond, time.Nanosecond)
+
+	unknown := s.do("POST", "/login", loginRequest("nobody1", "Str0ng!pass"), nil)
+	wrong := s.do("POST", "/login", loginRequest("reader1", "Wr0ng!pass"), nil)
+	if unknown.Status != http.StatusUnauthorized || wrong.Status != http.StatusUnauthorized {
+		t.Fatalf("unknown user = %d, wrong password = %d, want 401", unknown.Status, wrong.Status)
+	}
+	if unknown.Description != wrong.Description || string(unknown.Data) != string(wrong.Data) {
+		t.Errorf("unknown user answered %q %s, wrong password %q %s", unknown.Description, unknown.Data, wrong.Description, wrong.Data)
+	}
+}
+
+func TestLoginBacksOffAfterFailure(t *testing.T) {
+	s := loginServer(t, time.Minute, time.Hour)
+
+	s.expect(http.StatusUnauthorized, "POST", "/login", loginRequest("reader1", "Wr0ng!pass"), nil)
+	s.expect(http.StatusTooManyRequests, "POST", "/login", loginRequest("reader1", "Wr0ng!pass"), nil)
+	s.expect(http.StatusTooManyRequests, "POST", "/login", loginRequest("reader1", "Str0ng!pass"), nil)
+}
+
+func TestLoginLocksOutAfterMaxFailures(t *testing.T) {
+	s := loginServer(t, time.Nanosecond, time.Nanosecond)
+	s.h.cfg.LoginMaxFailures = 3
+
+	for i := 0; i < 3; i++ {
+		s.expect(http.StatusUnauthorized, "POST", "/login", loginRequest("reader1", "Wr0ng!pass"), nil)
+	}
+	s.expect(http.StatusTooManyRequests, "POST", "/login", loginRequest("reader1", "Str0ng!pass"), nil)
+
+	// the throttled attempt does not extend the lockout
+	attempts, err := s.strg.LoginAttempt().GetFailures(context.Background(), &models.LoginFailureRequest{Username: "reader1", Since: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Count != 3 {
+		t.Errorf("failures = %d, want 3", attempts.Count)
+	}
+}
+
+func TestLoginCountsConcurrentGuesses(t *testing.T) {
+	s := loginServer(t, time.Minute, time.Hour)
+
+	const guesses = 10
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = map[int]int{}
+	)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := s.do("POST", "/login", loginRequest("reader1", "Wr0ng!pass"), nil)
+			mu.Lock()
+			statuses[resp.Status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// a guess that does not see the others as pending failures is let through
+	if statuses[http.StatusUnauthorized] > 1 || statuses[http.StatusUnauthorized]+statuses[http.StatusTooManyRequests] != guesses {
+		t.Errorf("statuses = %v, want at most one password check", statuses)
+	}
+}
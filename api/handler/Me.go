@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"app/api/models"
+	"app/pkg/helper"
+	"app/pkg/logger"
+)
+
+// GetMe godoc
+// @ID get_me
+// @Router /me [GET]
+// @Summary Get Me
+// @Description Get the profile of the authenticated user
+// @Tags Me
+// @Accept json
+// @Procedure json
+// @Success 200 {object} Response{data=models.PrivateUser} "Success Request"
+// @Response 401 {object} Response{data=string} "Unauthorized"
+func (h *Handler) GetMe(c *gin.Context) {
+	user := h.currentUser(c)
+	setETag(c, user.Version)
+	h.handlerResponse(c, "User successfully retrieved", http.StatusOK, privateUser(user))
+}
+
+// UpdateMe godoc
+// @ID update_me
+// @Router /me [PATCH]
+// @Summary Update Me
+// @Description Update the profile of the authenticated user, only the supplied fields change
+// @Tags Me
+// @Accept json
+// @Procedure json
+// @Param profile body models.UpdateProfile true "UpdateProfile"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.PrivateUser} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Email already in use"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) UpdateMe(c *gin.Context) {
+	var (
+		req  models.UpdateProfile
+		user = h.currentUser(c)
+		ctx  = c.Request.Context()
+	)
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	if req.Age != nil && *req.Age < 0 {
+		h.handlerResponse(c, "Age is not valid", http.StatusBadRequest, "age must not be negative")
+		return
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.Email))
+		if !helper.IsValidEmail(email) {
+			h.handlerResponse(c, "Email is not valid", http.StatusBadRequest, "Email is not valid")
+			return
+		}
+		req.Email = &email
+
+		if email != user.Email {
+			if !h.checkEmailFree(c, email, user.Id) {
+				return
+			}
+			emailChanged = true
+		}
+	}
+
+	_, err = h.strg.Users().Patch(ctx, &models.PatchUser{
+		Id:        user.Id,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Age:       req.Age,
+		Phone:     req.Phone,
+		Picture:   req.Picture,
+		Email:     req.Email,
+		CardNo:    req.CardNo,
+		Version:   version,
+	})
+	if err != nil {
+		if h.versionConflict(c, err, "User") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating User", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := h.strg.Users().GetById(ctx, &models.UserPrimaryKey{Id: user.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if emailChanged {
+		h.reverify(ctx, resp)
+	}
+
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "User successfully updated", http.StatusOK, privateUser(resp))
+}
+
+// checkEmailFree answers 409 when email belongs to a user other than userId
+func (h *Handler) checkEmailFree(c *gin.Context, email string, userId string) bool {
+	existing, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Email: email})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			return true
+		}
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if existing.Id != userId {
+		h.handlerResponse(c, "Email already in use", http.StatusConflict, nil)
+		return false
+	}
+	return true
+}
+
+// reverify mails a new verification link to a user whose email changed; the
+// storage has already marked them unverified. A failed mail does not fail the
+// update
+func (h *Handler) reverify(ctx context.Context, user *models.User) {
+	if user.Email == "" {
+		return
+	}
+	err := h.sendVerification(ctx, user)
+	if err != nil {
+		h.logger.Error("verification mail", logger.String("user_id", user.Id), logger.Error(err))
+	}
+}
+
+// ChangePassword godoc
+// @ID change_password
+// @Router /me/password [POST]
+// @Summary Change Password
+// @Description Change the password of the authenticated user. Every session is signed out, so the client has to log in again
+// @Tags Me
+// @Accept json
+// @Procedure json
+// @Param password body models.ChangePasswordRequest true "ChangePasswordRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var (
+		req  models.ChangePasswordRequest
+		user = h.currentUser(c)
+		ctx  = c.Request.Context()
+	)
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !helper.CheckPasswordHash(req.OldPassword, user.Password) {
+		h.handlerResponse(c, "Old password is incorrect", http.StatusBadRequest, "Old password is incorrect")
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		h.handlerResponse(c, "New password must differ from the old one", http.StatusBadRequest, "New password must differ from the old one")
+		return
+	}
+	if !h.checkPassword(c, req.NewPassword, user.Username) {
+		return
+	}
+
+	hashedPassword, err := helper.HashPassword(req.NewPassword)
+	if err != nil {
+		h.handlerResponse(c, "Error hashing password", http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = h.strg.Users().UpdatePassword(ctx, &models.UpdatePassword{Id: user.Id, Password: hashedPassword})
+	if err != nil {
+		h.handlerResponse(c, "Error while updating password", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = h.logoutEverywhere(ctx, user.Id)
+	if err != nil {
+		h.handlerResponse(c, "Error while revoking tokens", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.setCookie(c, "Authorization", "", -1, "/")
+	h.setCookie(c, "RefreshToken", "", -1, "/refresh")
+	h.handlerResponse(c, "Password successfully changed", http.StatusOK, nil)
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,7 +14,7 @@ import (
 // @ID create_order
 // @Router /orders [POST]
 // @Summary Create Order
-// @Description Create Order
+// @Description Create Order. Customers always order for themselves; staff may set user_id, which defaults to the caller
 // @Tags Order
 // @Accept json
 // @Procedure json
@@ -28,6 +30,15 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	if !h.canOrder(c) {
+		h.handlerResponse(c, "Email is not verified", http.StatusForbidden, "Verify your email before ordering")
+		return
+	}
+	if !h.isStaff(c) || createOrder.UserId == "" {
+		createOrder.UserId = c.GetString("user_id")
+	}
+	createOrder.TaxRate = h.cfg.TaxRate
+
 	OrderId, err := h.strg.Order().Create(c.Request.Context(), createOrder)
 	if err != nil {
 		h.handlerResponse(c, "Error while creating Order", http.StatusInternalServerError, err.Error())
@@ -43,6 +54,81 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 	h.handlerResponse(c, "Order successfully created", http.StatusCreated, Order)
 }
 
+// CheckoutOrder godoc
+// @ID checkout_order
+// @Router /orders/checkout [POST]
+// @Summary Checkout Order
+// @Description Place an order for several books at once. Prices are taken from the books and their stock is reserved; nothing is saved unless every item can be ordered
+// @Tags Order
+// @Accept json
+// @Procedure json
+// @Param checkout body models.Checkout true "CheckoutRequest"
+// @Success 201 {object} Response{data=models.Order} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 403 {object} Response{data=string} "Forbidden"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) CheckoutOrder(c *gin.Context) {
+	var checkout models.Checkout
+	err := c.ShouldBindJSON(&checkout)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.canOrder(c) {
+		h.handlerResponse(c, "Email is not verified", http.StatusForbidden, "Verify your email before ordering")
+		return
+	}
+	if !h.isStaff(c) || checkout.UserId == "" {
+		checkout.UserId = c.GetString("user_id")
+	}
+	items, ok := h.checkoutItems(c, checkout.Items)
+	if !ok {
+		return
+	}
+	checkout.Items = items
+	checkout.TaxRate = h.cfg.TaxRate
+
+	OrderId, err := h.strg.Order().Checkout(c.Request.Context(), &checkout)
+	if err != nil {
+		h.checkoutError(c, err)
+		return
+	}
+	h.placedOrder(c, OrderId)
+}
+
+// checkoutError answers for an error from Order().Checkout
+func (h *Handler) checkoutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrBookNotFound):
+		h.handlerResponse(c, "Book does not exist", http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrCurrencyMismatch):
+		h.handlerResponse(c, "Books are priced in different currencies, order them separately", http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrInsufficientStock):
+		h.handlerResponse(c, "Not enough stock", http.StatusConflict, err.Error())
+	default:
+		h.handlerResponse(c, "Error while placing Order", http.StatusInternalServerError, err.Error())
+	}
+}
+
+// placedOrder answers with a newly placed order and its items
+func (h *Handler) placedOrder(c *gin.Context, orderId string) {
+	Order, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: orderId})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = h.expandOrder(c, Order)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order items", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(c, Order.Version)
+	h.handlerResponse(c, "Order successfully placed", http.StatusCreated, Order)
+}
+
 // UpdateOrder godoc
 // @ID update_order
 // @Router /orders [PUT]
@@ -52,8 +138,12 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param user body models.Order true "UpdateOrderRequest"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) UpdateOrder(c *gin.Context) {
 	var order models.UpdateOrder
@@ -62,7 +152,12 @@ func (h *Handler) UpdateOrder(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
-	_, err = h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: order.OrderId})
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	order.Version = version
+	existing, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: order.OrderId})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
 			h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
@@ -71,24 +166,119 @@ func (h *Handler) UpdateOrder(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if !h.canAccessOrder(c, existing) {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+	if !h.isStaff(c) {
+		order.UserId = existing.UserId
+		order.Discount = existing.Discount
+	}
+	if order.Discount < 0 {
+		h.handlerResponse(c, "Discount is not valid", http.StatusBadRequest, "discount must not be negative")
+		return
+	}
 	resp, err := h.strg.Order().Update(c.Request.Context(), &order)
 	if err != nil {
+		if h.versionConflict(c, err, "Order") {
+			return
+		}
+		if errors.Is(err, storage.ErrOrderNotPending) {
+			h.handlerResponse(c, "Order is no longer pending, its discount cannot change", http.StatusConflict, err.Error())
+			return
+		}
 		h.handlerResponse(c, "Error while updating Order", http.StatusInternalServerError, err.Error())
 		return
 	}
 	h.handlerResponse(c, "Order successfully updated", http.StatusCreated, resp)
 }
 
+// PatchOrder godoc
+// @ID patch_order
+// @Router /orders/{id} [PATCH]
+// @Summary Patch Order
+// @Description Update only the supplied fields of an Order
+// @Tags Order
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param order body models.PatchOrder true "PatchOrderRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchOrder(c *gin.Context) {
+	var order models.PatchOrder
+	err := c.ShouldBindJSON(&order)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	order.Version = version
+	order.OrderId = c.Param("id")
+	if _, err := uuid.Parse(order.OrderId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	owned, err := h.ownsOrder(c, order.OrderId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !owned {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+	if !h.isStaff(c) {
+		order.UserId = nil
+		order.Discount = nil
+	}
+	if order.Discount != nil && *order.Discount < 0 {
+		h.handlerResponse(c, "Discount is not valid", http.StatusBadRequest, "discount must not be negative")
+		return
+	}
+
+	_, err = h.strg.Order().Patch(c.Request.Context(), &order)
+	if err != nil {
+		if h.versionConflict(c, err, "Order") {
+			return
+		}
+		if errors.Is(err, storage.ErrOrderNotPending) {
+			h.handlerResponse(c, "Order is no longer pending, its discount cannot change", http.StatusConflict, err.Error())
+			return
+		}
+		h.handlerResponse(c, "Error while updating Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: order.OrderId})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "Order successfully updated", http.StatusOK, resp)
+}
+
 // GetByIdOrder godoc
 // @ID get_by_id_order
 // @Router /orders/{id} [GET]
 // @Summary Get By ID Order
-// @Description Get By ID Order
+// @Description Get an Order with its totals and its items, each with its book
 // @Tags Order
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) GetByIdOrder(c *gin.Context) {
@@ -107,6 +297,16 @@ func (h *Handler) GetByIdOrder(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if !h.canAccessOrder(c, order) {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+	err = h.expandOrder(c, order)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order items", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, order.Version)
 	h.handlerResponse(c, "Order successfully retrieved", http.StatusOK, order)
 }
 
@@ -118,6 +318,10 @@ func (h *Handler) GetByIdOrder(c *gin.Context) {
 // @Tags Order
 // @Accept json
 // @Procedure jsonUser
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[created_at][gte]=2024-01-01"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
@@ -132,9 +336,22 @@ func (h *Handler) GetListOrders(c *gin.Context) {
 		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
 		return
 	}
+	var userId = c.Query("user_id")
+	if !h.isStaff(c) {
+		userId = c.GetString("user_id")
+	}
+	list, ok := h.listQuery(c, orderList)
+	if !ok {
+		return
+	}
 	resp, err := h.strg.Order().GetList(c.Request.Context(), &models.OrderGetListRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:  offset,
+		Limit:   limit,
+		UserId:  userId,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
 	})
 	if err != nil {
 		h.handlerResponse(c, "Error while getting Orders", http.StatusInternalServerError, err.Error())
@@ -152,8 +369,13 @@ func (h *Handler) GetListOrders(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 403 {object} Response{data=string} "Forbidden"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) DeleteOrder(c *gin.Context) {
 	var id = c.Param("id")
@@ -162,7 +384,12 @@ func (h *Handler) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	_, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: id})
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
 			h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
@@ -171,12 +398,131 @@ func (h *Handler) DeleteOrder(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if !h.canAccessOrder(c, order) {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+	// deleting an open order cancels it, which only staff may do once it is paid
+	if !h.isStaff(c) && order.Status != models.OrderStatusPending && !models.IsTerminal(order.Status) {
+		h.handlerResponse(c, "Forbidden", http.StatusForbidden, "Only pending orders can be cancelled by their owner")
+		return
+	}
 
-	err = h.strg.Order().Delete(c.Request.Context(), &models.OrderPrimaryKey{OrderId: id})
+	err = h.strg.Order().Delete(c.Request.Context(), &models.OrderPrimaryKey{OrderId: id, Version: version, UserId: c.GetString("user_id")})
 	if err != nil {
+		if h.versionConflict(c, err, "Order") {
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidTransition) {
+			h.handlerResponse(c, "Order cannot be cancelled, so it cannot be deleted", http.StatusConflict, err.Error())
+			return
+		}
 		h.handlerResponse(c, "Error while deleting Order", http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	h.handlerResponse(c, "Order deleted successfully", http.StatusOK, nil)
 }
+
+// checkoutItems answers 400 for an empty cart, a malformed book id or a
+// quantity below one, and merges repeated books into one item
+func (h *Handler) checkoutItems(c *gin.Context, items []*models.CheckoutItem) ([]*models.CheckoutItem, bool) {
+	if len(items) == 0 {
+		h.handlerResponse(c, "Order has no items", http.StatusBadRequest, nil)
+		return nil, false
+	}
+	var (
+		merged []*models.CheckoutItem
+		byBook = map[string]*models.CheckoutItem{}
+	)
+	for _, item := range items {
+		if item == nil {
+			h.handlerResponse(c, "Order item is not valid", http.StatusBadRequest, nil)
+			return nil, false
+		}
+		if _, err := uuid.Parse(item.BookId); err != nil {
+			h.handlerResponse(c, "Book id is not valid", http.StatusBadRequest, err.Error())
+			return nil, false
+		}
+		if item.Quantity < 1 {
+			h.handlerResponse(c, "Quantity is not valid", http.StatusBadRequest, "quantity must be at least 1")
+			return nil, false
+		}
+		if existing, ok := byBook[item.BookId]; ok {
+			existing.Quantity += item.Quantity
+			continue
+		}
+		item := *item
+		byBook[item.BookId] = &item
+		merged = append(merged, &item)
+	}
+	return merged, true
+}
+
+// orderItems returns every live item of orderId
+func (h *Handler) orderItems(c *gin.Context, orderId string) ([]*models.OrderItem, error) {
+	resp, err := h.strg.OrderItem().GetList(c.Request.Context(), &models.OrderItemGetListRequest{
+		Limit:   maxOrderItems,
+		Filters: []models.Filter{{Field: "order_id", Op: models.FilterEq, Value: orderId}},
+		Sorts:   []models.Sort{{Field: "created_at"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.OrderItems, nil
+}
+
+// expandOrder fills in the order's items and the book of each of them. A book
+// deleted since it was ordered is left out
+func (h *Handler) expandOrder(c *gin.Context, order *models.Order) error {
+	items, err := h.orderItems(c, order.OrderId)
+	if err != nil {
+		return err
+	}
+	order.Items = items
+	if len(items) == 0 {
+		return nil
+	}
+
+	var ids = make([]interface{}, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.BookId)
+	}
+	resp, err := h.strg.Books().GetList(c.Request.Context(), &models.BookGetListRequest{
+		Limit:   len(ids),
+		Filters: []models.Filter{{Field: "id", Op: models.FilterIn, Value: ids}},
+	})
+	if err != nil {
+		return err
+	}
+	var books = make(map[string]*models.Book, len(resp.Books))
+	for _, book := range resp.Books {
+		books[book.Id] = book
+	}
+	for _, item := range items {
+		item.Book = books[item.BookId]
+	}
+	return nil
+}
+
+// maxOrderItems bounds the items loaded with an order
+const maxOrderItems = 1000
+
+// canAccessOrder reports whether the current user may see or modify order;
+// customers only reach their own orders
+func (h *Handler) canAccessOrder(c *gin.Context, order *models.Order) bool {
+	return h.isStaff(c) || order.UserId == c.GetString("user_id")
+}
+
+// ownsOrder looks orderId up and applies canAccessOrder to it, treating a
+// missing order the same as a foreign one
+func (h *Handler) ownsOrder(c *gin.Context, orderId string) (bool, error) {
+	order, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: orderId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			return false, nil
+		}
+		return false, err
+	}
+	return h.canAccessOrder(c, order), nil
+}
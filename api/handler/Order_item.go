@@ -2,6 +2,8 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,6 +21,7 @@ import (
 // @Param user body models.CreateOrderItem true "CreateOrderItemRequest"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) CreateOrderItem(c *gin.Context) {
 	var createOrderItem *models.CreateOrderItem
@@ -28,9 +31,32 @@ func (h *Handler) CreateOrderItem(c *gin.Context) {
 		return
 	}
 
+	if !h.canOrder(c) {
+		h.handlerResponse(c, "Email is not verified", http.StatusForbidden, "Verify your email before ordering")
+		return
+	}
+	owned, err := h.ownsOrder(c, createOrderItem.OrderId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !owned {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+	if !h.checkQuantity(c, &createOrderItem.Quantity) {
+		return
+	}
+	book, ok := h.priceOrderItem(c, createOrderItem.OrderId, "", createOrderItem.BookId)
+	if !ok {
+		return
+	}
+	createOrderItem.UnitPrice, createOrderItem.Currency = book.Price, book.Currency
+	createOrderItem.UserId = c.GetString("user_id")
+
 	OrderItemId, err := h.strg.OrderItem().Create(c.Request.Context(), createOrderItem)
 	if err != nil {
-		h.handlerResponse(c, "Error while creating OrderItem", http.StatusInternalServerError, err.Error())
+		h.orderItemError(c, err, "Error while creating OrderItem")
 		return
 	}
 	OrderItem, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: OrderItemId})
@@ -52,8 +78,12 @@ func (h *Handler) CreateOrderItem(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param user body models.OrderItem true "UpdateOrderItemRequest"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Response 409 {object} Response{data=string} "Conflict"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) UpdateOrderItem(c *gin.Context) {
 	var orderItem models.UpdateOrderItem
@@ -62,7 +92,12 @@ func (h *Handler) UpdateOrderItem(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
-	_, err = h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: orderItem.ItemId})
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	orderItem.Version = version
+	existing, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: orderItem.ItemId})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
 			h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
@@ -71,14 +106,132 @@ func (h *Handler) UpdateOrderItem(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting OrderItem", http.StatusInternalServerError, err.Error())
 		return
 	}
+	for _, orderId := range []string{existing.OrderId, orderItem.OrderId} {
+		owned, err := h.ownsOrder(c, orderId)
+		if err != nil {
+			h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !owned {
+			h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
+			return
+		}
+	}
+	if !h.checkQuantity(c, &orderItem.Quantity) {
+		return
+	}
+	orderItem.UnitPrice, orderItem.Currency = existing.UnitPrice, existing.Currency
+	if orderItem.BookId != existing.BookId || orderItem.OrderId != existing.OrderId {
+		book, ok := h.priceOrderItem(c, orderItem.OrderId, orderItem.ItemId, orderItem.BookId)
+		if !ok {
+			return
+		}
+		orderItem.UnitPrice, orderItem.Currency = book.Price, book.Currency
+	}
+	orderItem.UserId = c.GetString("user_id")
 	resp, err := h.strg.OrderItem().Update(c.Request.Context(), &orderItem)
 	if err != nil {
-		h.handlerResponse(c, "Error while updating OrderItem", http.StatusInternalServerError, err.Error())
+		h.orderItemError(c, err, "Error while updating OrderItem")
 		return
 	}
 	h.handlerResponse(c, "OrderItem successfully updated", http.StatusCreated, resp)
 }
 
+// PatchOrderItem godoc
+// @ID patch_OrderItem
+// @Router /order_items/{id} [PATCH]
+// @Summary Patch OrderItem
+// @Description Update only the supplied fields of an OrderItem
+// @Tags OrderItem
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param order_item body models.PatchOrderItem true "PatchOrderItemRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.OrderItem} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchOrderItem(c *gin.Context) {
+	var orderItem models.PatchOrderItem
+	err := c.ShouldBindJSON(&orderItem)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	orderItem.Version = version
+	orderItem.ItemId = c.Param("id")
+	if _, err := uuid.Parse(orderItem.ItemId); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: orderItem.ItemId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting OrderItem", http.StatusInternalServerError, err.Error())
+		return
+	}
+	orderIds := []string{existing.OrderId}
+	if orderItem.OrderId != nil {
+		orderIds = append(orderIds, *orderItem.OrderId)
+	}
+	for _, orderId := range orderIds {
+		owned, err := h.ownsOrder(c, orderId)
+		if err != nil {
+			h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !owned {
+			h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
+			return
+		}
+	}
+	if orderItem.Quantity != nil && *orderItem.Quantity < 1 {
+		h.handlerResponse(c, "Quantity is not valid", http.StatusBadRequest, "quantity must be at least 1")
+		return
+	}
+	if (orderItem.BookId != nil && *orderItem.BookId != existing.BookId) || (orderItem.OrderId != nil && *orderItem.OrderId != existing.OrderId) {
+		orderId, bookId := existing.OrderId, existing.BookId
+		if orderItem.OrderId != nil {
+			orderId = *orderItem.OrderId
+		}
+		if orderItem.BookId != nil {
+			bookId = *orderItem.BookId
+		}
+		book, ok := h.priceOrderItem(c, orderId, orderItem.ItemId, bookId)
+		if !ok {
+			return
+		}
+		orderItem.UnitPrice, orderItem.Currency = &book.Price, &book.Currency
+	}
+
+	orderItem.UserId = c.GetString("user_id")
+	_, err = h.strg.OrderItem().Patch(c.Request.Context(), &orderItem)
+	if err != nil {
+		h.orderItemError(c, err, "Error while updating OrderItem")
+		return
+	}
+
+	resp, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: orderItem.ItemId})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting OrderItem", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "OrderItem successfully updated", http.StatusOK, resp)
+}
+
 // GetByIdOrderItem godoc
 // @ID get_by_id_OrderItem
 // @Router /order_items/{id} [GET]
@@ -107,6 +260,16 @@ func (h *Handler) GetByIdOrderItem(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting OrderItem", http.StatusInternalServerError, err.Error())
 		return
 	}
+	owned, err := h.ownsOrder(c, orderItem.OrderId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !owned {
+		h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
+		return
+	}
+	setETag(c, orderItem.Version)
 	h.handlerResponse(c, "OrderItem successfully retrieved", http.StatusOK, orderItem)
 }
 
@@ -118,6 +281,10 @@ func (h *Handler) GetByIdOrderItem(c *gin.Context) {
 // @Tags OrderItem
 // @Accept json
 // @Procedure jsonUser
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[created_at][gte]=2024-01-01"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
@@ -132,9 +299,22 @@ func (h *Handler) GetListOrderItems(c *gin.Context) {
 		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
 		return
 	}
+	var userId = c.Query("user_id")
+	if !h.isStaff(c) {
+		userId = c.GetString("user_id")
+	}
+	list, ok := h.listQuery(c, orderItemList)
+	if !ok {
+		return
+	}
 	resp, err := h.strg.OrderItem().GetList(c.Request.Context(), &models.OrderItemGetListRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:  offset,
+		Limit:   limit,
+		UserId:  userId,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
 	})
 	if err != nil {
 		h.handlerResponse(c, "Error while getting OrderItems", http.StatusInternalServerError, err.Error())
@@ -152,8 +332,12 @@ func (h *Handler) GetListOrderItems(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Response 409 {object} Response{data=string} "Conflict"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) DeleteOrderItem(c *gin.Context) {
 	var id = c.Param("id")
@@ -162,7 +346,12 @@ func (h *Handler) DeleteOrderItem(c *gin.Context) {
 		return
 	}
 
-	_, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: id})
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	orderItem, err := h.strg.OrderItem().GetById(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
 			h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
@@ -171,12 +360,85 @@ func (h *Handler) DeleteOrderItem(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting OrderItem", http.StatusInternalServerError, err.Error())
 		return
 	}
+	owned, err := h.ownsOrder(c, orderItem.OrderId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !owned {
+		h.handlerResponse(c, "OrderItem does not exist", http.StatusNotFound, nil)
+		return
+	}
 
-	err = h.strg.OrderItem().Delete(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: id})
+	err = h.strg.OrderItem().Delete(c.Request.Context(), &models.OrderItemPrimaryKey{ItemId: id, Version: version, UserId: c.GetString("user_id")})
 	if err != nil {
-		h.handlerResponse(c, "Error while deleting OrderItem", http.StatusInternalServerError, err.Error())
+		h.orderItemError(c, err, "Error while deleting OrderItem")
 		return
 	}
 
 	h.handlerResponse(c, "OrderItem deleted successfully", http.StatusOK, nil)
 }
+
+// orderItemError answers for an item write the storage refused. The items of
+// an order that has left pending are fixed, and stock is reserved as they
+// change, so either can fail it
+func (h *Handler) orderItemError(c *gin.Context, err error, message string) {
+	switch {
+	case h.versionConflict(c, err, "OrderItem"):
+	case errors.Is(err, storage.ErrOrderNotPending):
+		h.handlerResponse(c, "Order is no longer pending, its items cannot change", http.StatusConflict, err.Error())
+	case errors.Is(err, storage.ErrInsufficientStock):
+		h.handlerResponse(c, "Not enough stock", http.StatusConflict, err.Error())
+	case errors.Is(err, storage.ErrBookNotFound):
+		h.handlerResponse(c, "Book does not exist", http.StatusBadRequest, err.Error())
+	case err.Error() == fmt.Errorf("no rows in result set").Error():
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+	default:
+		h.handlerResponse(c, message, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// checkQuantity defaults a missing quantity to one and answers 400 for a
+// negative one
+func (h *Handler) checkQuantity(c *gin.Context, quantity *int) bool {
+	if *quantity == 0 {
+		*quantity = 1
+	}
+	if *quantity < 1 {
+		h.handlerResponse(c, "Quantity is not valid", http.StatusBadRequest, "quantity must be at least 1")
+		return false
+	}
+	return true
+}
+
+// priceOrderItem returns the book an item of orderId is being set to, whose
+// price and currency the item takes. It answers 400 unless the book exists and
+// is priced in the currency of the order's other items
+func (h *Handler) priceOrderItem(c *gin.Context, orderId, itemId, bookId string) (*models.Book, bool) {
+	if _, err := uuid.Parse(bookId); err != nil {
+		h.handlerResponse(c, "Book id is not valid", http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	book, err := h.strg.Books().GetById(c.Request.Context(), &models.BookPrimaryKey{Id: bookId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Book does not exist", http.StatusBadRequest, bookId)
+			return nil, false
+		}
+		h.handlerResponse(c, "Error while getting Book", http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	items, err := h.orderItems(c, orderId)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order items", http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	for _, item := range items {
+		if item.ItemId != itemId && item.Currency != book.Currency {
+			h.handlerResponse(c, "Books are priced in different currencies, order them separately", http.StatusBadRequest, bookId)
+			return nil, false
+		}
+	}
+	return book, true
+}
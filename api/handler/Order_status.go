@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/storage"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+)
+
+// TransitionOrder godoc
+// @ID transition_order
+// @Router /orders/{id}/{action} [POST]
+// @Summary Transition Order
+// @Description Move an order along its lifecycle: pay, ship, deliver, cancel or refund. Shipping sells the reserved stock, cancelling and refunding release it. Customers may only cancel their own pending orders
+// @Tags Order
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param action path string true "pay, ship, deliver, cancel or refund"
+// @Param transition body models.OrderTransition false "OrderTransitionRequest"
+// @Param If-Match header string false "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) TransitionOrder(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.OrderTransition
+		err := c.ShouldBindJSON(&req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+			return
+		}
+		version, ok := h.ifMatch(c)
+		if !ok {
+			return
+		}
+		req.OrderId, req.Status, req.Version = c.Param("id"), status, version
+		req.UserId = c.GetString("user_id")
+		if _, err := uuid.Parse(req.OrderId); err != nil {
+			h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		order, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: req.OrderId})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+				return
+			}
+			h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !h.canAccessOrder(c, order) {
+			h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+			return
+		}
+		if !h.isStaff(c) && (status != models.OrderStatusCancelled || order.Status != models.OrderStatusPending) {
+			h.handlerResponse(c, "Forbidden", http.StatusForbidden, "Only pending orders can be cancelled by their owner")
+			return
+		}
+
+		affected, err := h.strg.Order().Transition(c.Request.Context(), &req)
+		if err != nil {
+			if h.versionConflict(c, err, "Order") {
+				return
+			}
+			switch {
+			case errors.Is(err, storage.ErrInvalidTransition):
+				h.handlerResponse(c, "Order cannot be moved to "+status, http.StatusConflict, err.Error())
+			case errors.Is(err, storage.ErrInsufficientStock):
+				h.handlerResponse(c, "Not enough stock", http.StatusConflict, err.Error())
+			default:
+				h.handlerResponse(c, "Error while updating Order", http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+		if affected == 0 {
+			h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+			return
+		}
+
+		resp, err := h.strg.Order().GetById(c.Request.Context(), &models.OrderPrimaryKey{OrderId: req.OrderId})
+		if err != nil {
+			h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+			return
+		}
+		setETag(c, resp.Version)
+		h.handlerResponse(c, "Order successfully "+status, http.StatusOK, resp)
+	}
+}
+
+// GetOrderHistory godoc
+// @ID get_order_history
+// @Router /orders/{id}/history [GET]
+// @Summary Get Order History
+// @Description Get the status changes of an order, oldest first
+// @Tags Order
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=[]models.OrderStatusChange} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetOrderHistory(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	owned, err := h.ownsOrder(c, id)
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !owned {
+		h.handlerResponse(c, "Order does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	history, err := h.strg.Order().GetHistory(c.Request.Context(), &models.OrderPrimaryKey{OrderId: id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Order history", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Order history successfully retrieved", http.StatusOK, history)
+}
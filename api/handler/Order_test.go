@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"app/api/models"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOrderTotalsFrozenOnceNotPending(t *testing.T) {
+	s := newTestServer(t)
+	bookId := s.book(1000, 10)
+
+	var order models.Order
+	s.expect(http.StatusCreated, "POST", "/orders", models.CreateOrder{UserId: s.userId}, &order)
+	var item models.OrderItem
+	s.expect(http.StatusCreated, "POST", "/order_items", models.CreateOrderItem{OrderId: order.OrderId, BookId: bookId, Quantity: 2}, &item)
+	s.expectWith(http.StatusOK, "PATCH", "/orders/"+order.OrderId, map[string]int64{"discount": 300}, ifMatch(0), nil)
+	s.expect(http.StatusOK, "POST", "/orders/"+order.OrderId+"/pay", nil, nil)
+
+	want := models.Order{Currency: "USD", Subtotal: 2000, Discount: 300, TaxRate: 825, Tax: 140, Total: 1840}
+	check := func(when string) {
+		t.Helper()
+		var got models.Order
+		s.expect(http.StatusOK, "GET", "/orders/"+order.OrderId, nil, &got)
+		if got.Currency != want.Currency || got.Subtotal != want.Subtotal || got.Discount != want.Discount ||
+			got.TaxRate != want.TaxRate || got.Tax != want.Tax || got.Total != want.Total {
+			t.Fatalf("%s: totals %s %d-%d+%d=%d at %d, want %s %d-%d+%d=%d at %d", when,
+				got.Currency, got.Subtotal, got.Discount, got.Tax, got.Total, got.TaxRate,
+				want.Currency, want.Subtotal, want.Discount, want.Tax, want.Total, want.TaxRate)
+		}
+	}
+	check("paid")
+
+	s.expectWith(http.StatusConflict, "PATCH", "/order_items/"+item.ItemId, map[string]int{"quantity": 5}, ifMatch(0), nil)
+	s.expectWith(http.StatusConflict, "DELETE", "/order_items/"+item.ItemId, nil, ifMatch(0), nil)
+	s.expect(http.StatusConflict, "POST", "/order_items", models.CreateOrderItem{OrderId: order.OrderId, BookId: bookId, Quantity: 1}, nil)
+	s.expectWith(http.StatusConflict, "PATCH", "/orders/"+order.OrderId, map[string]int64{"discount": 0}, ifMatch(0), nil)
+	s.expectWith(http.StatusCreated, "PUT", "/orders", models.UpdateOrder{OrderId: order.OrderId, UserId: s.userId, Discount: 300}, ifMatch(0), nil)
+	check("after edits")
+
+	price := int64(5000)
+	_, err := s.strg.Books().Patch(context.Background(), &models.PatchBook{Id: bookId, Price: &price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusOK, "POST", "/orders/"+order.OrderId+"/ship", nil, nil)
+	check("shipped after a price change")
+}
+
+func TestCreateOrderDefaultsToCaller(t *testing.T) {
+	s := newTestServer(t)
+	customerId := uuid.New().String()
+
+	for _, tc := range []struct {
+		role, userId, want string
+	}{
+		{models.RoleStaff, "", s.userId},
+		{models.RoleStaff, customerId, customerId},
+		{models.RoleCustomer, "", s.userId},
+		{models.RoleCustomer, customerId, s.userId},
+	} {
+		s.role = tc.role
+		var order models.Order
+		s.expect(http.StatusCreated, "POST", "/orders", models.CreateOrder{UserId: tc.userId}, &order)
+		if order.UserId != tc.want {
+			t.Errorf("%s ordering for %q got an order of %q, want %q", tc.role, tc.userId, order.UserId, tc.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/pkg/logger"
+	"app/pkg/mailer"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const passwordResetCodeLength = 6
+
+// ForgotPassword godoc
+// @ID forgot_password
+// @Router /password/forgot [POST]
+// @Summary Forgot Password
+// @Description Email a one-time password reset code to the user
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param forgot body models.ForgotPasswordRequest true "ForgotPasswordRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the response never tells whether the account exists or has an email
+	const sent = "If the account exists, a reset code has been sent to its email"
+
+	ctx := c.Request.Context()
+	user, err := h.strg.Users().GetById(ctx, &models.UserPrimaryKey{Username: req.Username})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, sent, http.StatusOK, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.Email == "" {
+		h.handlerResponse(c, sent, http.StatusOK, nil)
+		return
+	}
+
+	issued, err := h.strg.PasswordReset().CountSince(ctx, &models.PasswordResetCountRequest{
+		UserId: user.Id,
+		Since:  time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while checking reset codes", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if issued >= h.cfg.PasswordResetMaxPerHour {
+		h.logger.Warn("password reset rate limited", logger.String("user_id", user.Id))
+		h.handlerResponse(c, sent, http.StatusOK, nil)
+		return
+	}
+
+	code, err := helper.GenerateOTP(passwordResetCodeLength)
+	if err != nil {
+		h.handlerResponse(c, "Error while generating code", http.StatusInternalServerError, err.Error())
+		return
+	}
+	codeHash, err := helper.HashPassword(code)
+	if err != nil {
+		h.handlerResponse(c, "Error hashing code", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, err = h.strg.PasswordReset().Create(ctx, &models.CreatePasswordReset{
+		UserId:    user.Id,
+		CodeHash:  codeHash,
+		ExpiresAt: time.Now().Add(h.cfg.PasswordResetTTL),
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while creating reset code", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	msg, err := mailer.Render(user.Email, "password_reset", map[string]interface{}{
+		"Username":  user.Username,
+		"Code":      code,
+		"ExpiresIn": h.cfg.PasswordResetTTL,
+	})
+	if err == nil {
+		err = h.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		h.logger.Error("password reset mail", logger.String("user_id", user.Id), logger.Error(err))
+	}
+
+	h.handlerResponse(c, sent, http.StatusOK, nil)
+}
+
+// ResetPassword godoc
+// @ID reset_password
+// @Router /password/reset [POST]
+// @Summary Reset Password
+// @Description Set a new password using the emailed code and log out every session
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param reset body models.ResetPasswordRequest true "ResetPasswordRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 429 {object} Response{data=string} "Too Many Requests"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	const invalid = "Invalid or expired code"
+
+	ctx := c.Request.Context()
+	user, err := h.strg.Users().GetById(ctx, &models.UserPrimaryKey{Username: req.Username})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+			return
+		}
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !h.checkPassword(c, req.NewPassword, user.Username) {
+		return
+	}
+
+	reset, err := h.strg.PasswordReset().GetPending(ctx, &models.PasswordResetPrimaryKey{UserId: user.Id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+			return
+		}
+		h.handlerResponse(c, "Error while getting reset code", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the guess is counted before the comparison so concurrent guesses cannot exceed the cap
+	attempt := &models.PasswordResetAttempt{Id: reset.Id, MaxAttempts: h.cfg.PasswordResetMaxAttempts}
+	_, err = h.strg.PasswordReset().AddAttempt(ctx, attempt)
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Too many attempts, request a new code", http.StatusTooManyRequests, invalid)
+			return
+		}
+		h.handlerResponse(c, "Error while checking reset code", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !helper.CheckPasswordHash(req.Code, reset.CodeHash) {
+		h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+		return
+	}
+
+	consumed, err := h.strg.PasswordReset().Consume(ctx, attempt)
+	if err != nil {
+		h.handlerResponse(c, "Error while using reset code", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if consumed == 0 {
+		h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+		return
+	}
+
+	hashedPassword, err := helper.HashPassword(req.NewPassword)
+	if err != nil {
+		h.handlerResponse(c, "Error hashing password", http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = h.strg.Users().UpdatePassword(ctx, &models.UpdatePassword{Id: user.Id, Password: hashedPassword})
+	if err != nil {
+		h.handlerResponse(c, "Error while updating password", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = h.logoutEverywhere(ctx, user.Id)
+	if err != nil {
+		h.handlerResponse(c, "Error while revoking tokens", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Password successfully reset", http.StatusOK, nil)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resetCode creates reader1 with a pending reset code that expires after ttl
+func (s *testServer) resetCode(code string, ttl time.Duration) string {
+	s.t.Helper()
+	ctx := context.Background()
+	userId, err := s.strg.Users().Create(ctx, &models.CreateUser{Username: "reader1", Email: "reader1@example.com", Role: models.RoleCustomer})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	hash, err := helper.HashPassword(code)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	_, err = s.strg.PasswordReset().Create(ctx, &models.CreatePasswordReset{UserId: userId, CodeHash: hash, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return userId
+}
+
+func resetRequest(code string) models.ResetPasswordRequest {
+	return models.ResetPasswordRequest{Username: "reader1", Code: code, NewPassword: "N3w!passphrase"}
+}
+
+func TestResetPasswordCodeIsSingleUse(t *testing.T) {
+	s := newTestServer(t)
+	s.resetCode("123456", time.Minute)
+
+	s.expect(http.StatusOK, "POST", "/password/reset", resetRequest("123456"), nil)
+	s.expect(http.StatusBadRequest, "POST", "/password/reset", resetRequest("123456"), nil)
+	s.expect(http.StatusCreated, "POST", "/login", models.UserLoginRequest{Username: "reader1", Password: "N3w!passphrase"}, nil)
+}
+
+func TestResetPasswordRejectsExpiredCode(t *testing.T) {
+	s := newTestServer(t)
+	s.resetCode("123456", -time.Second)
+
+	s.expect(http.StatusBadRequest, "POST", "/password/reset", resetRequest("123456"), nil)
+}
+
+func TestResetPasswordCapsConcurrentGuesses(t *testing.T) {
+	s := newTestServer(t)
+	userId := s.resetCode("123456", time.Minute)
+
+	const guesses = 20
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = map[int]int{}
+	)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := s.do("POST", "/password/reset", resetRequest("654321"), nil)
+			mu.Lock()
+			statuses[resp.Status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	limit := s.h.cfg.PasswordResetMaxAttempts
+	if statuses[http.StatusBadRequest] != limit || statuses[http.StatusTooManyRequests] != guesses-limit {
+		t.Errorf("statuses = %v, want %d wrong codes and %d refused", statuses, limit, guesses-limit)
+	}
+	reset, err := s.strg.PasswordReset().GetPending(context.Background(), &models.PasswordResetPrimaryKey{UserId: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.Attempts != limit {
+		t.Errorf("attempts = %d, want %d", reset.Attempts, limit)
+	}
+
+	// the right code no longer helps once the guesses are spent
+	s.expect(http.StatusTooManyRequests, "POST", "/password/reset", resetRequest("123456"), nil)
+}
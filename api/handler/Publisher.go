@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"app/api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// CreatePublisher godoc
+// @ID create_publisher
+// @Router /publishers [POST]
+// @Summary Create Publisher
+// @Description Create Publisher
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param publisher body models.CreatePublisher true "CreatePublisherRequest"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) CreatePublisher(c *gin.Context) {
+	var createPublisher *models.CreatePublisher
+	err := c.ShouldBindJSON(&createPublisher)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	if createPublisher.Name == "" {
+		h.handlerResponse(c, "Publisher name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	PublisherId, err := h.strg.Publisher().Create(c.Request.Context(), createPublisher)
+	if err != nil {
+		h.handlerResponse(c, "Error while creating Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	Publisher, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: PublisherId})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Publisher successfully created", http.StatusCreated, Publisher)
+}
+
+// UpdatePublisher godoc
+// @ID update_publisher
+// @Router /publishers [PUT]
+// @Summary Update Publisher
+// @Description Update Publisher
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param publisher body models.UpdatePublisher true "UpdatePublisherRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) UpdatePublisher(c *gin.Context) {
+	var publisher models.UpdatePublisher
+	err := c.ShouldBindJSON(&publisher)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	publisher.Version = version
+	if publisher.Name == "" {
+		h.handlerResponse(c, "Publisher name is required", http.StatusBadRequest, nil)
+		return
+	}
+	_, err = h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: publisher.Id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Publisher does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp, err := h.strg.Publisher().Update(c.Request.Context(), &publisher)
+	if err != nil {
+		if h.versionConflict(c, err, "Publisher") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Publisher successfully updated", http.StatusCreated, resp)
+}
+
+// PatchPublisher godoc
+// @ID patch_publisher
+// @Router /publishers/{id} [PATCH]
+// @Summary Patch Publisher
+// @Description Update only the supplied fields of a Publisher
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param publisher body models.PatchPublisher true "PatchPublisherRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.Publisher} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchPublisher(c *gin.Context) {
+	var publisher models.PatchPublisher
+	err := c.ShouldBindJSON(&publisher)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	publisher.Version = version
+	publisher.Id = c.Param("id")
+	if _, err := uuid.Parse(publisher.Id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+	if publisher.Name != nil && *publisher.Name == "" {
+		h.handlerResponse(c, "Publisher name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	affected, err := h.strg.Publisher().Patch(c.Request.Context(), &publisher)
+	if err != nil {
+		if h.versionConflict(c, err, "Publisher") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "Publisher does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	resp, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: publisher.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "Publisher successfully updated", http.StatusOK, resp)
+}
+
+// GetByIdPublisher godoc
+// @ID get_by_id_publisher
+// @Router /publishers/{id} [GET]
+// @Summary Get By ID Publisher
+// @Description Get By ID Publisher
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Publisher} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetByIdPublisher(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	publisher, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Publisher does not exist", http.StatusNotFound, err.Error())
+			return
+		}
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+	setETag(c, publisher.Version)
+	h.handlerResponse(c, "Publisher successfully retrieved", http.StatusOK, publisher)
+}
+
+// GetListPublishers godoc
+// @ID get_list_publisher
+// @Router /publishers [GET]
+// @Summary Get List Publishers
+// @Description Get List Publishers
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[name][like]=penguin"
+// @Param sort query string false "comma separated fields, - for descending; e.g. name"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.PublisherGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetListPublishers(c *gin.Context) {
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing offset", http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
+		return
+	}
+	list, ok := h.listQuery(c, publisherList)
+	if !ok {
+		return
+	}
+	resp, err := h.strg.Publisher().GetList(c.Request.Context(), &models.PublisherGetListRequest{
+		Offset:  offset,
+		Limit:   limit,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting Publishers", http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.handlerResponse(c, "Publisher successfully retrieved", http.StatusOK, resp)
+}
+
+// GetPublisherBooks godoc
+// @ID get_publisher_books
+// @Router /publishers/{id}/books [GET]
+// @Summary Get Publisher Books
+// @Description Get the books of a publisher
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[lang]=en"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at,title"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.BookGetListResponse} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) GetPublisherBooks(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Publisher does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.listBooks(c, models.Filter{Field: "publisher_id", Op: models.FilterEq, Value: id})
+}
+
+// DeletePublisher godoc
+// @ID delete_publisher
+// @Router /publishers/{id} [DELETE]
+// @Summary Delete Publisher
+// @Description Delete a Publisher that has no books
+// @Tags Publisher
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Conflict"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) DeletePublisher(c *gin.Context) {
+	var id = c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
+	_, err := h.strg.Publisher().GetById(c.Request.Context(), &models.PublisherPrimaryKey{Id: id})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, "Publisher does not exist", http.StatusNotFound, nil)
+			return
+		}
+		h.handlerResponse(c, "Error while getting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if used, ok := h.hasBooks(c, models.Filter{Field: "publisher_id", Op: models.FilterEq, Value: id}); !ok {
+		return
+	} else if used {
+		h.handlerResponse(c, "Publisher has books, assign them another publisher first", http.StatusConflict, nil)
+		return
+	}
+
+	err = h.strg.Publisher().Delete(c.Request.Context(), &models.PublisherPrimaryKey{Id: id, Version: version})
+	if err != nil {
+		if h.versionConflict(c, err, "Publisher") {
+			return
+		}
+		h.handlerResponse(c, "Error while deleting Publisher", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Publisher deleted successfully", http.StatusOK, nil)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"testing"
+)
+
+func TestBookPublisher(t *testing.T) {
+	s := newTestServer(t)
+	var publisher models.Publisher
+	s.expect(http.StatusCreated, "POST", "/publishers", models.CreatePublisher{Name: "Penguin"}, &publisher)
+
+	s.expect(http.StatusBadRequest, "POST", "/books", models.CreateBook{Title: "Emma", Price: 1000, Currency: "USD", PublisherId: s.userId}, nil)
+	var book models.Book
+	s.expect(http.StatusCreated, "POST", "/books", models.CreateBook{Title: "Emma", Price: 1000, Currency: "USD", PublisherId: publisher.Id}, &book)
+	if book.Publisher != "Penguin" || book.PublisherId != publisher.Id {
+		t.Fatalf("book publisher %q %q, want Penguin", book.Publisher, book.PublisherId)
+	}
+
+	var list models.BookGetListResponse
+	s.expect(http.StatusOK, "GET", "/publishers/"+publisher.Id+"/books", nil, &list)
+	if len(list.Books) != 1 || list.Books[0].Id != book.Id {
+		t.Fatalf("publisher's books = %d, want the book", len(list.Books))
+	}
+
+	name := "Penguin Classics"
+	s.expectWith(http.StatusOK, "PATCH", "/publishers/"+publisher.Id, models.PatchPublisher{Name: &name}, ifMatch(publisher.Version), nil)
+	s.expect(http.StatusOK, "GET", "/books/"+book.Id, nil, &book)
+	if book.Publisher != name {
+		t.Errorf("book publisher after the rename = %q, want %q", book.Publisher, name)
+	}
+
+	s.expectWith(http.StatusConflict, "DELETE", "/publishers/"+publisher.Id, nil, ifMatch(0), nil)
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"app/api/models"
+	"app/pkg/helper"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
+	"strings"
 )
 
 // CreateUser godoc
@@ -17,7 +19,7 @@ import (
 // @Accept json
 // @Procedure json
 // @Param user body models.CreateUser true "CreateUserRequest"
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Success 200 {object} Response{data=models.PrivateUser} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) CreateUser(c *gin.Context) {
@@ -28,6 +30,26 @@ func (h *Handler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.checkPassword(c, createUser.Password, createUser.Username) {
+		return
+	}
+	hashedPassword, err := helper.HashPassword(createUser.Password)
+	if err != nil {
+		h.handlerResponse(c, "Error hashing password", http.StatusInternalServerError, err.Error())
+		return
+	}
+	createUser.Password = hashedPassword
+
+	createUser.Email = strings.ToLower(strings.TrimSpace(createUser.Email))
+	if createUser.Email != "" && !helper.IsValidEmail(createUser.Email) {
+		h.handlerResponse(c, "Email is not valid", http.StatusBadRequest, "Email is not valid")
+		return
+	}
+	if createUser.Role != "" && !models.IsValidRole(createUser.Role) {
+		h.handlerResponse(c, "Role is not valid", http.StatusBadRequest, "role must be one of customer, staff, admin")
+		return
+	}
+
 	UserId, err := h.strg.Users().Create(c.Request.Context(), createUser)
 	if err != nil {
 		h.handlerResponse(c, "Error while creating User", http.StatusInternalServerError, err.Error())
@@ -40,7 +62,7 @@ func (h *Handler) CreateUser(c *gin.Context) {
 
 	}
 
-	h.handlerResponse(c, "User successfully created", http.StatusCreated, User)
+	h.handlerResponse(c, "User successfully created", http.StatusCreated, privateUser(User))
 }
 
 // UpdateUser godoc
@@ -51,9 +73,13 @@ func (h *Handler) CreateUser(c *gin.Context) {
 // @Tags User
 // @Accept json
 // @Procedure json
-// @Param user body models.User true "UpdateUserRequest"
+// @Param user body models.UpdateUser true "UpdateUserRequest"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 409 {object} Response{data=string} "Email already in use"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) UpdateUser(c *gin.Context) {
 	var user models.UpdateUser
@@ -62,7 +88,12 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
 		return
 	}
-	_, err = h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: user.Id})
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	user.Version = version
+	existing, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: user.Id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
 			h.handlerResponse(c, "User does not exist", http.StatusNotFound, nil)
@@ -71,25 +102,146 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if user.Role == "" {
+		user.Role = existing.Role
+	}
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	if user.Email != "" && !helper.IsValidEmail(user.Email) {
+		h.handlerResponse(c, "Email is not valid", http.StatusBadRequest, "Email is not valid")
+		return
+	}
+	if !models.IsValidRole(user.Role) {
+		h.handlerResponse(c, "Role is not valid", http.StatusBadRequest, "role must be one of customer, staff, admin")
+		return
+	}
+	emailChanged := user.Email != "" && user.Email != existing.Email
+	if emailChanged && !h.checkEmailFree(c, user.Email, user.Id) {
+		return
+	}
 	resp, err := h.strg.Users().Update(c.Request.Context(), &user)
 	if err != nil {
+		if h.versionConflict(c, err, "User") {
+			return
+		}
 		h.handlerResponse(c, "Error while updating User", http.StatusInternalServerError, err.Error())
 		return
 	}
+	if emailChanged && resp > 0 {
+		updated, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: user.Id})
+		if err != nil {
+			h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+			return
+		}
+		h.reverify(c.Request.Context(), updated)
+	}
 	h.handlerResponse(c, "User successfully updated", http.StatusCreated, resp)
 }
 
+// PatchUser godoc
+// @ID patch_user
+// @Router /users/{id} [PATCH]
+// @Summary Patch User
+// @Description Update only the supplied fields of a User. Passwords are changed through /me/password or a reset
+// @Tags User
+// @Accept json
+// @Procedure json
+// @Param id path string true "id"
+// @Param user body models.PatchUser true "PatchUserRequest"
+// @Param If-Match header string true "ETag from a previous GET"
+// @Success 200 {object} Response{data=models.PrivateUser} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
+// @Response 409 {object} Response{data=string} "Email already in use"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) PatchUser(c *gin.Context) {
+	var user models.PatchUser
+	err := c.ShouldBindJSON(&user)
+	if err != nil {
+		h.handlerResponse(c, "JSON format is not valid", http.StatusBadRequest, err.Error())
+		return
+	}
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+	user.Version = version
+	user.Id = c.Param("id")
+	if _, err := uuid.Parse(user.Id); err != nil {
+		h.handlerResponse(c, "Bad Request", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if user.Username != nil && !helper.IsValidLogin(*user.Username) {
+		h.handlerResponse(c, "Username is not valid", http.StatusBadRequest, "Username is not valid")
+		return
+	}
+	emailChanged := false
+	if user.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*user.Email))
+		if email != "" && !helper.IsValidEmail(email) {
+			h.handlerResponse(c, "Email is not valid", http.StatusBadRequest, "Email is not valid")
+			return
+		}
+		user.Email = &email
+
+		existing, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: user.Id})
+		if err != nil {
+			if err.Error() == fmt.Errorf("no rows in result set").Error() {
+				h.handlerResponse(c, "User does not exist", http.StatusNotFound, nil)
+				return
+			}
+			h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+			return
+		}
+		emailChanged = email != "" && email != existing.Email
+		if emailChanged && !h.checkEmailFree(c, email, user.Id) {
+			return
+		}
+	}
+	if user.Role != nil && !models.IsValidRole(*user.Role) {
+		h.handlerResponse(c, "Role is not valid", http.StatusBadRequest, "role must be one of customer, staff, admin")
+		return
+	}
+
+	affected, err := h.strg.Users().Patch(c.Request.Context(), &user)
+	if err != nil {
+		if h.versionConflict(c, err, "User") {
+			return
+		}
+		h.handlerResponse(c, "Error while updating User", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if affected == 0 {
+		h.handlerResponse(c, "User does not exist", http.StatusNotFound, nil)
+		return
+	}
+
+	resp, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: user.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if emailChanged {
+		h.reverify(c.Request.Context(), resp)
+	}
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "User successfully updated", http.StatusOK, privateUser(resp))
+}
+
 // GetByIdUser godoc
 // @ID get_by_id_user
 // @Router /users/{id} [GET]
 // @Summary Get By ID User
-// @Description Get By ID User
+// @Description Get By ID User. Only admins and the user itself see the private profile
 // @Tags User
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Success 200 {object} Response{data=models.PrivateUser} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 404 {object} Response{data=string} "Not Found"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) GetByIdUser(c *gin.Context) {
 	var id = c.Param("id")
@@ -107,7 +259,12 @@ func (h *Handler) GetByIdUser(c *gin.Context) {
 		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
 		return
 	}
-	h.handlerResponse(c, "User successfully retrieved", http.StatusOK, user)
+	if c.GetString("role") != models.RoleAdmin && c.GetString("user_id") != user.Id {
+		h.handlerResponse(c, "User successfully retrieved", http.StatusOK, publicUser(user))
+		return
+	}
+	setETag(c, user.Version)
+	h.handlerResponse(c, "User successfully retrieved", http.StatusOK, privateUser(user))
 }
 
 // GetListUsers godoc
@@ -118,7 +275,11 @@ func (h *Handler) GetByIdUser(c *gin.Context) {
 // @Tags User
 // @Accept json
 // @Procedure jsonUser
-// @Success 200 {object} Response{data=string} "Success Request"
+// @Param filter query string false "filter[field][op]=value, op defaults to eq; e.g. filter[created_at][gte]=2024-01-01"
+// @Param sort query string false "comma separated fields, - for descending; e.g. -created_at"
+// @Param cursor query string false "next_cursor or prev_cursor of a previous page, instead of offset"
+// @Param count query bool false "include the total count, defaults to true without a cursor"
+// @Success 200 {object} Response{data=models.PrivateUserGetListResponse} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) GetListUsers(c *gin.Context) {
@@ -132,15 +293,23 @@ func (h *Handler) GetListUsers(c *gin.Context) {
 		h.handlerResponse(c, "Error while parsing limit", http.StatusBadRequest, err.Error())
 		return
 	}
+	list, ok := h.listQuery(c, userList)
+	if !ok {
+		return
+	}
 	resp, err := h.strg.Users().GetList(c.Request.Context(), &models.UserGetListRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:  offset,
+		Limit:   limit,
+		Filters: list.filters,
+		Sorts:   list.sorts,
+		Cursor:  list.cursor,
+		Count:   list.count,
 	})
 	if err != nil {
 		h.handlerResponse(c, "Error while getting Users", http.StatusInternalServerError, err.Error())
 		return
 	}
-	h.handlerResponse(c, "User successfully retrieved", http.StatusOK, resp)
+	h.handlerResponse(c, "User successfully retrieved", http.StatusOK, privateUsers(resp))
 }
 
 // DeleteUser godoc
@@ -152,8 +321,11 @@ func (h *Handler) GetListUsers(c *gin.Context) {
 // @Accept json
 // @Procedure json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from a previous GET"
 // @Success 200 {object} Response{data=string} "Success Request"
 // @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 412 {object} Response{data=string} "Precondition Failed"
+// @Response 428 {object} Response{data=string} "Precondition Required"
 // @Failure 500 {object} Response{data=string} "Server error"
 func (h *Handler) DeleteUser(c *gin.Context) {
 	var id = c.Param("id")
@@ -162,6 +334,11 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	version, ok := h.ifMatch(c)
+	if !ok {
+		return
+	}
+
 	_, err := h.strg.Users().GetById(c.Request.Context(), &models.UserPrimaryKey{Id: id})
 	if err != nil {
 		if err.Error() == fmt.Errorf("no rows in result set").Error() {
@@ -172,8 +349,11 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err = h.strg.Users().Delete(c.Request.Context(), &models.UserPrimaryKey{Id: id})
+	err = h.strg.Users().Delete(c.Request.Context(), &models.UserPrimaryKey{Id: id, Version: version})
 	if err != nil {
+		if h.versionConflict(c, err, "User") {
+			return
+		}
 		h.handlerResponse(c, "Error while deleting User", http.StatusInternalServerError, err.Error())
 		return
 	}
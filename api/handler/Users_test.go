@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"app/api/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGetUserHidesPrivateFieldsFromOthers(t *testing.T) {
+	s := newTestServer(t)
+	userId, err := s.strg.Users().Create(context.Background(), &models.CreateUser{
+		Username: "reader1",
+		Email:    "reader1@example.com",
+		Password: "hash",
+		CardNo:   "4111111111111111",
+		Role:     models.RoleCustomer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := func() map[string]json.RawMessage {
+		t.Helper()
+		var user map[string]json.RawMessage
+		s.expect(http.StatusOK, "GET", "/users/"+userId, nil, &user)
+		if _, ok := user["password"]; ok {
+			t.Fatal("user response carries the password hash")
+		}
+		return user
+	}
+
+	s.role = models.RoleCustomer
+	if user := fields(); user["email"] != nil || user["card_no"] != nil || string(user["username"]) != `"reader1"` {
+		t.Errorf("another customer sees %v, want the public profile", user)
+	}
+
+	s.userId = userId
+	if user := fields(); string(user["email"]) != `"reader1@example.com"` || string(user["card_no"]) != `"************1111"` {
+		t.Errorf("the user itself sees %v, want the private profile", user)
+	}
+
+	s.userId, s.role = "", models.RoleAdmin
+	if user := fields(); string(user["email"]) != `"reader1@example.com"` {
+		t.Errorf("an admin sees %v, want the private profile", user)
+	}
+}
+
+func TestPatchUserKeepsOmittedFields(t *testing.T) {
+	s := newTestServer(t)
+	userId, err := s.strg.Users().Create(context.Background(), &models.CreateUser{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Username:  "reader1",
+		Email:     "reader1@example.com",
+		Password:  "hash",
+		Phone:     "+15550100",
+		Role:      models.RoleCustomer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastName := "King"
+	s.expectWith(http.StatusOK, "PATCH", "/users/"+userId, models.PatchUser{LastName: &lastName}, ifMatch(0), nil)
+
+	user, err := s.strg.Users().GetById(context.Background(), &models.UserPrimaryKey{Id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.LastName != "King" || user.FirstName != "Ada" || user.Email != "reader1@example.com" || user.Phone != "+15550100" ||
+		user.Password != "hash" || user.Role != models.RoleCustomer {
+		t.Errorf("user after patching the last name = %+v, want only the last name changed", user)
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/pkg/mailer"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail godoc
+// @ID verify_email
+// @Router /verify-email [GET]
+// @Summary Verify Email
+// @Description Confirm the email address using the token from the verification link
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Param token query string true "token"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var token = c.Query("token")
+
+	const invalid = "Invalid or expired verification link"
+
+	if token == "" {
+		h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+		return
+	}
+
+	ctx := c.Request.Context()
+	verification, err := h.strg.EmailVerification().GetPending(ctx, &models.EmailVerificationPrimaryKey{TokenHash: helper.HashToken(token)})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+			return
+		}
+		h.handlerResponse(c, "Error while getting verification", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	user, err := h.strg.Users().GetById(ctx, &models.UserPrimaryKey{Id: verification.UserId})
+	if err != nil {
+		if err.Error() == fmt.Errorf("no rows in result set").Error() {
+			h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+			return
+		}
+		h.handlerResponse(c, "Error while getting User", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.Email != verification.Email {
+		// the address changed after the link was sent
+		h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+		return
+	}
+
+	consumed, err := h.strg.EmailVerification().Consume(ctx, &models.EmailVerificationPrimaryKey{Id: verification.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while using verification", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if consumed == 0 {
+		h.handlerResponse(c, invalid, http.StatusBadRequest, invalid)
+		return
+	}
+
+	_, err = h.strg.Users().Verify(ctx, &models.UserPrimaryKey{Id: user.Id})
+	if err != nil {
+		h.handlerResponse(c, "Error while verifying User", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Email successfully verified", http.StatusOK, nil)
+}
+
+// ResendVerification godoc
+// @ID resend_verification
+// @Router /verify-email/resend [POST]
+// @Summary Resend Verification
+// @Description Send a new verification link to the current user's email
+// @Tags Auth
+// @Accept json
+// @Procedure json
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=string} "Bad Request"
+// @Response 429 {object} Response{data=string} "Too Many Requests"
+// @Failure 500 {object} Response{data=string} "Server error"
+func (h *Handler) ResendVerification(c *gin.Context) {
+	var user = h.currentUser(c)
+
+	if user.IsVerified {
+		h.handlerResponse(c, "Email already verified", http.StatusBadRequest, nil)
+		return
+	}
+	if user.Email == "" {
+		h.handlerResponse(c, "User has no email", http.StatusBadRequest, nil)
+		return
+	}
+
+	sent, err := h.strg.EmailVerification().CountSince(c.Request.Context(), &models.EmailVerificationCountRequest{
+		UserId: user.Id,
+		Since:  time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		h.handlerResponse(c, "Error while checking verifications", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sent >= h.cfg.EmailVerificationMaxPerHour {
+		h.handlerResponse(c, "Too many verification emails, try again later", http.StatusTooManyRequests, nil)
+		return
+	}
+
+	err = h.sendVerification(c.Request.Context(), user)
+	if err != nil {
+		h.handlerResponse(c, "Error while sending verification", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, "Verification email sent", http.StatusOK, nil)
+}
+
+// sendVerification stores a fresh verification token for user and emails the link
+func (h *Handler) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = h.strg.EmailVerification().Create(ctx, &models.CreateEmailVerification{
+		UserId:    user.Id,
+		Email:     user.Email,
+		TokenHash: helper.HashToken(token),
+		ExpiresAt: time.Now().Add(h.cfg.EmailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.PublicURL + "/verify-email?token=" + url.QueryEscape(token)
+	msg, err := mailer.Render(user.Email, "email_verification", map[string]interface{}{
+		"Username":  user.Username,
+		"Link":      link,
+		"ExpiresIn": h.cfg.EmailVerificationTTL,
+	})
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, msg)
+}
+
+// canOrder reports whether the current user may place orders, which
+// requires a verified email when REQUIRE_VERIFIED_EMAIL is set
+func (h *Handler) canOrder(c *gin.Context) bool {
+	return !h.cfg.RequireVerifiedEmail || h.isStaff(c) || h.currentUser(c).IsVerified
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"testing"
+)
+
+func TestUnverifiedCustomerCannotOrder(t *testing.T) {
+	s := newTestServer(t)
+	s.h.cfg.RequireVerifiedEmail = true
+	s.role = models.RoleCustomer
+	bookId := s.book(1000, 10)
+
+	var order models.Order
+	s.expect(http.StatusCreated, "POST", "/orders", models.CreateOrder{}, &order)
+
+	s.verified = false
+	s.expect(http.StatusForbidden, "POST", "/orders", models.CreateOrder{}, nil)
+	s.expect(http.StatusForbidden, "POST", "/order_items", models.CreateOrderItem{OrderId: order.OrderId, BookId: bookId, Quantity: 1}, nil)
+	s.expect(http.StatusOK, "POST", "/cart/items", models.CartItemRequest{BookId: bookId, Quantity: 2}, nil)
+	s.expect(http.StatusForbidden, "POST", "/cart/checkout", nil, nil)
+	if stock, reserved := s.stock(bookId); stock != 10 || reserved != 0 {
+		t.Fatalf("stock %d reserved %d, want nothing reserved", stock, reserved)
+	}
+
+	// staff place orders for customers whatever their own email state
+	s.role = models.RoleStaff
+	s.expect(http.StatusCreated, "POST", "/orders", models.CreateOrder{UserId: s.userId}, nil)
+}
@@ -3,16 +3,23 @@ package handler
 import (
 	"app/config"
 	"app/pkg/logger"
+	"app/pkg/mailer"
+	"app/pkg/password"
 	"app/storage"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"gopkg.in/gomail.v2"
+	"net/http"
 	"strconv"
+	"strings"
 )
 
 type Handler struct {
 	cfg    *config.Config
 	logger logger.LoggerI
 	strg   storage.StorageInterface
+	mailer mailer.Mailer
+	policy password.Policy
 }
 
 type Response struct {
@@ -21,11 +28,13 @@ type Response struct {
 	Data        interface{} `json:"data"`
 }
 
-func NewHandler(cfg *config.Config, storage storage.StorageInterface, logger logger.LoggerI) *Handler {
+func NewHandler(cfg *config.Config, storage storage.StorageInterface, mailer mailer.Mailer, logger logger.LoggerI) *Handler {
 	return &Handler{
 		cfg:    cfg,
 		logger: logger,
 		strg:   storage,
+		mailer: mailer,
+		policy: password.NewPolicy(cfg),
 	}
 }
 
@@ -35,16 +44,35 @@ func (h *Handler) getOffsetQuery(offset string) (int, error) {
 		return h.cfg.DefaultOffset, nil
 	}
 
-	return strconv.Atoi(offset)
+	value, err := strconv.Atoi(offset)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, errors.New("offset must not be negative")
+	}
+	return value, nil
 }
 
+// getLimitQuery parses limit, which must be positive and, when MaxLimit is
+// set, no larger than it
 func (h *Handler) getLimitQuery(limit string) (int, error) {
 
 	if len(limit) <= 0 {
 		return h.cfg.DefaultLimit, nil
 	}
 
-	return strconv.Atoi(limit)
+	value, err := strconv.Atoi(limit)
+	if err != nil {
+		return 0, err
+	}
+	if value < 1 {
+		return 0, errors.New("limit must be positive")
+	}
+	if h.cfg.MaxLimit > 0 && value > h.cfg.MaxLimit {
+		return 0, fmt.Errorf("limit must not exceed %d", h.cfg.MaxLimit)
+	}
+	return value, nil
 }
 
 func (h *Handler) handlerResponse(c *gin.Context, path string, code int, message interface{}) {
@@ -72,23 +100,62 @@ func (h *Handler) handlerResponse(c *gin.Context, path string, code int, message
 	c.JSON(code, response)
 }
 
-func (h *Handler) SendMessageToMail(subject string, sEmail string, sPassword string, To string, Message string) error {
-	// Set up the email message
-	messageBody := Message
+// checkPassword applies the password policy and writes the violations as a
+// bad request when password is rejected
+func (h *Handler) checkPassword(c *gin.Context, password string, username string) bool {
+	violations := h.policy.Check(password, username)
+	if len(violations) > 0 {
+		h.handlerResponse(c, "Password does not meet the policy", http.StatusBadRequest, violations)
+		return false
+	}
+	return true
+}
+
+// abortResponse writes the response and stops the remaining handlers in the chain
+func (h *Handler) abortResponse(c *gin.Context, path string, code int, message interface{}) {
+	h.handlerResponse(c, path, code, message)
+	c.Abort()
+}
+
+// setETag exposes version as the strong ETag of the returned resource
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
 
-	// Create a new email message using 'gomail'
-	m := gomail.NewMessage()
-	m.SetHeader("From", sEmail)
-	m.SetHeader("To", To)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/plain", messageBody)
+// ifMatch returns the version named by the If-Match header, zero for "*" or
+// for an absent header on other methods. PUT, PATCH and DELETE must send the
+// header and are answered with 428 without it; a header that cannot match any
+// version is answered with 412. ok is false once a response is written
+func (h *Handler) ifMatch(c *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		switch c.Request.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			h.handlerResponse(c, "If-Match is required", http.StatusPreconditionRequired, "Send the ETag from a previous GET, or * to write unconditionally")
+			return 0, false
+		}
+		return 0, true
+	}
+	if header == "*" {
+		return 0, true
+	}
 
-	// Set up the email sending configuration
-	d := gomail.NewDialer(sEmail, 587, sEmail, sPassword)
+	tag, err := strconv.Unquote(header)
+	if err == nil {
+		version, err = strconv.Atoi(tag)
+	}
+	if err != nil || version <= 0 {
+		h.handlerResponse(c, "If-Match does not match", http.StatusPreconditionFailed, "If-Match must be a single strong ETag")
+		return 0, false
+	}
+	return version, true
+}
 
-	// Send the email
-	if err := d.DialAndSend(m); err != nil {
-		return err
+// versionConflict answers 412 when a versioned write lost against another one
+func (h *Handler) versionConflict(c *gin.Context, err error, name string) bool {
+	if errors.Is(err, storage.ErrVersionConflict) {
+		h.handlerResponse(c, name+" was modified, fetch it again and retry", http.StatusPreconditionFailed, err.Error())
+		return true
 	}
-	return nil
+	return false
 }
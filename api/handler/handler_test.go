@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/config"
+	"app/pkg/logger"
+	"app/pkg/mailer"
+	"app/storage"
+	"app/storage/memory"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// testServer serves the handlers over the memory backend as the user userId
+// with role, leaving out token validation
+type testServer struct {
+	t        *testing.T
+	h        *Handler
+	strg     storage.StorageInterface
+	router   *gin.Engine
+	userId   string
+	role     string
+	verified bool
+}
+
+type testResponse struct {
+	Status      int             `json:"status"`
+	Description string          `json:"description"`
+	Data        json.RawMessage `json:"data"`
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		MaxLimit:           100,
+		TaxRate:            825,
+		SecretKey:          "test",
+		AccessTokenTTL:     time.Minute,
+		RefreshTokenTTL:    time.Hour,
+		LoginMaxFailures:   10,
+		LoginIPMaxFailures: 50,
+
+		PasswordResetMaxAttempts: 5,
+	}
+	strg, err := memory.NewConnectionMemory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logger.NewLogger("test", logger.LevelError)
+	s := &testServer{
+		t:        t,
+		h:        NewHandler(cfg, strg, mailer.NewLogMailer(log), log),
+		strg:     strg,
+		router:   gin.New(),
+		userId:   uuid.New().String(),
+		role:     models.RoleAdmin,
+		verified: true,
+	}
+
+	s.router.POST("/login", s.h.Login)
+	s.router.POST("/refresh", s.h.Refresh)
+	s.router.POST("/password/reset", s.h.ResetPassword)
+
+	r := s.router.Group("/", func(c *gin.Context) {
+		c.Set("user_id", s.userId)
+		c.Set("role", s.role)
+		c.Set("user", &models.User{Id: s.userId, Role: s.role, IsVerified: s.verified})
+	})
+	r.POST("/books", s.h.CreateBook)
+	r.GET("/books", s.h.GetListBooks)
+	r.GET("/books/:id", s.h.GetByIdBook)
+	r.PUT("/books", s.h.UpdateBook)
+	r.PATCH("/books/:id", s.h.PatchBook)
+	r.DELETE("/books/:id", s.h.DeleteBook)
+	r.POST("/books/:id/categories", s.h.AddBookCategories)
+	r.DELETE("/books/:id/categories/:category_id", s.h.RemoveBookCategory)
+	r.POST("/books/:id/tags", s.h.AddBookTags)
+	r.DELETE("/books/:id/tags/:tag", s.h.RemoveBookTag)
+	r.POST("/categories", s.h.CreateCategory)
+	r.GET("/categories/:id", s.h.GetByIdCategory)
+	r.PATCH("/categories/:id", s.h.PatchCategory)
+	r.GET("/categories/tree", s.h.GetCategoryTree)
+	r.GET("/categories/:id/ancestors", s.h.GetCategoryAncestors)
+	r.GET("/categories/:id/books", s.h.GetCategoryBooks)
+	r.POST("/authors", s.h.CreateAuthor)
+	r.PATCH("/authors/:id", s.h.PatchAuthor)
+	r.DELETE("/authors/:id", s.h.DeleteAuthor)
+	r.GET("/authors/:id/books", s.h.GetAuthorBooks)
+	r.POST("/publishers", s.h.CreatePublisher)
+	r.PATCH("/publishers/:id", s.h.PatchPublisher)
+	r.DELETE("/publishers/:id", s.h.DeletePublisher)
+	r.GET("/publishers/:id/books", s.h.GetPublisherBooks)
+	r.GET("/users/:id", s.h.GetByIdUser)
+	r.PATCH("/users/:id", s.h.PatchUser)
+	r.POST("/orders", s.h.CreateOrder)
+	r.PUT("/orders", s.h.UpdateOrder)
+	r.PATCH("/orders/:id", s.h.PatchOrder)
+	r.GET("/orders/:id", s.h.GetByIdOrder)
+	r.DELETE("/orders/:id", s.h.DeleteOrder)
+	r.POST("/orders/:id/pay", s.h.TransitionOrder(models.OrderStatusPaid))
+	r.POST("/orders/:id/ship", s.h.TransitionOrder(models.OrderStatusShipped))
+	r.POST("/orders/:id/cancel", s.h.TransitionOrder(models.OrderStatusCancelled))
+	r.POST("/order_items", s.h.CreateOrderItem)
+	r.PATCH("/order_items/:id", s.h.PatchOrderItem)
+	r.DELETE("/order_items/:id", s.h.DeleteOrderItem)
+	r.GET("/cart", s.h.GetCart)
+	r.DELETE("/cart", s.h.ClearCart)
+	r.POST("/cart/items", s.h.AddCartItem)
+	r.PUT("/cart/items/:book_id", s.h.UpdateCartItem)
+	r.DELETE("/cart/items/:book_id", s.h.RemoveCartItem)
+	r.POST("/cart/checkout", s.h.CheckoutCart)
+	return s
+}
+
+// do sends body as JSON and decodes the response, storing its data in out
+// when out is not nil
+func (s *testServer) do(method, path string, body interface{}, out interface{}) testResponse {
+	s.t.Helper()
+	return s.send(method, path, body, nil, out)
+}
+
+// doWith sends a request like do with extra headers
+func (s *testServer) doWith(method, path string, body interface{}, headers map[string]string) testResponse {
+	s.t.Helper()
+	return s.send(method, path, body, headers, nil)
+}
+
+func (s *testServer) send(method, path string, body interface{}, headers map[string]string, out interface{}) testResponse {
+	s.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	var resp testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		s.t.Fatalf("%s %s: %v: %s", method, path, err, rec.Body.String())
+	}
+	if resp.Status != rec.Code {
+		s.t.Fatalf("%s %s: body status %d, code %d", method, path, resp.Status, rec.Code)
+	}
+	if out != nil && resp.Status < 300 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			s.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// expect sends a request like do and fails unless it answers with status
+func (s *testServer) expect(status int, method, path string, body interface{}, out interface{}) {
+	s.t.Helper()
+	s.expectWith(status, method, path, body, nil, out)
+}
+
+// expectWith sends a request like expect with extra headers
+func (s *testServer) expectWith(status int, method, path string, body interface{}, headers map[string]string, out interface{}) {
+	s.t.Helper()
+	resp := s.send(method, path, body, headers, out)
+	if resp.Status != status {
+		s.t.Fatalf("%s %s = %d %q, want %d", method, path, resp.Status, resp.Description, status)
+	}
+}
+
+// ifMatch returns the If-Match header naming version, or * for zero
+func ifMatch(version int) map[string]string {
+	if version == 0 {
+		return map[string]string{"If-Match": "*"}
+	}
+	return map[string]string{"If-Match": strconv.Quote(strconv.Itoa(version))}
+}
+
+// book adds a book priced in USD with stock copies on hand
+func (s *testServer) book(price int64, stock int) string {
+	s.t.Helper()
+	ctx := context.Background()
+	id, err := s.strg.Books().Create(ctx, &models.CreateBook{Title: "Book", Price: price, Currency: "USD"})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	_, err = s.strg.Inventory().Adjust(ctx, &models.AdjustInventory{BookId: id, Delta: stock, Reason: models.InventoryReasonRestock})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return id
+}
+
+// stock returns the stock and reserved quantity of a book
+func (s *testServer) stock(bookId string) (int, int) {
+	s.t.Helper()
+	book, err := s.strg.Books().GetById(context.Background(), &models.BookPrimaryKey{Id: bookId})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return book.StockQuantity, book.ReservedQuantity
+}
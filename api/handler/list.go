@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"app/api/models"
+)
+
+type fieldType int
+
+const (
+	textField fieldType = iota
+	idField
+	intField
+	boolField
+	timeField
+	tagField
+)
+
+// operators lists the filter operators each field type supports
+var operators = map[fieldType][]string{
+	textField: {models.FilterEq, models.FilterNe, models.FilterLike, models.FilterIn},
+	idField:   {models.FilterEq, models.FilterNe, models.FilterIn},
+	intField:  {models.FilterEq, models.FilterNe, models.FilterGt, models.FilterGte, models.FilterLt, models.FilterLte, models.FilterIn},
+	boolField: {models.FilterEq, models.FilterNe},
+	timeField: {models.FilterEq, models.FilterNe, models.FilterGt, models.FilterGte, models.FilterLt, models.FilterLte},
+	tagField:  {models.FilterEq, models.FilterNe, models.FilterLike, models.FilterIn},
+}
+
+// listSpec whitelists what a list endpoint may be filtered and sorted by
+type listSpec struct {
+	filters map[string]fieldType
+	sorts   []string
+}
+
+var (
+	bookList = listSpec{
+		filters: map[string]fieldType{
+			"id":             idField,
+			"title":          textField,
+			"author":         textField,
+			"publisher":      textField,
+			"author_id":      idField,
+			"publisher_id":   idField,
+			"category":       idField,
+			"tag":            tagField,
+			"num_pages":      intField,
+			"lang":           textField,
+			"price":          intField,
+			"currency":       textField,
+			"stock_quantity": intField,
+			"created_at":     timeField,
+			"updated_at":     timeField,
+		},
+		sorts: []string{"title", "author", "publisher", "num_pages", "lang", "price", "stock_quantity", "created_at", "updated_at"},
+	}
+	categoryList = listSpec{
+		filters: map[string]fieldType{
+			"id":         idField,
+			"name":       textField,
+			"type":       textField,
+			"created_at": timeField,
+			"updated_at": timeField,
+		},
+		sorts: []string{"name", "type", "created_at", "updated_at"},
+	}
+	authorList = listSpec{
+		filters: map[string]fieldType{
+			"id":         idField,
+			"name":       textField,
+			"created_at": timeField,
+			"updated_at": timeField,
+		},
+		sorts: []string{"name", "created_at", "updated_at"},
+	}
+	publisherList = authorList
+	movementList  = listSpec{
+		filters: map[string]fieldType{
+			"reason":     textField,
+			"user_id":    idField,
+			"order_id":   idField,
+			"created_at": timeField,
+		},
+		sorts: []string{"created_at"},
+	}
+	userList = listSpec{
+		filters: map[string]fieldType{
+			"id":          idField,
+			"first_name":  textField,
+			"last_name":   textField,
+			"age":         intField,
+			"username":    textField,
+			"email":       textField,
+			"role":        textField,
+			"is_verified": boolField,
+			"created_at":  timeField,
+			"updated_at":  timeField,
+		},
+		sorts: []string{"first_name", "last_name", "age", "username", "email", "role", "created_at", "updated_at"},
+	}
+	orderList = listSpec{
+		filters: map[string]fieldType{
+			"order_id":   idField,
+			"user_id":    idField,
+			"status":     textField,
+			"created_at": timeField,
+			"updated_at": timeField,
+		},
+		sorts: []string{"status", "created_at", "updated_at"},
+	}
+	orderItemList = listSpec{
+		filters: map[string]fieldType{
+			"item_id":    idField,
+			"order_id":   idField,
+			"book_id":    idField,
+			"quantity":   intField,
+			"line_total": intField,
+			"created_at": timeField,
+			"updated_at": timeField,
+		},
+		sorts: []string{"quantity", "line_total", "created_at", "updated_at"},
+	}
+)
+
+var filterKey = regexp.MustCompile(`^filter\[(\w+)\](?:\[(\w+)\])?$`)
+
+// listParams holds the list options read from the query string
+type listParams struct {
+	filters []models.Filter
+	sorts   []models.Sort
+	cursor  *models.Cursor
+	count   bool
+}
+
+// listQuery parses filter[field][op]=value and sort=-field,field against
+// spec, the cursor of a keyset page and whether to count the matching rows,
+// which by default is done for offset pages only. It answers 400 itself and
+// returns false when the query is not valid for spec
+func (h *Handler) listQuery(c *gin.Context, spec listSpec) (listParams, bool) {
+	var (
+		list listParams
+		err  error
+	)
+	list.filters, err = parseFilters(c.Request.URL.Query(), spec)
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing filter", http.StatusBadRequest, err.Error())
+		return list, false
+	}
+	list.sorts, err = parseSorts(c.Query("sort"), spec)
+	if err != nil {
+		h.handlerResponse(c, "Error while parsing sort", http.StatusBadRequest, err.Error())
+		return list, false
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		list.cursor, err = models.DecodeCursor(cursor)
+		if err != nil {
+			h.handlerResponse(c, "Error while parsing cursor", http.StatusBadRequest, err.Error())
+			return list, false
+		}
+		if c.Query("offset") != "" || len(list.sorts) > 0 {
+			h.handlerResponse(c, "Error while parsing cursor", http.StatusBadRequest, "cursor cannot be combined with offset or sort")
+			return list, false
+		}
+	}
+
+	list.count = list.cursor == nil
+	if count := c.Query("count"); count != "" {
+		list.count, err = strconv.ParseBool(count)
+		if err != nil {
+			h.handlerResponse(c, "Error while parsing count", http.StatusBadRequest, err.Error())
+			return list, false
+		}
+	}
+	return list, true
+}
+
+func parseFilters(query map[string][]string, spec listSpec) ([]models.Filter, error) {
+	var filters []models.Filter
+	for key, values := range query {
+		match := filterKey.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		field, op := match[1], match[2]
+		if op == "" {
+			op = models.FilterEq
+		}
+
+		typ, ok := spec.filters[field]
+		if !ok {
+			return nil, fmt.Errorf("cannot filter by %q", field)
+		}
+		if !contains(operators[typ], op) {
+			return nil, fmt.Errorf("operator %q is not supported for %q", op, field)
+		}
+
+		for _, raw := range values {
+			var value interface{}
+			if op == models.FilterIn {
+				var list []interface{}
+				for _, item := range strings.Split(raw, ",") {
+					v, err := parseValue(typ, item)
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", field, err)
+					}
+					list = append(list, v)
+				}
+				value = list
+			} else {
+				v, err := parseValue(typ, raw)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", field, err)
+				}
+				value = v
+			}
+			filters = append(filters, models.Filter{Field: field, Op: op, Value: value})
+		}
+	}
+	return filters, nil
+}
+
+func parseValue(typ fieldType, raw string) (interface{}, error) {
+	switch typ {
+	case idField:
+		if _, err := uuid.Parse(raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	case intField:
+		return strconv.Atoi(raw)
+	case boolField:
+		return strconv.ParseBool(raw)
+	case tagField:
+		return normalizeTag(raw), nil
+	case timeField:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t.UTC(), nil
+		}
+		return time.Parse("2006-01-02", raw)
+	}
+	return raw, nil
+}
+
+func parseSorts(raw string, spec listSpec) ([]models.Sort, error) {
+	var sorts []models.Sort
+	if raw == "" {
+		return sorts, nil
+	}
+	for _, field := range strings.Split(raw, ",") {
+		sort := models.Sort{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(sort.Field, "-") {
+			sort.Field, sort.Desc = sort.Field[1:], true
+		}
+		if !contains(spec.sorts, sort.Field) {
+			return nil, fmt.Errorf("cannot sort by %q", sort.Field)
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"app/api/models"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFilters(t *testing.T) {
+	id := "0b9c8f1e-5d43-4f4e-9a43-6f0f0a4f8f11"
+	tests := []struct {
+		name  string
+		query map[string][]string
+		want  []models.Filter
+	}{
+		{"eq by default", map[string][]string{"filter[title]": {"Dune"}},
+			[]models.Filter{{Field: "title", Op: models.FilterEq, Value: "Dune"}}},
+		{"int", map[string][]string{"filter[price][gte]": {"1000"}},
+			[]models.Filter{{Field: "price", Op: models.FilterGte, Value: 1000}}},
+		{"date", map[string][]string{"filter[created_at][lt]": {"2024-01-02"}},
+			[]models.Filter{{Field: "created_at", Op: models.FilterLt, Value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+		{"rfc3339 in utc", map[string][]string{"filter[created_at][gt]": {"2024-01-02T03:00:00+03:00"}},
+			[]models.Filter{{Field: "created_at", Op: models.FilterGt, Value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+		{"in", map[string][]string{"filter[num_pages][in]": {"100,200"}},
+			[]models.Filter{{Field: "num_pages", Op: models.FilterIn, Value: []interface{}{100, 200}}}},
+		{"repeated", map[string][]string{"filter[id][ne]": {id, id}},
+			[]models.Filter{{Field: "id", Op: models.FilterNe, Value: id}, {Field: "id", Op: models.FilterNe, Value: id}}},
+		{"other keys", map[string][]string{"offset": {"10"}, "sort": {"title"}, "filter": {"x"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilters(tt.query, bookList)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFilters(%v) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFiltersRejects(t *testing.T) {
+	for _, key := range []string{
+		"filter[password]",
+		"filter[title][gt]",
+		"filter[price][like]",
+		"filter[price]",
+		"filter[id]",
+		"filter[created_at]",
+		"filter[num_pages][in]",
+	} {
+		query := map[string][]string{key: {"x,1"}}
+		if key == "filter[price]" {
+			query[key] = []string{"12.5"}
+		}
+		if _, err := parseFilters(query, bookList); err == nil {
+			t.Errorf("parseFilters(%v) succeeded", query)
+		}
+	}
+}
+
+func TestParseSorts(t *testing.T) {
+	got, err := parseSorts("-price, title", bookList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Sort{{Field: "price", Desc: true}, {Field: "title"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSorts = %+v, want %+v", got, want)
+	}
+
+	if got, err := parseSorts("", bookList); err != nil || len(got) != 0 {
+		t.Errorf("parseSorts(\"\") = %v, %v, want none", got, err)
+	}
+	for _, raw := range []string{"id", "-password", "title,", "--title"} {
+		if _, err := parseSorts(raw, bookList); err == nil {
+			t.Errorf("parseSorts(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestListRejectsOutOfRangePages(t *testing.T) {
+	s := newTestServer(t)
+	s.book(1000, 1)
+
+	for query, status := range map[string]int{
+		"offset=0&limit=1": http.StatusOK,
+		"limit=100":        http.StatusOK,
+		"offset=-1":        http.StatusBadRequest,
+		"limit=0":          http.StatusBadRequest,
+		"limit=-5":         http.StatusBadRequest,
+		"limit=101":        http.StatusBadRequest,
+		"offset=x":         http.StatusBadRequest,
+	} {
+		s.expect(status, "GET", "/books?"+query, nil, nil)
+	}
+}
+
+func TestListRejectsTamperedCursor(t *testing.T) {
+	s := newTestServer(t)
+	s.book(1000, 1)
+
+	tampered := models.Cursor{CreatedAt: time.Now(), Id: "not-a-uuid"}.Encode()
+	s.expect(http.StatusBadRequest, "GET", "/books?cursor="+tampered, nil, nil)
+}
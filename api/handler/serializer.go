@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strings"
+
+	"app/api/models"
+)
+
+// privateUser serializes a user for its owner or an admin
+func privateUser(user *models.User) *models.PrivateUser {
+	return &models.PrivateUser{
+		Id:         user.Id,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Age:        user.Age,
+		Phone:      user.Phone,
+		Picture:    user.Picture,
+		Username:   user.Username,
+		Email:      user.Email,
+		CardNo:     maskCardNo(user.CardNo),
+		Role:       user.Role,
+		IsVerified: user.IsVerified,
+		Version:    user.Version,
+	}
+}
+
+// publicUser serializes a user for anyone else
+func publicUser(user *models.User) *models.PublicUser {
+	return &models.PublicUser{
+		Id:        user.Id,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Picture:   user.Picture,
+		Username:  user.Username,
+	}
+}
+
+func privateUsers(resp *models.UserGetListResponse) *models.PrivateUserGetListResponse {
+	var list = &models.PrivateUserGetListResponse{
+		Count:      resp.Count,
+		NextCursor: resp.NextCursor,
+		PrevCursor: resp.PrevCursor,
+	}
+	for _, user := range resp.Users {
+		list.Users = append(list.Users, privateUser(user))
+	}
+	return list
+}
+
+// maskCardNo keeps only the last four digits of a card number
+func maskCardNo(cardNo string) string {
+	var digits []rune
+	for _, r := range cardNo {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) == 0 {
+		return ""
+	}
+	if len(digits) <= 4 {
+		return strings.Repeat("*", len(digits))
+	}
+	return strings.Repeat("*", len(digits)-4) + string(digits[len(digits)-4:])
+}
@@ -0,0 +1,18 @@
+package handler
+
+import "testing"
+
+func TestMaskCardNo(t *testing.T) {
+	for cardNo, want := range map[string]string{
+		"":                    "",
+		"none":                "",
+		"123":                 "***",
+		"1234":                "****",
+		"4111111111111111":    "************1111",
+		"4111 1111-1111 1234": "************1234",
+	} {
+		if got := maskCardNo(cardNo); got != want {
+			t.Errorf("maskCardNo(%q) = %q, want %q", cardNo, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+// Roles an author can be credited with on a book
+const (
+	AuthorRoleAuthor     = "author"
+	AuthorRoleTranslator = "translator"
+	AuthorRoleEditor     = "editor"
+)
+
+type Author struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Bio     string `json:"bio"`
+	Version int    `json:"version"`
+}
+
+type CreateAuthor struct {
+	Name string `json:"name"`
+	Bio  string `json:"bio"`
+}
+
+type UpdateAuthor struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Bio     string `json:"bio"`
+	Version int    `json:"-"`
+}
+
+// PatchAuthor changes only the fields that are set
+type PatchAuthor struct {
+	Id      string  `json:"-"`
+	Name    *string `json:"name"`
+	Bio     *string `json:"bio"`
+	Version int     `json:"-"`
+}
+
+type AuthorGetListRequest struct {
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
+}
+
+type AuthorGetListResponse struct {
+	Count      *int      `json:"count,omitempty"`
+	Authors    []*Author `json:"authors"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+}
+
+type AuthorPrimaryKey struct {
+	Id      string `json:"id"`
+	Version int    `json:"-"`
+}
+
+// BookAuthor credits an author on a book. Name is filled in when a book is
+// read and ignored when one is written
+type BookAuthor struct {
+	AuthorId string `json:"author_id"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+}
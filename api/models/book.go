@@ -1,47 +1,137 @@
 package models
 
+// Book carries Author and Publisher as display names kept in step with the
+// credited authors and the publisher entity. Price is in minor units of
+// Currency; stock only changes through inventory movements
 type Book struct {
-	Id        string `json:"id"`
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	Publisher string `json:"publisher"`
-	Category  string `json:"category"`
-	NumPages  int    `json:"num_pages"`
-	Picture   string `json:"picture"`
-	Lang      string `json:"lang"`
+	Id               string        `json:"id"`
+	Title            string        `json:"title"`
+	Author           string        `json:"author"`
+	Authors          []*BookAuthor `json:"authors"`
+	Publisher        string        `json:"publisher"`
+	PublisherId      string        `json:"publisher_id"`
+	Categories       []*Category   `json:"categories"`
+	Tags             []string      `json:"tags"`
+	NumPages         int           `json:"num_pages"`
+	Picture          string        `json:"picture"`
+	Lang             string        `json:"lang"`
+	Price            int64         `json:"price"`
+	Currency         string        `json:"currency"`
+	StockQuantity    int           `json:"stock_quantity"`
+	ReservedQuantity int           `json:"reserved_quantity"`
+	Version          int           `json:"version"`
 }
 
 type CreateBook struct {
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	Publisher string `json:"publisher"`
-	Category  string `json:"category"`
-	NumPages  int    `json:"num_pages"`
-	Picture   string `json:"picture"`
-	Lang      string `json:"lang"`
+	Title       string        `json:"title"`
+	Authors     []*BookAuthor `json:"authors"`
+	PublisherId string        `json:"publisher_id"`
+	CategoryIds []string      `json:"category_ids"`
+	Tags        []string      `json:"tags"`
+	NumPages    int           `json:"num_pages"`
+	Picture     string        `json:"picture"`
+	Lang        string        `json:"lang"`
+	Price       int64         `json:"price"`
+	Currency    string        `json:"currency"`
 }
 
 type UpdateBook struct {
-	Id        string `json:"id"`
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	Publisher string `json:"publisher"`
-	Category  string `json:"category"`
-	NumPages  int    `json:"num_pages"`
-	Picture   string `json:"picture"`
-	Lang      string `json:"lang"`
+	Id          string        `json:"id"`
+	Title       string        `json:"title"`
+	Authors     []*BookAuthor `json:"authors"`
+	PublisherId string        `json:"publisher_id"`
+	CategoryIds []string      `json:"category_ids"`
+	Tags        []string      `json:"tags"`
+	NumPages    int           `json:"num_pages"`
+	Picture     string        `json:"picture"`
+	Lang        string        `json:"lang"`
+	Price       int64         `json:"price"`
+	Currency    string        `json:"currency"`
+	Version     int           `json:"-"`
+}
+
+// PatchBook changes only the fields that are set
+type PatchBook struct {
+	Id          string         `json:"-"`
+	Title       *string        `json:"title"`
+	Authors     *[]*BookAuthor `json:"authors"`
+	PublisherId *string        `json:"publisher_id"`
+	CategoryIds *[]string      `json:"category_ids"`
+	Tags        *[]string      `json:"tags"`
+	NumPages    *int           `json:"num_pages"`
+	Picture     *string        `json:"picture"`
+	Lang        *string        `json:"lang"`
+	Price       *int64         `json:"price"`
+	Currency    *string        `json:"currency"`
+	Version     int            `json:"-"`
 }
 
 type BookGetListRequest struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
 }
 
 type BookGetListResponse struct {
-	Count int     `json:"count"`
-	Books []*Book `json:"books"`
+	Count      *int    `json:"count,omitempty"`
+	Books      []*Book `json:"books"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 }
 
 type BookPrimaryKey struct {
-	Id string `json:"id"`
+	Id      string `json:"id"`
+	Version int    `json:"-"`
+}
+
+// BookCategories links a book to more categories, keeping the ones it has
+type BookCategories struct {
+	BookId      string   `json:"-"`
+	CategoryIds []string `json:"category_ids"`
+}
+
+type BookCategoryPrimaryKey struct {
+	BookId     string `json:"book_id"`
+	CategoryId string `json:"category_id"`
+}
+
+// BookTags adds tags to a book, keeping the ones it has
+type BookTags struct {
+	BookId string   `json:"-"`
+	Tags   []string `json:"tags"`
+}
+
+type BookTagPrimaryKey struct {
+	BookId string `json:"book_id"`
+	Tag    string `json:"tag"`
+}
+
+// BookSearchRequest matches Query against title, author and publisher using
+// the stemming rules of each book's lang; Lang narrows results to one language
+type BookSearchRequest struct {
+	Query  string `json:"query"`
+	Lang   string `json:"lang"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// BookHighlight holds the searched fields with matches wrapped in <mark> tags
+type BookHighlight struct {
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Publisher string `json:"publisher"`
+}
+
+type BookSearchResult struct {
+	*Book
+	Rank      float64       `json:"rank"`
+	Highlight BookHighlight `json:"highlight"`
+}
+
+type BookSearchResponse struct {
+	Count int                 `json:"count"`
+	Books []*BookSearchResult `json:"books"`
 }
@@ -0,0 +1,60 @@
+package models
+
+// Cart holds the books a user means to order. A cart without a user belongs
+// to a visitor who is not logged in; its id is all they need to reach it.
+// Prices and stock are read live from the books, so Subtotal can change
+// between views
+type Cart struct {
+	CartId   string      `json:"cart_id"`
+	UserId   string      `json:"user_id,omitempty"`
+	Currency string      `json:"currency"`
+	Subtotal int64       `json:"subtotal"`
+	InStock  bool        `json:"in_stock"`
+	Items    []*CartItem `json:"items"`
+	Version  int         `json:"version"`
+}
+
+// CartItem is a book in a cart. Available is the stock that is not reserved
+// by other orders; InStock reports whether it covers Quantity
+type CartItem struct {
+	BookId    string `json:"book_id"`
+	Book      *Book  `json:"book,omitempty"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"unit_price"`
+	LineTotal int64  `json:"line_total"`
+	Available int    `json:"available"`
+	InStock   bool   `json:"in_stock"`
+}
+
+// CartPrimaryKey finds a cart by CartId, or the cart of UserId
+type CartPrimaryKey struct {
+	CartId  string `json:"cart_id"`
+	UserId  string `json:"user_id"`
+	Version int    `json:"-"`
+}
+
+type CreateCart struct {
+	UserId string `json:"user_id"`
+}
+
+// CartItemRequest adds Quantity copies of a book to a cart, or sets the
+// quantity of a book already in it
+type CartItemRequest struct {
+	CartId   string `json:"-"`
+	BookId   string `json:"book_id"`
+	Quantity int    `json:"quantity"`
+	Version  int    `json:"-"`
+}
+
+type CartItemPrimaryKey struct {
+	CartId  string `json:"cart_id"`
+	BookId  string `json:"book_id"`
+	Version int    `json:"-"`
+}
+
+// MergeCart moves the items of the visitor cart CartId into the cart of
+// UserId
+type MergeCart struct {
+	CartId string `json:"cart_id"`
+	UserId string `json:"user_id"`
+}
@@ -1,35 +1,69 @@
 package models
 
 type Category struct {
-	Id      string `json:"id"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Picture string `json:"picture"`
+	Id       string `json:"id"`
+	ParentId string `json:"parent_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Picture  string `json:"picture"`
+	Version  int    `json:"version"`
 }
 
 type CreateCategory struct {
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Picture string `json:"picture"`
+	ParentId string `json:"parent_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Picture  string `json:"picture"`
 }
 
 type UpdateCategory struct {
-	Id      string `json:"id"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Picture string `json:"picture"`
+	Id       string `json:"id"`
+	ParentId string `json:"parent_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Picture  string `json:"picture"`
+	Version  int    `json:"-"`
+}
+
+// PatchCategory changes only the fields that are set
+type PatchCategory struct {
+	Id       string  `json:"-"`
+	ParentId *string `json:"parent_id"`
+	Name     *string `json:"name"`
+	Type     *string `json:"type"`
+	Picture  *string `json:"picture"`
+	Version  int     `json:"-"`
 }
 
 type CategoryGetListRequest struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
 }
 
 type CategoryGetListResponse struct {
-	Count      int         `json:"count"`
+	Count      *int        `json:"count,omitempty"`
 	Categories []*Category `json:"categorys"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+// CategoryNode is a category with its subcategories
+type CategoryNode struct {
+	*Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryTreeRequest selects the subtree under RootId, or every category
+// when RootId is empty
+type CategoryTreeRequest struct {
+	RootId string `json:"root_id"`
 }
 
 type CategoryPrimaryKey struct {
-	Id string `json:"id"`
+	Id      string `json:"id"`
+	Version int    `json:"-"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type EmailVerification struct {
+	Id        string     `json:"id"`
+	UserId    string     `json:"user_id"`
+	Email     string     `json:"email"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreateEmailVerification struct {
+	UserId    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EmailVerificationPrimaryKey selects a pending verification by Id or TokenHash
+type EmailVerificationPrimaryKey struct {
+	Id        string `json:"id"`
+	TokenHash string `json:"-"`
+}
+
+type EmailVerificationCountRequest struct {
+	UserId string    `json:"user_id"`
+	Since  time.Time `json:"since"`
+}
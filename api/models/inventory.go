@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// Reasons a stock adjustment can be made for
+const (
+	InventoryReasonRestock    = "restock"
+	InventoryReasonReturn     = "return"
+	InventoryReasonDamage     = "damage"
+	InventoryReasonLoss       = "loss"
+	InventoryReasonCorrection = "correction"
+	InventoryReasonReserve    = "reserve"
+	InventoryReasonRelease    = "release"
+	InventoryReasonSale       = "sale"
+)
+
+// InventoryMovement is one entry of a book's stock ledger. StockQuantity and
+// ReservedQuantity are the book's quantities after the movement
+type InventoryMovement struct {
+	Id               string    `json:"id"`
+	BookId           string    `json:"book_id"`
+	Delta            int       `json:"delta"`
+	ReservedDelta    int       `json:"reserved_delta"`
+	Reason           string    `json:"reason"`
+	Note             string    `json:"note"`
+	UserId           string    `json:"user_id"`
+	OrderId          string    `json:"order_id,omitempty"`
+	StockQuantity    int       `json:"stock_quantity"`
+	ReservedQuantity int       `json:"reserved_quantity"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ItemReservations returns the movements that change the stock an order
+// holds for an item from what from needed to what to needs. from is nil for an
+// item being added and to for one being removed. Releases come first
+func ItemReservations(from, to *OrderItem, userId string) []*InventoryMovement {
+	var movements []*InventoryMovement
+	hold := func(item *OrderItem, quantity int) {
+		movement := &InventoryMovement{
+			BookId:        item.BookId,
+			ReservedDelta: quantity,
+			Reason:        InventoryReasonReserve,
+			UserId:        userId,
+			OrderId:       item.OrderId,
+		}
+		if quantity < 0 {
+			movement.Reason = InventoryReasonRelease
+		}
+		movements = append(movements, movement)
+	}
+
+	if from != nil && to != nil && from.OrderId == to.OrderId && from.BookId == to.BookId {
+		if to.Quantity != from.Quantity {
+			hold(to, to.Quantity-from.Quantity)
+		}
+		return movements
+	}
+	if from != nil && from.Quantity > 0 {
+		hold(from, -from.Quantity)
+	}
+	if to != nil && to.Quantity > 0 {
+		hold(to, to.Quantity)
+	}
+	return movements
+}
+
+type AdjustInventory struct {
+	BookId string `json:"-"`
+	Delta  int    `json:"delta"`
+	Reason string `json:"reason"`
+	Note   string `json:"note"`
+	UserId string `json:"-"`
+}
+
+type InventoryMovementGetListRequest struct {
+	BookId  string   `json:"book_id"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
+}
+
+type InventoryMovementGetListResponse struct {
+	Count      *int                 `json:"count,omitempty"`
+	Movements  []*InventoryMovement `json:"movements"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	PrevCursor string               `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestItemReservations(t *testing.T) {
+	type move struct {
+		order, book string
+		delta       int
+		reason      string
+	}
+	tests := []struct {
+		name     string
+		from, to *OrderItem
+		want     []move
+	}{
+		{"added", nil, &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2},
+			[]move{{"o1", "b1", 2, InventoryReasonReserve}}},
+		{"removed", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2}, nil,
+			[]move{{"o1", "b1", -2, InventoryReasonRelease}}},
+		{"more", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2}, &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 5},
+			[]move{{"o1", "b1", 3, InventoryReasonReserve}}},
+		{"fewer", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 5}, &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 1},
+			[]move{{"o1", "b1", -4, InventoryReasonRelease}}},
+		{"unchanged", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2}, &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2},
+			nil},
+		{"other book", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2}, &OrderItem{OrderId: "o1", BookId: "b2", Quantity: 3},
+			[]move{{"o1", "b1", -2, InventoryReasonRelease}, {"o1", "b2", 3, InventoryReasonReserve}}},
+		{"other order", &OrderItem{OrderId: "o1", BookId: "b1", Quantity: 2}, &OrderItem{OrderId: "o2", BookId: "b1", Quantity: 2},
+			[]move{{"o1", "b1", -2, InventoryReasonRelease}, {"o2", "b1", 2, InventoryReasonReserve}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ItemReservations(tt.from, tt.to, "u1")
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d movements, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				want := tt.want[i]
+				if m.OrderId != want.order || m.BookId != want.book || m.ReservedDelta != want.delta || m.Reason != want.reason {
+					t.Errorf("movement %d = %s %s %+d %s, want %s %s %+d %s", i,
+						m.OrderId, m.BookId, m.ReservedDelta, m.Reason, want.order, want.book, want.delta, want.reason)
+				}
+				if m.Delta != 0 || m.UserId != "u1" {
+					t.Errorf("movement %d changes stock by %d for %q, want 0 for u1", i, m.Delta, m.UserId)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Filter operators accepted in filter[field][op]; eq is used when op is omitted
+const (
+	FilterEq   = "eq"
+	FilterNe   = "ne"
+	FilterGt   = "gt"
+	FilterGte  = "gte"
+	FilterLt   = "lt"
+	FilterLte  = "lte"
+	FilterLike = "like"
+	FilterIn   = "in"
+)
+
+// Filter is one condition of a list request. Value is already converted to
+// the field's type: string, int, bool or time.Time, or a slice of those for in
+type Filter struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// Sort orders a list by Field, descending when Desc is set
+type Sort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Cursor is the position of a keyset page in the default newest-first order.
+// The page holds the rows after the cursor row, or the rows before it when
+// Before is set
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Id        string    `json:"id"`
+	Before    bool      `json:"b,omitempty"`
+}
+
+// Encode returns the cursor as the opaque string handed to clients
+func (c Cursor) Encode() string {
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// DecodeCursor parses a string returned by Encode. Cursors come back from
+// clients, so the id is checked to be a uuid before it reaches a query
+func DecodeCursor(s string) (*Cursor, error) {
+	var c Cursor
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(body, &c)
+	}
+	if err == nil {
+		_, err = uuid.Parse(c.Id)
+	}
+	if err != nil || c.CreatedAt.IsZero() {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, cursor := range []Cursor{
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC), Id: "0b9c8f1e-5d43-4f4e-9a43-6f0f0a4f8f11"},
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Id: "7c3e2f0a-1b2d-4c5e-8f90-a1b2c3d4e5f6", Before: true},
+	} {
+		got, err := DecodeCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%+v) = %v", cursor, err)
+		}
+		if !got.CreatedAt.Equal(cursor.CreatedAt) || got.Id != cursor.Id || got.Before != cursor.Before {
+			t.Errorf("round trip of %+v = %+v", cursor, *got)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not base64!",
+		Cursor{Id: "0b9c8f1e-5d43-4f4e-9a43-6f0f0a4f8f11"}.Encode(),
+		Cursor{CreatedAt: time.Now()}.Encode(),
+		Cursor{CreatedAt: time.Now(), Id: "7"}.Encode(),
+		Cursor{CreatedAt: time.Now(), Id: "' OR 1=1 --"}.Encode(),
+		"e30", // {}
+	} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Errorf("DecodeCursor(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+const (
+	LoginSuccess     = "success"
+	LoginUnknownUser = "unknown_user"
+	LoginBadPassword = "bad_password"
+	// LoginThrottled attempts are audited but do not count as failures
+	LoginThrottled = "throttled"
+	// LoginPending attempts are recorded before the password is checked and
+	// count as failures until resolved, so concurrent guesses see each other
+	LoginPending = "pending"
+)
+
+type LoginAttempt struct {
+	Id        string    `json:"id"`
+	Username  string    `json:"username"`
+	UserId    string    `json:"user_id"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateLoginAttempt struct {
+	Username  string `json:"username"`
+	UserId    string `json:"user_id"`
+	Ip        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason"`
+}
+
+// UpdateLoginAttempt resolves a pending attempt once the password is checked
+type UpdateLoginAttempt struct {
+	Id      string `json:"id"`
+	UserId  string `json:"user_id"`
+	Success bool   `json:"success"`
+	Reason  string `json:"reason"`
+}
+
+// LoginFailureRequest counts failures for Username since its last success,
+// or for Ip when Username is empty, no older than Since, leaving out the
+// attempt ExceptId being checked
+type LoginFailureRequest struct {
+	Username string    `json:"username"`
+	Ip       string    `json:"ip"`
+	Since    time.Time `json:"since"`
+	ExceptId string    `json:"except_id"`
+}
+
+type LoginFailures struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+type LoginAttemptGetListRequest struct {
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+	Username string `json:"username"`
+	Ip       string `json:"ip"`
+}
+
+type LoginAttemptGetListResponse struct {
+	Count         int             `json:"count"`
+	LoginAttempts []*LoginAttempt `json:"login_attempts"`
+}
@@ -1,29 +1,89 @@
 package models
 
+// Order amounts are in minor units of Currency, the currency of its items.
+// TaxRate is in basis points and fixed when the order is created; the items,
+// discount and so every amount are fixed once it leaves pending
 type Order struct {
-	OrderId string `json:"order_id"`
-	UserId  string `json:"user_id"`
+	OrderId  string       `json:"order_id"`
+	UserId   string       `json:"user_id"`
+	Status   string       `json:"status"`
+	Currency string       `json:"currency"`
+	Subtotal int64        `json:"subtotal"`
+	Discount int64        `json:"discount"`
+	TaxRate  int          `json:"tax_rate"`
+	Tax      int64        `json:"tax"`
+	Total    int64        `json:"total"`
+	Items    []*OrderItem `json:"items,omitempty"`
+	Version  int          `json:"version"`
+}
+
+// SetTotals fills in Subtotal and the tax and total that follow from it. The
+// discount never takes the total below zero and tax is rounded half up
+func (o *Order) SetTotals(subtotal int64) {
+	taxable := subtotal - o.Discount
+	if taxable < 0 {
+		taxable = 0
+	}
+	o.Subtotal = subtotal
+	o.Tax = (taxable*int64(o.TaxRate) + 5000) / 10000
+	o.Total = taxable + o.Tax
 }
 
 type CreateOrder struct {
-	UserId string `json:"user_id"`
+	UserId  string `json:"user_id"`
+	TaxRate int    `json:"-"`
 }
 
 type UpdateOrder struct {
-	OrderId string `json:"order_id"`
-	UserId  string `json:"user_id"`
+	OrderId  string `json:"order_id"`
+	UserId   string `json:"user_id"`
+	Discount int64  `json:"discount"`
+	Version  int    `json:"-"`
+}
+
+// PatchOrder changes only the fields that are set
+type PatchOrder struct {
+	OrderId  string  `json:"-"`
+	UserId   *string `json:"user_id"`
+	Discount *int64  `json:"discount"`
+	Version  int     `json:"-"`
 }
 
 type OrderGetListRequest struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	UserId  string   `json:"user_id"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
 }
 
 type OrderGetListResponse struct {
-	Count  int      `json:"count"`
-	Orders []*Order `json:"orders"`
+	Count      *int     `json:"count,omitempty"`
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
 }
 
 type OrderPrimaryKey struct {
 	OrderId string `json:"order_id"`
+	Version int    `json:"-"`
+	UserId  string `json:"-"`
+}
+
+type CheckoutItem struct {
+	BookId   string `json:"book_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// Checkout places an order for Items in one step, reserving their stock
+type Checkout struct {
+	UserId  string          `json:"user_id"`
+	Items   []*CheckoutItem `json:"items"`
+	TaxRate int             `json:"-"`
+	// CartId is the cart the items were read from at CartVersion, if any. It
+	// is emptied along with placing the order
+	CartId      string `json:"-"`
+	CartVersion int    `json:"-"`
 }
@@ -1,32 +1,71 @@
 package models
 
+// OrderItem carries the book's price and currency as they were when the
+// book was added to the order. Book is only filled in with the order
 type OrderItem struct {
-	ItemId  string `json:"item_id"`
-	OrderId string `json:"order_id"`
-	BookId  string `json:"book_id"`
+	ItemId    string `json:"item_id"`
+	OrderId   string `json:"order_id"`
+	BookId    string `json:"book_id"`
+	Book      *Book  `json:"book,omitempty"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"unit_price"`
+	Currency  string `json:"currency"`
+	LineTotal int64  `json:"line_total"`
+	Version   int    `json:"version"`
 }
 
+// CreateOrderItem takes UnitPrice and Currency from the book, never the client
 type CreateOrderItem struct {
-	OrderId string `json:"order_id"`
-	BookId  string `json:"book_id"`
+	OrderId   string `json:"order_id"`
+	BookId    string `json:"book_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"-"`
+	Currency  string `json:"-"`
+	UserId    string `json:"-"`
 }
 
 type UpdateOrderItem struct {
-	ItemId  string `json:"item_id"`
-	OrderId string `json:"order_id"`
-	BookId  string `json:"book_id"`
+	ItemId    string `json:"item_id"`
+	OrderId   string `json:"order_id"`
+	BookId    string `json:"book_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"-"`
+	Currency  string `json:"-"`
+	UserId    string `json:"-"`
+	Version   int    `json:"-"`
+}
+
+// PatchOrderItem changes only the fields that are set
+type PatchOrderItem struct {
+	ItemId    string  `json:"-"`
+	OrderId   *string `json:"order_id"`
+	BookId    *string `json:"book_id"`
+	Quantity  *int    `json:"quantity"`
+	UnitPrice *int64  `json:"-"`
+	Currency  *string `json:"-"`
+	UserId    string  `json:"-"`
+	Version   int     `json:"-"`
 }
 
 type OrderItemGetListRequest struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	UserId  string   `json:"user_id"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
 }
 
 type OrderItemGetListResponse struct {
-	Count      int          `json:"count"`
+	Count      *int         `json:"count,omitempty"`
 	OrderItems []*OrderItem `json:"order_items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
 }
 
 type OrderItemPrimaryKey struct {
-	ItemId string `json:"item_id"`
+	ItemId  string `json:"item_id"`
+	UserId  string `json:"-"`
+	Version int    `json:"-"`
 }
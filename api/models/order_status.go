@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Order statuses; a new order is pending
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// orderTransitions lists the statuses an order may move to from each status
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+// CanTransition reports whether an order in status from may move to status to
+func CanTransition(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether an order in status is done with: it was
+// cancelled, delivered or refunded and holds no stock, so it may be deleted
+func IsTerminal(status string) bool {
+	return status == OrderStatusCancelled || status == OrderStatusDelivered || status == OrderStatusRefunded
+}
+
+type OrderTransition struct {
+	OrderId string `json:"-"`
+	Status  string `json:"-"`
+	Note    string `json:"note"`
+	UserId  string `json:"-"`
+	Version int    `json:"-"`
+}
+
+// OrderStatusChange is one entry of an order's status history. From is empty
+// for the entry recording the order's creation
+type OrderStatusChange struct {
+	Id        string    `json:"id"`
+	OrderId   string    `json:"order_id"`
+	From      string    `json:"from_status"`
+	To        string    `json:"to_status"`
+	Note      string    `json:"note"`
+	UserId    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{OrderStatusPending, OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded}
+	allowed := map[[2]string]bool{
+		{OrderStatusPending, OrderStatusPaid}:       true,
+		{OrderStatusPending, OrderStatusCancelled}:  true,
+		{OrderStatusPaid, OrderStatusShipped}:       true,
+		{OrderStatusPaid, OrderStatusCancelled}:     true,
+		{OrderStatusPaid, OrderStatusRefunded}:      true,
+		{OrderStatusShipped, OrderStatusDelivered}:  true,
+		{OrderStatusDelivered, OrderStatusRefunded}: true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got, want := CanTransition(from, to), allowed[[2]string{from, to}]; got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+	if CanTransition("", OrderStatusPaid) || CanTransition(OrderStatusPending, "lost") {
+		t.Error("CanTransition allows an unknown status")
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	for status, want := range map[string]bool{
+		OrderStatusPending:   false,
+		OrderStatusPaid:      false,
+		OrderStatusShipped:   false,
+		OrderStatusDelivered: true,
+		OrderStatusCancelled: true,
+		OrderStatusRefunded:  true,
+	} {
+		if got := IsTerminal(status); got != want {
+			t.Errorf("IsTerminal(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
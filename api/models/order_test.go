@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestSetTotals(t *testing.T) {
+	tests := []struct {
+		name               string
+		subtotal, discount int64
+		taxRate            int
+		wantTax, wantTotal int64
+	}{
+		{"no tax", 2000, 0, 0, 0, 2000},
+		{"tax", 2000, 0, 825, 165, 2165},
+		{"discount before tax", 2000, 300, 825, 140, 1840},
+		{"rounds half up", 180, 0, 250, 5, 185},
+		{"rounds down below half", 170, 0, 250, 4, 174},
+		{"discount above subtotal", 1000, 1500, 825, 0, 0},
+		{"empty", 0, 0, 825, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{Discount: tt.discount, TaxRate: tt.taxRate}
+			order.SetTotals(tt.subtotal)
+			if order.Subtotal != tt.subtotal || order.Tax != tt.wantTax || order.Total != tt.wantTotal {
+				t.Errorf("SetTotals(%d) = subtotal %d tax %d total %d, want %d %d %d",
+					tt.subtotal, order.Subtotal, order.Tax, order.Total, tt.subtotal, tt.wantTax, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type PasswordReset struct {
+	Id        string     `json:"id"`
+	UserId    string     `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreatePasswordReset struct {
+	UserId    string    `json:"user_id"`
+	CodeHash  string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordResetPrimaryKey selects a reset by Id, or the pending one of UserId
+type PasswordResetPrimaryKey struct {
+	Id     string `json:"id"`
+	UserId string `json:"user_id"`
+}
+
+// PasswordResetAttempt spends one of the MaxAttempts guesses at a pending reset
+type PasswordResetAttempt struct {
+	Id          string `json:"id"`
+	MaxAttempts int    `json:"max_attempts"`
+}
+
+type PasswordResetCountRequest struct {
+	UserId string    `json:"user_id"`
+	Since  time.Time `json:"since"`
+}
+
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+type ResetPasswordRequest struct {
+	Username    string `json:"username"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
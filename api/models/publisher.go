@@ -0,0 +1,49 @@
+package models
+
+type Publisher struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Website string `json:"website"`
+	Version int    `json:"version"`
+}
+
+type CreatePublisher struct {
+	Name    string `json:"name"`
+	Website string `json:"website"`
+}
+
+type UpdatePublisher struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Website string `json:"website"`
+	Version int    `json:"-"`
+}
+
+// PatchPublisher changes only the fields that are set
+type PatchPublisher struct {
+	Id      string  `json:"-"`
+	Name    *string `json:"name"`
+	Website *string `json:"website"`
+	Version int     `json:"-"`
+}
+
+type PublisherGetListRequest struct {
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
+}
+
+type PublisherGetListResponse struct {
+	Count      *int         `json:"count,omitempty"`
+	Publishers []*Publisher `json:"publishers"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+}
+
+type PublisherPrimaryKey struct {
+	Id      string `json:"id"`
+	Version int    `json:"-"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	Id         string     `json:"id"`
+	UserId     string     `json:"user_id"`
+	FamilyId   string     `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy string     `json:"replaced_by"`
+}
+
+type CreateRefreshToken struct {
+	UserId    string    `json:"user_id"`
+	FamilyId  string    `json:"family_id"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RevokeRefreshToken revokes by Id, FamilyId or UserId, whichever is set first
+type RevokeRefreshToken struct {
+	Id         string `json:"id"`
+	FamilyId   string `json:"family_id"`
+	UserId     string `json:"user_id"`
+	ReplacedBy string `json:"replaced_by"`
+}
+
+type RefreshTokenPrimaryKey struct {
+	Id        string `json:"id"`
+	TokenHash string `json:"-"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
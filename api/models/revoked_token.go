@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// CreateRevokedToken revokes a single access token by Jti, or every token
+// issued to UserId up to now when Jti is empty
+type CreateRevokedToken struct {
+	Jti       string    `json:"jti"`
+	UserId    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PurgeRevokedTokens removes revocations that expired before Before, when the
+// tokens they cover can no longer be used anyway
+type PurgeRevokedTokens struct {
+	Before time.Time `json:"before"`
+}
+
+type RevokedTokenPrimaryKey struct {
+	Jti      string    `json:"jti"`
+	UserId   string    `json:"user_id"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+type LogoutRequest struct {
+	All          bool   `json:"all"`
+	RefreshToken string `json:"refresh_token"`
+}
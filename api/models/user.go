@@ -1,15 +1,59 @@
 package models
 
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
+	Id         string `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Age        int    `json:"age"`
+	Phone      string `json:"phone"`
+	Picture    string `json:"picture"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"-"`
+	CardNo     string `json:"card_no"`
+	Role       string `json:"role"`
+	IsVerified bool   `json:"is_verified"`
+	Version    int    `json:"version"`
+}
+
+// PrivateUser is what a user sees about themselves and what admins see; the
+// password hash is never included and CardNo is masked to the last four digits
+type PrivateUser struct {
+	Id         string `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Age        int    `json:"age"`
+	Phone      string `json:"phone"`
+	Picture    string `json:"picture"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	CardNo     string `json:"card_no"`
+	Role       string `json:"role"`
+	IsVerified bool   `json:"is_verified"`
+	Version    int    `json:"version"`
+}
+
+// PublicUser is the profile other users may see
+type PublicUser struct {
 	Id        string `json:"id"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Age       int    `json:"age"`
-	Phone     string `json:"phone"`
 	Picture   string `json:"picture"`
 	Username  string `json:"username"`
-	Password  string `json:"password"`
-	CardNo    string `json:"card_no"`
 }
 
 type CreateUser struct {
@@ -19,8 +63,10 @@ type CreateUser struct {
 	Phone     string `json:"phone"`
 	Picture   string `json:"picture"`
 	Username  string `json:"username"`
+	Email     string `json:"email"`
 	Password  string `json:"password"`
 	CardNo    string `json:"card_no"`
+	Role      string `json:"role"`
 }
 
 type UpdateUser struct {
@@ -31,26 +77,83 @@ type UpdateUser struct {
 	Phone     string `json:"phone"`
 	Picture   string `json:"picture"`
 	Username  string `json:"username"`
-	Password  string `json:"password"`
+	Email     string `json:"email"`
 	CardNo    string `json:"card_no"`
+	Role      string `json:"role"`
+	Version   int    `json:"-"`
+}
+
+// PatchUser changes only the fields that are set; credentials are not patchable
+type PatchUser struct {
+	Id        string  `json:"-"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Age       *int    `json:"age"`
+	Phone     *string `json:"phone"`
+	Picture   *string `json:"picture"`
+	Username  *string `json:"username"`
+	Email     *string `json:"email"`
+	CardNo    *string `json:"card_no"`
+	Role      *string `json:"role"`
+	Version   int     `json:"-"`
+}
+
+// UpdateProfile is the subset of PatchUser a user may change about themselves
+type UpdateProfile struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Age       *int    `json:"age"`
+	Phone     *string `json:"phone"`
+	Picture   *string `json:"picture"`
+	Email     *string `json:"email"`
+	CardNo    *string `json:"card_no"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
 }
 
 type UserGetListRequest struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+	Cursor  *Cursor  `json:"cursor"`
+	Count   bool     `json:"count"`
 }
 
 type UserGetListResponse struct {
-	Count int     `json:"count"`
-	Users []*User `json:"users"`
+	Count      *int    `json:"count,omitempty"`
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 }
 
+type PrivateUserGetListResponse struct {
+	Count      *int           `json:"count,omitempty"`
+	Users      []*PrivateUser `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// UserPrimaryKey looks a user up by Id, Username or Email, in that order
 type UserPrimaryKey struct {
 	Id       string `json:"id"`
 	Username string `json:"username"`
+	Email    string `json:"email"`
+	Version  int    `json:"-"`
+}
+
+type UpdatePassword struct {
+	Id       string `json:"id"`
+	Password string `json:"password"`
 }
 
+// UserLoginRequest may carry the id of the cart the user filled before
+// logging in, which is then merged into their own
 type UserLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	CartId   string `json:"cart_id"`
 }
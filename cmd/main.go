@@ -2,14 +2,21 @@ package main
 
 import (
 	"app/api"
+	"app/api/models"
 	"app/config"
+	"app/pkg/helper"
 	"app/pkg/logger"
+	"app/pkg/mailer"
+	"app/storage"
+	"app/storage/memory"
 	"app/storage/postgres"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/mattn/go-colorable"
 	"io"
 	"os"
+	"time"
 )
 
 func main() {
@@ -39,9 +46,27 @@ func main() {
 		}
 	}()
 
-	pgconn, err := postgres.NewConnectionPostgres(&cfg)
+	var (
+		strg storage.StorageInterface
+		err  error
+	)
+	switch cfg.StorageDriver {
+	case config.MemoryStorage:
+		strg, err = memory.NewConnectionMemory(&cfg)
+		if err != nil {
+			panic("memory storage: " + err.Error())
+		}
+	default:
+		strg, err = postgres.NewConnectionPostgres(&cfg)
+		if err != nil {
+			panic("postgres no connection: " + err.Error())
+		}
+	}
+	defer strg.Close()
+
+	err = seedAdmin(&cfg, strg)
 	if err != nil {
-		panic("postgres no connection: " + err.Error())
+		panic("seed admin: " + err.Error())
 	}
 
 	r := gin.New()
@@ -52,7 +77,14 @@ func main() {
 
 	r.Use(gin.Recovery(), gin.Logger())
 
-	api.NewApi(r, &cfg, pgconn, log)
+	mail, err := mailer.New(&cfg, log)
+	if err != nil {
+		panic("mailer: " + err.Error())
+	}
+	defer mail.Close()
+
+	api.NewApi(r, &cfg, strg, mail, log)
+	go purgeRevokedTokens(&cfg, strg, log)
 
 	fmt.Println("Listening server", cfg.ServerHost+cfg.HTTPPort)
 	err = r.Run(cfg.ServerHost + cfg.HTTPPort)
@@ -60,3 +92,47 @@ func main() {
 		panic(err)
 	}
 }
+
+// purgeRevokedTokens deletes expired token revocations every
+// REVOKED_TOKEN_PURGE_INTERVAL; a zero interval disables it
+func purgeRevokedTokens(cfg *config.Config, strg storage.StorageInterface, log logger.LoggerI) {
+	if cfg.RevokedTokenPurgeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cfg.RevokedTokenPurgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		_, err := strg.RevokedToken().Purge(context.Background(), &models.PurgeRevokedTokens{Before: time.Now()})
+		if err != nil {
+			log.Error("error while purging revoked tokens", logger.Error(err))
+		}
+	}
+}
+
+// seedAdmin creates the ADMIN_USERNAME account with the admin role when it
+// does not exist yet, so a fresh database has someone to manage roles
+func seedAdmin(cfg *config.Config, strg storage.StorageInterface) error {
+	if cfg.AdminUsername == "" || cfg.AdminPassword == "" {
+		return nil
+	}
+
+	_, err := strg.Users().GetById(context.Background(), &models.UserPrimaryKey{Username: cfg.AdminUsername})
+	if err == nil {
+		return nil
+	}
+	if err.Error() != fmt.Errorf("no rows in result set").Error() {
+		return err
+	}
+
+	hashedPassword, err := helper.HashPassword(cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = strg.Users().Create(context.Background(), &models.CreateUser{
+		Username: cfg.AdminUsername,
+		Password: hashedPassword,
+		Role:     models.RoleAdmin,
+	})
+	return err
+}
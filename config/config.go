@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cast"
@@ -14,12 +15,19 @@ const (
 	ReleaseMode = "release"
 )
 
+const (
+	PostgresStorage = "postgres"
+	MemoryStorage   = "memory"
+)
+
 type Config struct {
 	Environment string
 
 	ServerHost string
 	HTTPPort   string
 
+	StorageDriver string
+
 	PostgresHost          string
 	PostgresUser          string
 	PostgresDatabase      string
@@ -27,9 +35,61 @@ type Config struct {
 	PostgresPort          int
 	PostgresMaxConnection int32
 
-	DefaultOffset int
-	DefaultLimit  int
-	SecretKey     string
+	DefaultOffset   int
+	DefaultLimit    int
+	MaxLimit        int
+	DefaultCurrency string
+	TaxRate         int
+	SecretKey       string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	RevokedTokenPurgeInterval time.Duration
+
+	CookieDomain string
+	CookieSecure bool
+
+	AdminUsername string
+	AdminPassword string
+
+	MailDriver       string
+	MailOutboxDir    string
+	MailWorkers      int
+	MailQueueSize    int
+	MailRetries      int
+	MailRetryBackoff time.Duration
+
+	SMTPHost               string
+	SMTPPort               int
+	SMTPUsername           string
+	SMTPPassword           string
+	SMTPFrom               string
+	SMTPTLS                string
+	SMTPInsecureSkipVerify bool
+
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+
+	PasswordResetTTL         time.Duration
+	PasswordResetMaxAttempts int
+	PasswordResetMaxPerHour  int
+
+	PublicURL                   string
+	EmailVerificationTTL        time.Duration
+	EmailVerificationMaxPerHour int
+	RequireVerifiedEmail        bool
+
+	LoginFailureWindow   time.Duration
+	LoginMaxFailures     int
+	LoginLockoutDuration time.Duration
+	LoginBackoffBase     time.Duration
+	LoginBackoffMax      time.Duration
+	LoginIPMaxFailures   int
 }
 
 func Load() Config {
@@ -42,12 +102,15 @@ func Load() Config {
 
 	cfg.DefaultOffset = 0
 	cfg.DefaultLimit = 10
+	cfg.MaxLimit = 100
 
 	cfg.Environment = cast.ToString(getOrReturnDefaultValue("ENVIRONMENT", DebugMode))
 
 	cfg.ServerHost = cast.ToString(getOrReturnDefaultValue("SERVER_HOST", "localhost:"))
 	cfg.HTTPPort = cast.ToString(getOrReturnDefaultValue("HTTP_PORT", "8000"))
 
+	cfg.StorageDriver = cast.ToString(getOrReturnDefaultValue("STORAGE_DRIVER", PostgresStorage))
+
 	cfg.PostgresHost = cast.ToString(getOrReturnDefaultValue("POSTGRES_HOST", "localhost"))
 	cfg.PostgresUser = cast.ToString(getOrReturnDefaultValue("POSTGRES_USER", "doniy"))
 	cfg.PostgresDatabase = cast.ToString(getOrReturnDefaultValue("POSTGRES_DATABASE", "app"))
@@ -58,7 +121,62 @@ func Load() Config {
 
 	cfg.DefaultOffset = cast.ToInt(getOrReturnDefaultValue("OFFSET", 0))
 	cfg.DefaultLimit = cast.ToInt(getOrReturnDefaultValue("LIMIT", 10))
+	cfg.MaxLimit = cast.ToInt(getOrReturnDefaultValue("MAX_LIMIT", 100))
+	cfg.DefaultCurrency = cast.ToString(getOrReturnDefaultValue("DEFAULT_CURRENCY", "USD"))
+	cfg.TaxRate = cast.ToInt(getOrReturnDefaultValue("TAX_RATE_BPS", 0))
 	cfg.SecretKey = cast.ToString(getOrReturnDefaultValue("SECRET_KEY", "SECRET"))
+
+	cfg.AccessTokenTTL = cast.ToDuration(getOrReturnDefaultValue("ACCESS_TOKEN_TTL", "15m"))
+	cfg.RefreshTokenTTL = cast.ToDuration(getOrReturnDefaultValue("REFRESH_TOKEN_TTL", "720h"))
+	cfg.RevokedTokenPurgeInterval = cast.ToDuration(getOrReturnDefaultValue("REVOKED_TOKEN_PURGE_INTERVAL", "1h"))
+
+	cfg.CookieDomain = cast.ToString(getOrReturnDefaultValue("COOKIE_DOMAIN", "localhost"))
+	cfg.CookieSecure = cast.ToBool(getOrReturnDefaultValue("COOKIE_SECURE", false))
+
+	cfg.AdminUsername = cast.ToString(getOrReturnDefaultValue("ADMIN_USERNAME", ""))
+	cfg.AdminPassword = cast.ToString(getOrReturnDefaultValue("ADMIN_PASSWORD", ""))
+
+	cfg.SMTPHost = cast.ToString(getOrReturnDefaultValue("SMTP_HOST", ""))
+	cfg.SMTPPort = cast.ToInt(getOrReturnDefaultValue("SMTP_PORT", 587))
+	cfg.SMTPUsername = cast.ToString(getOrReturnDefaultValue("SMTP_USERNAME", ""))
+	cfg.SMTPPassword = cast.ToString(getOrReturnDefaultValue("SMTP_PASSWORD", ""))
+	cfg.SMTPFrom = cast.ToString(getOrReturnDefaultValue("SMTP_FROM", cfg.SMTPUsername))
+	cfg.SMTPTLS = cast.ToString(getOrReturnDefaultValue("SMTP_TLS", "starttls"))
+	cfg.SMTPInsecureSkipVerify = cast.ToBool(getOrReturnDefaultValue("SMTP_INSECURE_SKIP_VERIFY", false))
+
+	defaultMailDriver := "log"
+	if cfg.SMTPHost != "" {
+		defaultMailDriver = "smtp"
+	}
+	cfg.MailDriver = cast.ToString(getOrReturnDefaultValue("MAIL_DRIVER", defaultMailDriver))
+	cfg.MailOutboxDir = cast.ToString(getOrReturnDefaultValue("MAIL_OUTBOX_DIR", "./outbox"))
+	cfg.MailWorkers = cast.ToInt(getOrReturnDefaultValue("MAIL_WORKERS", 2))
+	cfg.MailQueueSize = cast.ToInt(getOrReturnDefaultValue("MAIL_QUEUE_SIZE", 100))
+	cfg.MailRetries = cast.ToInt(getOrReturnDefaultValue("MAIL_RETRIES", 3))
+	cfg.MailRetryBackoff = cast.ToDuration(getOrReturnDefaultValue("MAIL_RETRY_BACKOFF", "2s"))
+
+	cfg.PasswordMinLength = cast.ToInt(getOrReturnDefaultValue("PASSWORD_MIN_LENGTH", 8))
+	cfg.PasswordMaxLength = cast.ToInt(getOrReturnDefaultValue("PASSWORD_MAX_LENGTH", 72))
+	cfg.PasswordRequireUpper = cast.ToBool(getOrReturnDefaultValue("PASSWORD_REQUIRE_UPPER", false))
+	cfg.PasswordRequireLower = cast.ToBool(getOrReturnDefaultValue("PASSWORD_REQUIRE_LOWER", true))
+	cfg.PasswordRequireDigit = cast.ToBool(getOrReturnDefaultValue("PASSWORD_REQUIRE_DIGIT", true))
+	cfg.PasswordRequireSymbol = cast.ToBool(getOrReturnDefaultValue("PASSWORD_REQUIRE_SYMBOL", false))
+
+	cfg.PasswordResetTTL = cast.ToDuration(getOrReturnDefaultValue("PASSWORD_RESET_TTL", "15m"))
+	cfg.PasswordResetMaxAttempts = cast.ToInt(getOrReturnDefaultValue("PASSWORD_RESET_MAX_ATTEMPTS", 5))
+	cfg.PasswordResetMaxPerHour = cast.ToInt(getOrReturnDefaultValue("PASSWORD_RESET_MAX_PER_HOUR", 3))
+
+	cfg.PublicURL = cast.ToString(getOrReturnDefaultValue("PUBLIC_URL", "http://localhost:8000"))
+	cfg.EmailVerificationTTL = cast.ToDuration(getOrReturnDefaultValue("EMAIL_VERIFICATION_TTL", "24h"))
+	cfg.EmailVerificationMaxPerHour = cast.ToInt(getOrReturnDefaultValue("EMAIL_VERIFICATION_MAX_PER_HOUR", 3))
+	cfg.RequireVerifiedEmail = cast.ToBool(getOrReturnDefaultValue("REQUIRE_VERIFIED_EMAIL", true))
+
+	cfg.LoginFailureWindow = cast.ToDuration(getOrReturnDefaultValue("LOGIN_FAILURE_WINDOW", "1h"))
+	cfg.LoginMaxFailures = cast.ToInt(getOrReturnDefaultValue("LOGIN_MAX_FAILURES", 10))
+	cfg.LoginLockoutDuration = cast.ToDuration(getOrReturnDefaultValue("LOGIN_LOCKOUT_DURATION", "15m"))
+	cfg.LoginBackoffBase = cast.ToDuration(getOrReturnDefaultValue("LOGIN_BACKOFF_BASE", "1s"))
+	cfg.LoginBackoffMax = cast.ToDuration(getOrReturnDefaultValue("LOGIN_BACKOFF_MAX", "1m"))
+	cfg.LoginIPMaxFailures = cast.ToInt(getOrReturnDefaultValue("LOGIN_IP_MAX_FAILURES", 50))
 	return cfg
 }
 
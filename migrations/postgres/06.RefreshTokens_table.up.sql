@@ -0,0 +1,10 @@
+CREATE TABLE refresh_tokens(
+    id uuid PRIMARY KEY,
+    user_id uuid REFERENCES users(id),
+    family_id uuid NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    replaced_by uuid,
+    created_at TIMESTAMP DEFAULT NOW()
+)
\ No newline at end of file
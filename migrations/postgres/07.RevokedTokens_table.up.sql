@@ -0,0 +1,7 @@
+CREATE TABLE revoked_tokens(
+    id uuid PRIMARY KEY,
+    jti uuid UNIQUE,
+    user_id uuid REFERENCES users(id),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP NOT NULL
+)
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN email VARCHAR
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE password_resets(
+    id uuid PRIMARY KEY,
+    user_id uuid REFERENCES users(id),
+    code_hash VARCHAR NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+)
\ No newline at end of file
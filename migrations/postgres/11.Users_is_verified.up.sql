@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN is_verified BOOLEAN NOT NULL DEFAULT FALSE;
+-- accounts created before verification existed are grandfathered in
+UPDATE users SET is_verified = TRUE;
+CREATE UNIQUE INDEX users_email_key ON users(LOWER(email));
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE email_verifications(
+    id uuid PRIMARY KEY,
+    user_id uuid REFERENCES users(id),
+    email VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+)
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE login_attempts(
+    id uuid PRIMARY KEY,
+    username VARCHAR NOT NULL,
+    user_id uuid REFERENCES users(id),
+    ip VARCHAR NOT NULL,
+    user_agent VARCHAR,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX login_attempts_username_idx ON login_attempts(username, created_at);
+CREATE INDEX login_attempts_ip_idx ON login_attempts(ip, created_at);
\ No newline at end of file
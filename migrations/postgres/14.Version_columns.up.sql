@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE books ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE order_items ADD COLUMN version INT NOT NULL DEFAULT 1;
\ No newline at end of file
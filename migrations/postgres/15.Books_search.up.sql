@@ -0,0 +1,19 @@
+CREATE OR REPLACE FUNCTION book_search_config(lang VARCHAR) RETURNS regconfig AS $$
+    SELECT CASE LOWER(lang)
+        WHEN 'en' THEN 'english'
+        WHEN 'ru' THEN 'russian'
+        WHEN 'de' THEN 'german'
+        WHEN 'fr' THEN 'french'
+        WHEN 'es' THEN 'spanish'
+        WHEN 'it' THEN 'italian'
+        WHEN 'pt' THEN 'portuguese'
+        WHEN 'tr' THEN 'turkish'
+        ELSE 'simple'
+    END::regconfig
+$$ LANGUAGE SQL IMMUTABLE;
+ALTER TABLE books ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector(book_search_config(lang), COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector(book_search_config(lang), COALESCE(author, '')), 'B') ||
+    setweight(to_tsvector(book_search_config(lang), COALESCE(publisher, '')), 'C')
+) STORED;
+CREATE INDEX books_search_vector_idx ON books USING GIN (search_vector);
\ No newline at end of file
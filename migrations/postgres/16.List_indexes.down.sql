@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_created_at_idx;
+DROP INDEX IF EXISTS categories_created_at_idx;
+DROP INDEX IF EXISTS users_created_at_idx;
+DROP INDEX IF EXISTS orders_created_at_idx;
+DROP INDEX IF EXISTS order_items_created_at_idx;
\ No newline at end of file
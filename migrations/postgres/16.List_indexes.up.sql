@@ -0,0 +1,5 @@
+CREATE INDEX books_created_at_idx ON books (created_at DESC, id DESC) WHERE is_deleted = FALSE;
+CREATE INDEX categories_created_at_idx ON categories (created_at DESC, id DESC) WHERE is_deleted = FALSE;
+CREATE INDEX users_created_at_idx ON users (created_at DESC, id DESC) WHERE is_deleted = FALSE;
+CREATE INDEX orders_created_at_idx ON orders (created_at DESC, order_id DESC) WHERE is_deleted = FALSE;
+CREATE INDEX order_items_created_at_idx ON order_items (created_at DESC, item_id DESC) WHERE is_deleted = FALSE;
\ No newline at end of file
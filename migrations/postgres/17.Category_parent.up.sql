@@ -0,0 +1,3 @@
+ALTER TABLE categories ADD COLUMN parent_id uuid REFERENCES categories(id);
+ALTER TABLE categories ADD CONSTRAINT categories_parent_id_check CHECK (parent_id <> id);
+CREATE INDEX categories_parent_id_idx ON categories (parent_id) WHERE is_deleted = FALSE;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN category uuid REFERENCES categories(id);
+UPDATE books b SET category = (SELECT category_id FROM book_categories WHERE book_id = b.id ORDER BY created_at LIMIT 1);
+DROP TABLE IF EXISTS book_tags;
+DROP TABLE IF EXISTS book_categories;
\ No newline at end of file
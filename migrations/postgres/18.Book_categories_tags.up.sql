@@ -0,0 +1,16 @@
+CREATE TABLE book_categories(
+    book_id uuid REFERENCES books(id),
+    category_id uuid REFERENCES categories(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (book_id, category_id)
+);
+CREATE INDEX book_categories_category_id_idx ON book_categories (category_id);
+INSERT INTO book_categories(book_id, category_id) SELECT id, category FROM books WHERE category IS NOT NULL;
+ALTER TABLE books DROP COLUMN category;
+CREATE TABLE book_tags(
+    book_id uuid REFERENCES books(id),
+    tag VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (book_id, tag)
+);
+CREATE INDEX book_tags_tag_idx ON book_tags (tag);
\ No newline at end of file
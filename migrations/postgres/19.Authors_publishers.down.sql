@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_publisher_id_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS publisher_id;
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS publishers;
+DROP TABLE IF EXISTS authors;
\ No newline at end of file
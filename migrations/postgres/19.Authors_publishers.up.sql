@@ -0,0 +1,45 @@
+CREATE TABLE authors(
+    id uuid PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    bio VARCHAR NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX authors_created_at_idx ON authors (created_at DESC, id DESC) WHERE is_deleted = FALSE;
+CREATE TABLE publishers(
+    id uuid PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    website VARCHAR NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX publishers_created_at_idx ON publishers (created_at DESC, id DESC) WHERE is_deleted = FALSE;
+CREATE TABLE book_authors(
+    book_id uuid REFERENCES books(id),
+    author_id uuid REFERENCES authors(id),
+    role VARCHAR NOT NULL DEFAULT 'author' CHECK (role IN ('author', 'translator', 'editor')),
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role)
+);
+CREATE INDEX book_authors_author_id_idx ON book_authors (author_id);
+ALTER TABLE books ADD COLUMN publisher_id uuid REFERENCES publishers(id);
+CREATE INDEX books_publisher_id_idx ON books (publisher_id) WHERE is_deleted = FALSE;
+INSERT INTO authors(id, name)
+SELECT gen_random_uuid(), mode() WITHIN GROUP (ORDER BY REGEXP_REPLACE(TRIM(author), '\s+', ' ', 'g'))
+FROM books WHERE TRIM(author) <> ''
+GROUP BY LOWER(REGEXP_REPLACE(TRIM(author), '\s+', ' ', 'g'));
+INSERT INTO publishers(id, name)
+SELECT gen_random_uuid(), mode() WITHIN GROUP (ORDER BY REGEXP_REPLACE(TRIM(publisher), '\s+', ' ', 'g'))
+FROM books WHERE TRIM(publisher) <> ''
+GROUP BY LOWER(REGEXP_REPLACE(TRIM(publisher), '\s+', ' ', 'g'));
+INSERT INTO book_authors(book_id, author_id)
+SELECT b.id, a.id FROM books b
+JOIN authors a ON LOWER(a.name) = LOWER(REGEXP_REPLACE(TRIM(b.author), '\s+', ' ', 'g'));
+UPDATE books b SET publisher_id = p.id FROM publishers p
+WHERE LOWER(p.name) = LOWER(REGEXP_REPLACE(TRIM(b.publisher), '\s+', ' ', 'g'));
+UPDATE books b SET author = COALESCE((SELECT a.name FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE ba.book_id = b.id), ''),
+    publisher = COALESCE((SELECT p.name FROM publishers p WHERE p.id = b.publisher_id), '');
\ No newline at end of file
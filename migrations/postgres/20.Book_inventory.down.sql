@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS inventory_movements;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_stock_check;
+ALTER TABLE books DROP COLUMN IF EXISTS reserved_quantity;
+ALTER TABLE books DROP COLUMN IF EXISTS stock_quantity;
+ALTER TABLE books DROP COLUMN IF EXISTS currency;
+ALTER TABLE books DROP COLUMN IF EXISTS price;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE books ADD COLUMN price BIGINT NOT NULL DEFAULT 0 CHECK (price >= 0);
+ALTER TABLE books ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE books ADD COLUMN stock_quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN reserved_quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE books ADD CONSTRAINT books_stock_check CHECK (reserved_quantity >= 0 AND stock_quantity >= reserved_quantity);
+CREATE TABLE inventory_movements(
+    id uuid PRIMARY KEY,
+    book_id uuid NOT NULL REFERENCES books(id),
+    delta INT NOT NULL,
+    reserved_delta INT NOT NULL DEFAULT 0,
+    reason VARCHAR NOT NULL,
+    note VARCHAR NOT NULL DEFAULT '',
+    user_id uuid REFERENCES users(id),
+    stock_quantity INT NOT NULL,
+    reserved_quantity INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX inventory_movements_book_id_idx ON inventory_movements (book_id, created_at DESC, id DESC);
\ No newline at end of file
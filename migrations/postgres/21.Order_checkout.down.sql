@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS inventory_movements_order_id_idx;
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS order_id;
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency;
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price;
+ALTER TABLE order_items DROP COLUMN IF EXISTS quantity;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE order_items ADD COLUMN quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0);
+ALTER TABLE order_items ADD COLUMN unit_price BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT '';
+UPDATE order_items oi SET unit_price = b.price, currency = b.currency FROM books b WHERE b.id = oi.book_id;
+UPDATE orders SET status = 'pending' WHERE status IS NULL;
+ALTER TABLE inventory_movements ADD COLUMN order_id uuid REFERENCES orders(order_id);
+CREATE INDEX inventory_movements_order_id_idx ON inventory_movements (order_id) WHERE order_id IS NOT NULL;
\ No newline at end of file
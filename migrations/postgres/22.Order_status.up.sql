@@ -0,0 +1,15 @@
+UPDATE orders SET status = 'pending' WHERE status IS NULL;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE orders ALTER COLUMN status SET NOT NULL;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
+CREATE TABLE order_status_history(
+    id uuid PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(order_id),
+    from_status VARCHAR,
+    to_status VARCHAR NOT NULL,
+    note VARCHAR NOT NULL DEFAULT '',
+    user_id uuid REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX order_status_history_order_id_idx ON order_status_history (order_id, created_at);
+INSERT INTO order_status_history(id, order_id, to_status, created_at) SELECT gen_random_uuid(), order_id, status, created_at FROM orders;
\ No newline at end of file
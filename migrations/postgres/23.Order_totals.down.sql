@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
+ALTER TABLE order_items DROP COLUMN IF EXISTS line_total;
\ No newline at end of file
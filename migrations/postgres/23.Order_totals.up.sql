@@ -0,0 +1,3 @@
+ALTER TABLE order_items ADD COLUMN line_total BIGINT GENERATED ALWAYS AS (quantity * unit_price) STORED;
+ALTER TABLE orders ADD COLUMN discount BIGINT NOT NULL DEFAULT 0 CHECK (discount >= 0);
+ALTER TABLE orders ADD COLUMN tax_rate INT NOT NULL DEFAULT 0 CHECK (tax_rate >= 0);
\ No newline at end of file
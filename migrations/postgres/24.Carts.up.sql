@@ -0,0 +1,14 @@
+CREATE TABLE carts(
+    cart_id uuid PRIMARY KEY,
+    user_id uuid UNIQUE REFERENCES users(id),
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE cart_items(
+    cart_id uuid NOT NULL REFERENCES carts(cart_id) ON DELETE CASCADE,
+    book_id uuid NOT NULL REFERENCES books(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (cart_id, book_id)
+);
\ No newline at end of file
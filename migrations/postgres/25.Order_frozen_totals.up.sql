@@ -0,0 +1,6 @@
+ALTER TABLE orders ADD COLUMN subtotal BIGINT CHECK (subtotal >= 0);
+ALTER TABLE orders ADD COLUMN currency VARCHAR(3);
+UPDATE orders SET
+    subtotal = (SELECT COALESCE(SUM(i.line_total), 0) FROM order_items i WHERE i.order_id = orders.order_id AND i.is_deleted = FALSE),
+    currency = (SELECT COALESCE(MIN(i.currency), '') FROM order_items i WHERE i.order_id = orders.order_id AND i.is_deleted = FALSE)
+WHERE status <> 'pending';
\ No newline at end of file
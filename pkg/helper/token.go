@@ -1,18 +1,28 @@
 package helper
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/spf13/cast"
 )
 
 type TokenInfo struct {
-	UserID     string `json:"user_id"`
-	ClientType string `json:"client_type"`
-	PlatformID string `json:"platform_id"`
+	UserID     string    `json:"user_id"`
+	ClientType string    `json:"client_type"`
+	PlatformID string    `json:"platform_id"`
+	Role       string    `json:"role"`
+	Jti        string    `json:"jti"`
+	IssuedAt   time.Time `json:"iat"`
+	ExpiresAt  time.Time `json:"exp"`
 }
 
 // GenerateJWT ...
@@ -27,8 +37,12 @@ func GenerateJWT(m map[string]interface{}, tokenExpireTime time.Duration, tokenS
 		claims[key] = value
 	}
 
-	claims["iat"] = time.Now().Unix()
-	claims["exp"] = time.Now().Add(tokenExpireTime).Unix()
+	// iat keeps milliseconds so that a token issued just before a user-wide
+	// revocation in the same second is still covered by it
+	now := time.Now()
+	claims["jti"] = uuid.New().String()
+	claims["iat"] = float64(now.UnixMilli()) / 1000
+	claims["exp"] = now.Add(tokenExpireTime).Unix()
 
 	tokenString, err = token.SignedString([]byte(tokenSecretKey))
 	if err != nil {
@@ -49,6 +63,10 @@ func ParseClaims(token string, secretKey string) (result TokenInfo, err error) {
 	result.UserID = cast.ToString(claims["user_id"])
 	result.ClientType = cast.ToString(claims["client_type"])
 	result.PlatformID = cast.ToString(claims["platform_id"])
+	result.Role = cast.ToString(claims["role"])
+	result.Jti = cast.ToString(claims["jti"])
+	result.IssuedAt = time.UnixMilli(int64(math.Round(cast.ToFloat64(claims["iat"]) * 1000)))
+	result.ExpiresAt = time.Unix(cast.ToInt64(claims["exp"]), 0)
 	if len(result.UserID) <= 0 {
 		err = errors.New("cannot parse 'user_id' field")
 		return result, err
@@ -65,7 +83,9 @@ func ExtractClaims(tokenString string, tokenSecretKey string) (jwt.MapClaims, er
 	)
 
 	token, err = jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// check token signing method etc
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
 		return []byte(tokenSecretKey), nil
 	})
 
@@ -84,8 +104,24 @@ func ExtractClaims(tokenString string, tokenSecretKey string) (jwt.MapClaims, er
 // ExtractToken checks and returns token part of input string
 func ExtractToken(bearer string) (token string, err error) {
 	strArr := strings.Split(bearer, " ")
-	if len(strArr) == 2 {
+	if len(strArr) == 2 && strings.EqualFold(strArr[0], "Bearer") && strArr[1] != "" {
 		return strArr[1], nil
 	}
 	return token, errors.New("wrong token format")
 }
+
+// GenerateOpaqueToken returns a url-safe random token for refresh sessions and emailed links
+func GenerateOpaqueToken() (string, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken returns the hex encoded sha256 of token, used to store tokens at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
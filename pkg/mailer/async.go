@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"app/pkg/logger"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+type AsyncOptions struct {
+	Workers   int
+	QueueSize int
+	Retries   int
+	Backoff   time.Duration
+}
+
+// AsyncMailer queues messages and delivers them from background workers,
+// retrying failed sends with exponential backoff
+type AsyncMailer struct {
+	next    Mailer
+	log     logger.LoggerI
+	opts    AsyncOptions
+	queue   chan *Message
+	wg      sync.WaitGroup
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+func NewAsyncMailer(next Mailer, log logger.LoggerI, opts AsyncOptions) *AsyncMailer {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+
+	m := &AsyncMailer{
+		next:  next,
+		log:   log,
+		opts:  opts,
+		queue: make(chan *Message, opts.QueueSize),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+	return m
+}
+
+// Send enqueues msg and returns without waiting for delivery
+func (m *AsyncMailer) Send(ctx context.Context, msg *Message) error {
+	m.closeMu.RLock()
+	defer m.closeMu.RUnlock()
+
+	if m.closed {
+		return errors.New("mailer is closed")
+	}
+
+	select {
+	case m.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for the queue to drain
+func (m *AsyncMailer) Close() {
+	m.closeMu.Lock()
+	if m.closed {
+		m.closeMu.Unlock()
+		return
+	}
+	m.closed = true
+	close(m.queue)
+	m.closeMu.Unlock()
+
+	m.wg.Wait()
+}
+
+func (m *AsyncMailer) work() {
+	defer m.wg.Done()
+
+	for msg := range m.queue {
+		m.deliver(msg)
+	}
+}
+
+func (m *AsyncMailer) deliver(msg *Message) {
+	var (
+		err     error
+		backoff = m.opts.Backoff
+	)
+	for attempt := 0; attempt <= m.opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		err = m.next.Send(context.Background(), msg)
+		if err == nil {
+			return
+		}
+		m.log.Warn("mail delivery failed",
+			logger.String("to", msg.To),
+			logger.Int("attempt", attempt+1),
+			logger.Error(err),
+		)
+	}
+	m.log.Error("mail dropped", logger.String("to", msg.To), logger.String("subject", msg.Subject), logger.Error(err))
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+	"app/pkg/logger"
+	"context"
+)
+
+type logMailer struct {
+	log logger.LoggerI
+}
+
+// NewLogMailer returns a Mailer that writes messages to the log instead of sending them
+func NewLogMailer(log logger.LoggerI) Mailer {
+	return &logMailer{
+		log: log,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg *Message) error {
+	m.log.Info("mail",
+		logger.String("to", msg.To),
+		logger.String("subject", msg.Subject),
+		logger.String("text", msg.Text),
+	)
+	return nil
+}
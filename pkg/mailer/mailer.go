@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"app/config"
+	"app/pkg/logger"
+	"context"
+	"fmt"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverOutbox = "outbox"
+	DriverLog    = "log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers a single message; implementations must be safe for concurrent use
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New builds the mailer selected by MAIL_DRIVER and wraps it in an
+// AsyncMailer so handlers never wait on the mail server
+func New(cfg *config.Config, log logger.LoggerI) (*AsyncMailer, error) {
+	var m Mailer
+
+	switch cfg.MailDriver {
+	case DriverSMTP:
+		m = NewSMTPMailer(cfg)
+	case DriverOutbox:
+		outbox, err := NewOutboxMailer(cfg.MailOutboxDir)
+		if err != nil {
+			return nil, err
+		}
+		m = outbox
+	case DriverLog:
+		m = NewLogMailer(log)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+
+	return NewAsyncMailer(m, log, AsyncOptions{
+		Workers:   cfg.MailWorkers,
+		QueueSize: cfg.MailQueueSize,
+		Retries:   cfg.MailRetries,
+		Backoff:   cfg.MailRetryBackoff,
+	}), nil
+}
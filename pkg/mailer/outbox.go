@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type outboxMailer struct {
+	dir string
+}
+
+// NewOutboxMailer returns a Mailer that writes every message as an .eml file
+// into dir, for local development and tests
+func NewOutboxMailer(dir string) (Mailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &outboxMailer{
+		dir: dir,
+	}, nil
+}
+
+func (m *outboxMailer) Send(ctx context.Context, msg *Message) error {
+	var (
+		b        strings.Builder
+		boundary = uuid.New().String()
+		name     = fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), boundary[:8])
+	)
+
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.Text)
+	if msg.HTML != "" {
+		fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, msg.HTML)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(b.String()), 0o644)
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"app/config"
+	"context"
+	"crypto/tls"
+
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	// TLSStartTLS upgrades a plain connection, usually on port 587
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS from the start, usually on port 465
+	TLSImplicit = "tls"
+)
+
+type smtpMailer struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+func NewSMTPMailer(cfg *config.Config) Mailer {
+	dialer := gomail.NewDialer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+	dialer.SSL = cfg.SMTPTLS == TLSImplicit
+	dialer.TLSConfig = &tls.Config{
+		ServerName:         cfg.SMTPHost,
+		InsecureSkipVerify: cfg.SMTPInsecureSkipVerify,
+	}
+
+	return &smtpMailer{
+		dialer: dialer,
+		from:   cfg.SMTPFrom,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	message := gomail.NewMessage()
+	message.SetHeader("From", m.from)
+	message.SetHeader("To", msg.To)
+	message.SetHeader("Subject", msg.Subject)
+	message.SetBody("text/plain", msg.Text)
+	if msg.HTML != "" {
+		message.AddAlternative("text/html", msg.HTML)
+	}
+
+	return m.dialer.DialAndSend(message)
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
+
+// Render builds a message for to from the templates named name: name.txt
+// holds the plain text body and a "subject" block, name.html the HTML body
+func Render(to string, name string, data interface{}) (*Message, error) {
+	var subject, text, html bytes.Buffer
+
+	err := textTemplates.ExecuteTemplate(&subject, name+".subject", data)
+	if err != nil {
+		return nil, err
+	}
+	err = textTemplates.ExecuteTemplate(&text, name+".txt", data)
+	if err != nil {
+		return nil, err
+	}
+	err = htmlTemplates.ExecuteTemplate(&html, name+".html", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package password
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     []string
+	}{
+		{"acceptable", "Str0ng!pass", "reader", nil},
+		{"short", "Ab1!", "", []string{RuleMinLength}},
+		{"length in runes", "Pässwö1!", "", nil},
+		{"long", "Aa1!" + strings.Repeat("x", 69), "", []string{RuleMaxLength}},
+		{"no upper", "weak1!pass", "", []string{RuleUpper}},
+		{"no lower", "WEAK1!PASS", "", []string{RuleLower}},
+		{"no digit", "Weak!pass", "", []string{RuleDigit}},
+		{"space is a symbol", "Weak1 pass", "", nil},
+		{"no symbol", "Weak1pass", "", []string{RuleSymbol}},
+		{"username", "Hi!Reader99", "reader", []string{RuleUsername}},
+		{"everything", "", "", []string{RuleMinLength, RuleUpper, RuleLower, RuleDigit, RuleSymbol}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.Check(tt.password, tt.username) {
+				got = append(got, violation.Rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check(%q, %q) = %v, want %v", tt.password, tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCommon(t *testing.T) {
+	violations := Policy{MaxLength: 72}.Check("PassWord", "")
+	if len(violations) != 1 || violations[0].Rule != RuleCommon {
+		t.Errorf("Check(%q) = %v, want only %s", "PassWord", violations, RuleCommon)
+	}
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type authorRow struct {
+	meta
+	author models.Author
+}
+
+func (row *authorRow) field(name string) interface{} {
+	switch name {
+	case "id":
+		return row.author.Id
+	case "name":
+		return row.author.Name
+	}
+	return row.meta.field(name)
+}
+
+func (row *authorRow) key() string {
+	return row.author.Id
+}
+
+type AuthorRepo struct {
+	db *database
+}
+
+func (s AuthorRepo) Create(ctx context.Context, req *models.CreateAuthor) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.authors = append(s.db.authors, &authorRow{
+		meta: newMeta(),
+		author: models.Author{
+			Id:      id,
+			Version: 1,
+			Name:    req.Name,
+			Bio:     req.Bio,
+		},
+	})
+	return id, nil
+}
+
+func (s AuthorRepo) Update(ctx context.Context, req *models.UpdateAuthor) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.authors {
+		if row.author.Id != req.Id {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.author.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		row.author = models.Author{
+			Id:      req.Id,
+			Version: row.author.Version,
+			Name:    req.Name,
+			Bio:     req.Bio,
+		}
+		row.updatedAt = time.Now()
+		row.author.Version++
+		affected++
+	}
+	if affected > 0 {
+		s.db.refreshBookNames()
+	}
+	return affected, nil
+}
+
+func (s AuthorRepo) Patch(ctx context.Context, req *models.PatchAuthor) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.authors {
+		if row.author.Id != req.Id || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.author.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if req.Name != nil {
+			row.author.Name = *req.Name
+		}
+		if req.Bio != nil {
+			row.author.Bio = *req.Bio
+		}
+		row.updatedAt = time.Now()
+		row.author.Version++
+		affected++
+	}
+	if affected > 0 && req.Name != nil {
+		s.db.refreshBookNames()
+	}
+	return affected, nil
+}
+
+func (s AuthorRepo) GetById(ctx context.Context, req *models.AuthorPrimaryKey) (*models.Author, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.authors {
+		if !row.isDeleted && row.author.Id == req.Id {
+			author := row.author
+			return &author, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s AuthorRepo) GetList(ctx context.Context, req *models.AuthorGetListRequest) (*models.AuthorGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.AuthorGetListResponse{}
+		rows []*authorRow
+	)
+	for _, row := range s.db.authors {
+		if row.isDeleted {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&authorRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		author := row.author
+		resp.Authors = append(resp.Authors, &author)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s AuthorRepo) Delete(ctx context.Context, req *models.AuthorPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.authors {
+		if row.author.Id == req.Id {
+			if ok, err := checkVersion(row.meta, row.author.Version, req.Version); !ok {
+				return err
+			}
+			row.isDeleted = true
+			row.author.Version++
+			row.updatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (db *database) author(id string) *authorRow {
+	for _, row := range db.authors {
+		if row.author.Id == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func NewAuthorRepo(db *database) *AuthorRepo {
+	return &AuthorRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,492 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type bookRow struct {
+	meta
+	book        models.Book
+	authors     []models.BookAuthor
+	categoryIds []string
+	tags        []string
+}
+
+func (row *bookRow) field(name string) interface{} {
+	switch name {
+	case "id":
+		return row.book.Id
+	case "title":
+		return row.book.Title
+	case "author":
+		return row.book.Author
+	case "publisher":
+		return row.book.Publisher
+	case "author_id":
+		var ids []string
+		for _, author := range row.authors {
+			ids = append(ids, author.AuthorId)
+		}
+		return ids
+	case "publisher_id":
+		return row.book.PublisherId
+	case "category":
+		return row.categoryIds
+	case "tag":
+		return row.tags
+	case "num_pages":
+		return row.book.NumPages
+	case "lang":
+		return row.book.Lang
+	case "price":
+		return int(row.book.Price)
+	case "currency":
+		return row.book.Currency
+	case "stock_quantity":
+		return row.book.StockQuantity
+	}
+	return row.meta.field(name)
+}
+
+func (row *bookRow) key() string {
+	return row.book.Id
+}
+
+type BookRepo struct {
+	db *database
+}
+
+func (s BookRepo) Create(ctx context.Context, req *models.CreateBook) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	row := &bookRow{
+		meta: newMeta(),
+		book: models.Book{
+			Id:          id,
+			Version:     1,
+			Title:       req.Title,
+			PublisherId: req.PublisherId,
+			NumPages:    req.NumPages,
+			Picture:     req.Picture,
+			Lang:        req.Lang,
+			Price:       req.Price,
+			Currency:    req.Currency,
+		},
+		authors:     bookAuthors(req.Authors),
+		categoryIds: addUnique(nil, req.CategoryIds...),
+		tags:        addUnique(nil, req.Tags...),
+	}
+	s.db.bookNames(row)
+	s.db.books = append(s.db.books, row)
+	return id, nil
+}
+
+func (s BookRepo) Update(ctx context.Context, req *models.UpdateBook) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.books {
+		if row.book.Id != req.Id {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.book.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		row.book = models.Book{
+			Id:               req.Id,
+			Version:          row.book.Version,
+			Title:            req.Title,
+			PublisherId:      req.PublisherId,
+			NumPages:         req.NumPages,
+			Picture:          req.Picture,
+			Lang:             req.Lang,
+			Price:            req.Price,
+			Currency:         req.Currency,
+			StockQuantity:    row.book.StockQuantity,
+			ReservedQuantity: row.book.ReservedQuantity,
+		}
+		row.authors = bookAuthors(req.Authors)
+		s.db.bookNames(row)
+		row.categoryIds = addUnique(nil, req.CategoryIds...)
+		row.tags = addUnique(nil, req.Tags...)
+		row.updatedAt = time.Now()
+		row.book.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s BookRepo) Patch(ctx context.Context, req *models.PatchBook) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.books {
+		if row.book.Id != req.Id || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.book.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if req.Title != nil {
+			row.book.Title = *req.Title
+		}
+		if req.Authors != nil {
+			row.authors = bookAuthors(*req.Authors)
+		}
+		if req.PublisherId != nil {
+			row.book.PublisherId = *req.PublisherId
+		}
+		s.db.bookNames(row)
+		if req.CategoryIds != nil {
+			row.categoryIds = addUnique(nil, *req.CategoryIds...)
+		}
+		if req.Tags != nil {
+			row.tags = addUnique(nil, *req.Tags...)
+		}
+		if req.NumPages != nil {
+			row.book.NumPages = *req.NumPages
+		}
+		if req.Picture != nil {
+			row.book.Picture = *req.Picture
+		}
+		if req.Lang != nil {
+			row.book.Lang = *req.Lang
+		}
+		if req.Price != nil {
+			row.book.Price = *req.Price
+		}
+		if req.Currency != nil {
+			row.book.Currency = *req.Currency
+		}
+		row.updatedAt = time.Now()
+		row.book.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s BookRepo) GetById(ctx context.Context, req *models.BookPrimaryKey) (*models.Book, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.books {
+		if !row.isDeleted && row.book.Id == req.Id {
+			return s.db.expandBook(row), nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s BookRepo) GetList(ctx context.Context, req *models.BookGetListRequest) (*models.BookGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.BookGetListResponse{}
+		rows []*bookRow
+	)
+	for _, row := range s.db.books {
+		if row.isDeleted {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&bookRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		resp.Books = append(resp.Books, s.db.expandBook(row))
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+// Search mirrors the postgres full-text search: every positive term has to
+// occur in title, author or publisher after stemming for the book's lang
+func (s BookRepo) Search(ctx context.Context, req *models.BookSearchRequest) (*models.BookSearchResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp    = &models.BookSearchResponse{}
+		results []*models.BookSearchResult
+	)
+	for _, row := range s.db.books {
+		if row.isDeleted || (req.Lang != "" && !strings.EqualFold(row.book.Lang, req.Lang)) {
+			continue
+		}
+
+		var (
+			lang      = row.book.Lang
+			title     = stems(row.book.Title, lang)
+			author    = stems(row.book.Author, lang)
+			publisher = stems(row.book.Publisher, lang)
+			matched   = map[string]bool{}
+			rank      float64
+			ok        = true
+		)
+		for _, term := range parseSearch(req.Query, lang) {
+			found := title[term.stem] || author[term.stem] || publisher[term.stem]
+			if found == term.negated {
+				ok = false
+				break
+			}
+			if term.negated {
+				continue
+			}
+			matched[term.stem] = true
+			if title[term.stem] {
+				rank += titleWeight
+			}
+			if author[term.stem] {
+				rank += authorWeight
+			}
+			if publisher[term.stem] {
+				rank += publisherWeight
+			}
+		}
+		if !ok || len(matched) == 0 {
+			continue
+		}
+
+		book := s.db.expandBook(row)
+		results = append(results, &models.BookSearchResult{
+			Book: book,
+			Rank: rank,
+			Highlight: models.BookHighlight{
+				Title:     highlight(book.Title, lang, matched),
+				Author:    highlight(book.Author, lang, matched),
+				Publisher: highlight(book.Publisher, lang, matched),
+			},
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Id < results[j].Id
+	})
+
+	start, end := page(len(results), req.Offset, req.Limit)
+	for _, result := range results[start:end] {
+		resp.Books = append(resp.Books, result)
+		resp.Count = len(results)
+	}
+	return resp, nil
+}
+
+func (s BookRepo) AddCategories(ctx context.Context, req *models.BookCategories) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveBook(req.BookId)
+	if row == nil {
+		return 0, nil
+	}
+	row.categoryIds = addUnique(row.categoryIds, req.CategoryIds...)
+	row.touch()
+	return 1, nil
+}
+
+func (s BookRepo) RemoveCategory(ctx context.Context, req *models.BookCategoryPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveBook(req.BookId)
+	if row == nil {
+		return 0, nil
+	}
+	var removed bool
+	row.categoryIds, removed = remove(row.categoryIds, req.CategoryId)
+	if !removed {
+		return 0, nil
+	}
+	row.touch()
+	return 1, nil
+}
+
+func (s BookRepo) AddTags(ctx context.Context, req *models.BookTags) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveBook(req.BookId)
+	if row == nil {
+		return 0, nil
+	}
+	row.tags = addUnique(row.tags, req.Tags...)
+	row.touch()
+	return 1, nil
+}
+
+func (s BookRepo) RemoveTag(ctx context.Context, req *models.BookTagPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveBook(req.BookId)
+	if row == nil {
+		return 0, nil
+	}
+	var removed bool
+	row.tags, removed = remove(row.tags, req.Tag)
+	if !removed {
+		return 0, nil
+	}
+	row.touch()
+	return 1, nil
+}
+
+func (s BookRepo) Delete(ctx context.Context, req *models.BookPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.books {
+		if row.book.Id == req.Id {
+			if ok, err := checkVersion(row.meta, row.book.Version, req.Version); !ok {
+				return err
+			}
+			row.isDeleted = true
+			row.book.Version++
+			row.updatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (row *bookRow) touch() {
+	row.updatedAt = time.Now()
+	row.book.Version++
+}
+
+func (db *database) liveBook(id string) *bookRow {
+	for _, row := range db.books {
+		if !row.isDeleted && row.book.Id == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// expandBook returns a copy of the row's book with its live authors,
+// categories and tags
+func (db *database) expandBook(row *bookRow) *models.Book {
+	book := row.book
+	book.Authors = []*models.BookAuthor{}
+	for _, author := range row.authors {
+		if a := db.author(author.AuthorId); a != nil && !a.isDeleted {
+			author.Name = a.author.Name
+			book.Authors = append(book.Authors, &author)
+		}
+	}
+	book.Categories = []*models.Category{}
+	book.Tags = append([]string{}, row.tags...)
+	for _, id := range row.categoryIds {
+		if c := db.category(id); c != nil && !c.isDeleted {
+			category := c.category
+			book.Categories = append(book.Categories, &category)
+		}
+	}
+
+	sort.Slice(book.Categories, func(i, j int) bool {
+		if book.Categories[i].Name != book.Categories[j].Name {
+			return book.Categories[i].Name < book.Categories[j].Name
+		}
+		return book.Categories[i].Id < book.Categories[j].Id
+	})
+	sort.Strings(book.Tags)
+	return &book
+}
+
+// bookNames derives the row's author and publisher text from its credited
+// authors and its publisher, as postgres does on every write
+func (db *database) bookNames(row *bookRow) {
+	var names []string
+	for _, author := range row.authors {
+		if a := db.author(author.AuthorId); a != nil && !a.isDeleted && author.Role == models.AuthorRoleAuthor {
+			names = append(names, a.author.Name)
+		}
+	}
+	row.book.Author = strings.Join(names, ", ")
+	row.book.Publisher = ""
+	if p := db.publisher(row.book.PublisherId); p != nil && !p.isDeleted {
+		row.book.Publisher = p.publisher.Name
+	}
+}
+
+// refreshBookNames reruns bookNames for every book once an author or
+// publisher is renamed
+func (db *database) refreshBookNames() {
+	for _, row := range db.books {
+		db.bookNames(row)
+	}
+}
+
+// bookAuthors copies the credits of a request, dropping repeated ones
+func bookAuthors(authors []*models.BookAuthor) []models.BookAuthor {
+	var credits []models.BookAuthor
+	for _, author := range authors {
+		credit := models.BookAuthor{AuthorId: author.AuthorId, Role: author.Role}
+		if !slices.Contains(credits, credit) {
+			credits = append(credits, credit)
+		}
+	}
+	return credits
+}
+
+// addUnique appends the values not yet in list
+func addUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// remove drops value from list and reports whether it was there
+func remove(list []string, value string) ([]string, bool) {
+	i := slices.Index(list, value)
+	if i < 0 {
+		return list, false
+	}
+	return slices.Delete(list, i, i+1), true
+}
+
+func NewBookRepo(db *database) *BookRepo {
+	return &BookRepo{
+		db: db,
+	}
+}
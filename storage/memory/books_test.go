@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"testing"
+)
+
+// TestSearchMatchesPostgres checks the memory search against the results
+// websearch_to_tsquery and ts_rank_cd give for the same books
+func TestSearchMatchesPostgres(t *testing.T) {
+	strg := newTestStore(t)
+	ctx := context.Background()
+	author := func(name string) []*models.BookAuthor {
+		id, err := strg.Author().Create(ctx, &models.CreateAuthor{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []*models.BookAuthor{{AuthorId: id, Role: models.AuthorRoleAuthor}}
+	}
+	book := func(title, lang string, authors []*models.BookAuthor) string {
+		id, err := strg.Books().Create(ctx, &models.CreateBook{Title: title, Authors: authors, Lang: lang, Price: 1000, Currency: "USD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	running := book("Running with Dogs", "en", author("Jane Doe"))
+	cats := book("Cats", "en", author("Dog Walker"))
+	simple := book("Dogs", "xx", nil)
+
+	for _, tc := range []struct {
+		query, lang string
+		want        []string
+	}{
+		// english stems both sides, title (A) outranks author (B)
+		{"dog", "", []string{running, cats}},
+		{"Dogs", "en", []string{running, cats}},
+		{"run", "", []string{running}},
+		// every positive term must match and negated terms exclude
+		{"running dog", "", []string{running}},
+		{"dogs -cats", "en", []string{running}},
+		{"run walker", "", nil},
+		// the simple config matches whole words only
+		{"dogs", "xx", []string{simple}},
+		{"dog", "xx", nil},
+	} {
+		resp, err := strg.Books().Search(ctx, &models.BookSearchRequest{Query: tc.query, Lang: tc.lang})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, book := range resp.Books {
+			got = append(got, book.Id)
+		}
+		if len(got) != len(tc.want) || resp.Count != len(tc.want) {
+			t.Errorf("search %q in %q found %d of %d, want %d", tc.query, tc.lang, len(got), resp.Count, len(tc.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("search %q in %q = %v, want %v", tc.query, tc.lang, got, tc.want)
+				break
+			}
+		}
+	}
+
+	resp, err := strg.Books().Search(ctx, &models.BookSearchRequest{Query: "dog", Lang: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Books) != 2 {
+		t.Fatalf("search found %d books, want 2", len(resp.Books))
+	}
+	if got := resp.Books[0].Highlight; got.Title != "Running with <mark>Dogs</mark>" || got.Author != "Jane Doe" {
+		t.Errorf("highlight = %+v, want the title's Dogs marked", got)
+	}
+	if got := resp.Books[1].Highlight; got.Title != "Cats" || got.Author != "<mark>Dog</mark> Walker" {
+		t.Errorf("highlight = %+v, want the author's Dog marked", got)
+	}
+	if resp.Books[0].Rank <= resp.Books[1].Rank {
+		t.Errorf("ranks %v and %v, want the title match first", resp.Books[0].Rank, resp.Books[1].Rank)
+	}
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type cartRow struct {
+	meta
+	cart  models.Cart
+	items []*models.CartItem
+}
+
+func (row *cartRow) touch() {
+	row.updatedAt = time.Now()
+	row.cart.Version++
+}
+
+func (row *cartRow) item(bookId string) *models.CartItem {
+	for _, item := range row.items {
+		if item.BookId == bookId {
+			return item
+		}
+	}
+	return nil
+}
+
+type CartRepo struct {
+	db *database
+}
+
+func (s CartRepo) Create(ctx context.Context, req *models.CreateCart) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.createCart(req.UserId), nil
+}
+
+// createCart returns the id of the user's cart, adding one when a visitor
+// cart is asked for or the user has none yet; callers hold db.mu
+func (db *database) createCart(userId string) string {
+	if userId != "" {
+		if row := db.cart(&models.CartPrimaryKey{UserId: userId}); row != nil {
+			return row.cart.CartId
+		}
+	}
+	var id = uuid.New().String()
+	db.carts = append(db.carts, &cartRow{
+		meta: newMeta(),
+		cart: models.Cart{
+			CartId:  id,
+			UserId:  userId,
+			Version: 1,
+		},
+	})
+	return id
+}
+
+func (s CartRepo) GetById(ctx context.Context, req *models.CartPrimaryKey) (*models.Cart, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	row := s.db.cart(req)
+	if row == nil {
+		return nil, errNoRows
+	}
+	cart := row.cart
+	cart.Items = []*models.CartItem{}
+	for _, item := range row.items {
+		cart.Items = append(cart.Items, &models.CartItem{BookId: item.BookId, Quantity: item.Quantity})
+	}
+	return &cart, nil
+}
+
+func (s CartRepo) AddItem(ctx context.Context, req *models.CartItemRequest) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+	if row == nil {
+		return 0, nil
+	}
+	if req.Version > 0 && req.Version != row.cart.Version {
+		return 0, storage.ErrVersionConflict
+	}
+	row.add(req.BookId, req.Quantity)
+	row.touch()
+	return 1, nil
+}
+
+func (row *cartRow) add(bookId string, quantity int) {
+	if item := row.item(bookId); item != nil {
+		item.Quantity += quantity
+		return
+	}
+	row.items = append(row.items, &models.CartItem{BookId: bookId, Quantity: quantity})
+}
+
+func (s CartRepo) SetItem(ctx context.Context, req *models.CartItemRequest) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+	if row == nil {
+		return 0, nil
+	}
+	if req.Version > 0 && req.Version != row.cart.Version {
+		return 0, storage.ErrVersionConflict
+	}
+	item := row.item(req.BookId)
+	if item == nil {
+		return 0, nil
+	}
+	item.Quantity = req.Quantity
+	row.touch()
+	return 1, nil
+}
+
+func (s CartRepo) RemoveItem(ctx context.Context, req *models.CartItemPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+	if row == nil {
+		return 0, nil
+	}
+	if req.Version > 0 && req.Version != row.cart.Version {
+		return 0, storage.ErrVersionConflict
+	}
+	for i, item := range row.items {
+		if item.BookId == req.BookId {
+			row.items = append(row.items[:i], row.items[i+1:]...)
+			row.touch()
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s CartRepo) Clear(ctx context.Context, req *models.CartPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+	if row == nil {
+		return 0, nil
+	}
+	if req.Version > 0 && req.Version != row.cart.Version {
+		return 0, storage.ErrVersionConflict
+	}
+	affected := int64(len(row.items))
+	row.items = nil
+	row.touch()
+	return affected, nil
+}
+
+func (s CartRepo) Merge(ctx context.Context, req *models.MergeCart) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	from := s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+	if from == nil || from.cart.UserId != "" {
+		return 0, nil
+	}
+	into := s.db.cart(&models.CartPrimaryKey{CartId: s.db.createCart(req.UserId)})
+	for _, item := range from.items {
+		into.add(item.BookId, item.Quantity)
+	}
+	into.touch()
+	from.isDeleted = true
+	return 1, nil
+}
+
+// cart finds a live cart by id, or by user when no id is given; callers
+// hold db.mu
+func (db *database) cart(key *models.CartPrimaryKey) *cartRow {
+	for _, row := range db.carts {
+		if row.isDeleted {
+			continue
+		}
+		if (key.CartId != "" && row.cart.CartId == key.CartId) || (key.CartId == "" && key.UserId != "" && row.cart.UserId == key.UserId) {
+			return row
+		}
+	}
+	return nil
+}
+
+func NewCartRepo(db *database) *CartRepo {
+	return &CartRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type categoryRow struct {
+	meta
+	category models.Category
+}
+
+func (row *categoryRow) field(name string) interface{} {
+	switch name {
+	case "id":
+		return row.category.Id
+	case "parent_id":
+		return row.category.ParentId
+	case "name":
+		return row.category.Name
+	case "type":
+		return row.category.Type
+	}
+	return row.meta.field(name)
+}
+
+func (row *categoryRow) key() string {
+	return row.category.Id
+}
+
+type CategoryRepo struct {
+	db *database
+}
+
+func (s CategoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.categories = append(s.db.categories, &categoryRow{
+		meta: newMeta(),
+		category: models.Category{
+			Id:       id,
+			ParentId: req.ParentId,
+			Version:  1,
+			Name:     req.Name,
+			Type:     req.Type,
+			Picture:  req.Picture,
+		},
+	})
+	return id, nil
+}
+
+func (s CategoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.categories {
+		if row.category.Id != req.Id {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.category.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if s.db.categoryCycle(req.Id, req.ParentId) {
+			return 0, storage.ErrCategoryCycle
+		}
+		row.category = models.Category{
+			Id:       req.Id,
+			ParentId: req.ParentId,
+			Version:  row.category.Version,
+			Name:     req.Name,
+			Type:     req.Type,
+			Picture:  req.Picture,
+		}
+		row.updatedAt = time.Now()
+		row.category.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s CategoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.categories {
+		if row.category.Id != req.Id || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.category.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if req.ParentId != nil {
+			if s.db.categoryCycle(req.Id, *req.ParentId) {
+				return 0, storage.ErrCategoryCycle
+			}
+			row.category.ParentId = *req.ParentId
+		}
+		if req.Name != nil {
+			row.category.Name = *req.Name
+		}
+		if req.Type != nil {
+			row.category.Type = *req.Type
+		}
+		if req.Picture != nil {
+			row.category.Picture = *req.Picture
+		}
+		row.updatedAt = time.Now()
+		row.category.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s CategoryRepo) GetById(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.categories {
+		if !row.isDeleted && row.category.Id == req.Id {
+			category := row.category
+			return &category, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s CategoryRepo) GetList(ctx context.Context, req *models.CategoryGetListRequest) (*models.CategoryGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.CategoryGetListResponse{}
+		rows []*categoryRow
+	)
+	for _, row := range s.db.categories {
+		if row.isDeleted {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&categoryRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		category := row.category
+		resp.Categories = append(resp.Categories, &category)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s CategoryRepo) GetTree(ctx context.Context, req *models.CategoryTreeRequest) ([]*models.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		children = map[string][]*categoryRow{}
+		queue    []*categoryRow
+		tree     []*models.Category
+	)
+	for _, row := range s.db.categories {
+		if row.isDeleted {
+			continue
+		}
+		if req.RootId == "" && row.category.ParentId == "" || row.category.Id == req.RootId {
+			queue = append(queue, row)
+		} else {
+			children[row.category.ParentId] = append(children[row.category.ParentId], row)
+		}
+	}
+	for len(queue) > 0 {
+		row := queue[0]
+		queue = append(queue[1:], children[row.category.Id]...)
+		delete(children, row.category.Id)
+
+		category := row.category
+		tree = append(tree, &category)
+	}
+
+	sort.Slice(tree, func(i, j int) bool {
+		if tree[i].Name != tree[j].Name {
+			return tree[i].Name < tree[j].Name
+		}
+		return tree[i].Id < tree[j].Id
+	})
+	return tree, nil
+}
+
+func (s CategoryRepo) GetAncestors(ctx context.Context, req *models.CategoryPrimaryKey) ([]*models.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var path []*models.Category
+	for id := req.Id; id != "" && len(path) <= 100; {
+		row := s.db.category(id)
+		if row == nil || row.isDeleted && id == req.Id {
+			break
+		}
+		category := row.category
+		path = append([]*models.Category{&category}, path...)
+		id = category.ParentId
+	}
+	return path, nil
+}
+
+func (s CategoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.categories {
+		if row.category.Id == req.Id {
+			if ok, err := checkVersion(row.meta, row.category.Version, req.Version); !ok {
+				return err
+			}
+			row.isDeleted = true
+			row.category.Version++
+			row.updatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (db *database) category(id string) *categoryRow {
+	for _, row := range db.categories {
+		if row.category.Id == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// categoryCycle reports whether moving id under parentId would make id its
+// own ancestor
+func (db *database) categoryCycle(id, parentId string) bool {
+	for seen := map[string]bool{}; parentId != "" && !seen[parentId]; {
+		if parentId == id {
+			return true
+		}
+		seen[parentId] = true
+		if row := db.category(parentId); row != nil {
+			parentId = row.category.ParentId
+		} else {
+			parentId = ""
+		}
+	}
+	return false
+}
+
+func NewCategoryRepo(db *database) *CategoryRepo {
+	return &CategoryRepo{
+		db: db,
+	}
+}
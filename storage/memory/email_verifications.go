@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailVerificationRepo struct {
+	db *database
+}
+
+// Create stores a new verification token, superseding any token still pending for the user
+func (s EmailVerificationRepo) Create(ctx context.Context, req *models.CreateEmailVerification) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var (
+		id  = uuid.New().String()
+		now = time.Now().UTC()
+	)
+	for _, row := range s.db.emailVerifications {
+		if row.UserId == req.UserId && row.UsedAt == nil {
+			usedAt := now
+			row.UsedAt = &usedAt
+		}
+	}
+
+	s.db.emailVerifications = append(s.db.emailVerifications, &models.EmailVerification{
+		Id:        id,
+		UserId:    req.UserId,
+		Email:     req.Email,
+		TokenHash: req.TokenHash,
+		ExpiresAt: req.ExpiresAt.UTC(),
+		CreatedAt: now,
+	})
+	return id, nil
+}
+
+func (s EmailVerificationRepo) GetPending(ctx context.Context, req *models.EmailVerificationPrimaryKey) (*models.EmailVerification, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var now = time.Now().UTC()
+	for _, row := range s.db.emailVerifications {
+		if row.UsedAt != nil || !row.ExpiresAt.After(now) {
+			continue
+		}
+		if (req.Id != "" && row.Id == req.Id) || (req.Id == "" && row.TokenHash == req.TokenHash) {
+			verification := *row
+			return &verification, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s EmailVerificationRepo) CountSince(ctx context.Context, req *models.EmailVerificationCountRequest) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var count int
+	for _, row := range s.db.emailVerifications {
+		if row.UserId == req.UserId && !row.CreatedAt.Before(req.Since.UTC()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Consume marks the token used; zero rows affected means it was already used
+func (s EmailVerificationRepo) Consume(ctx context.Context, req *models.EmailVerificationPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.emailVerifications {
+		if row.Id == req.Id && row.UsedAt == nil {
+			usedAt := time.Now().UTC()
+			row.UsedAt = &usedAt
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func NewEmailVerificationRepo(db *database) *EmailVerificationRepo {
+	return &EmailVerificationRepo{
+		db: db,
+	}
+}
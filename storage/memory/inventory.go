@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+type movementRow struct {
+	meta
+	movement models.InventoryMovement
+}
+
+func (row *movementRow) field(name string) interface{} {
+	switch name {
+	case "reason":
+		return row.movement.Reason
+	case "user_id":
+		return row.movement.UserId
+	case "order_id":
+		return row.movement.OrderId
+	}
+	return row.meta.field(name)
+}
+
+func (row *movementRow) key() string {
+	return row.movement.Id
+}
+
+type InventoryRepo struct {
+	db *database
+}
+
+func (s InventoryRepo) Adjust(ctx context.Context, req *models.AdjustInventory) (*models.InventoryMovement, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	movement := &models.InventoryMovement{
+		BookId: req.BookId,
+		Delta:  req.Delta,
+		Reason: req.Reason,
+		Note:   req.Note,
+		UserId: req.UserId,
+	}
+	ok, err := s.db.moveStock(movement)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s InventoryRepo) GetMovements(ctx context.Context, req *models.InventoryMovementGetListRequest) (*models.InventoryMovementGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.InventoryMovementGetListResponse{}
+		rows []*movementRow
+	)
+	for _, row := range s.db.movements {
+		if row.movement.BookId != req.BookId {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&movementRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		movement := row.movement
+		resp.Movements = append(resp.Movements, &movement)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+// moveStock mirrors the postgres moveStock; the caller holds the write lock
+func (db *database) moveStock(m *models.InventoryMovement) (bool, error) {
+	row := db.liveBook(m.BookId)
+	if row == nil {
+		return false, nil
+	}
+	stock, reserved := row.book.StockQuantity+m.Delta, row.book.ReservedQuantity+m.ReservedDelta
+	if reserved < 0 || stock < reserved {
+		return false, storage.ErrInsufficientStock
+	}
+	row.book.StockQuantity, row.book.ReservedQuantity = stock, reserved
+	row.touch()
+
+	m.Id = uuid.New().String()
+	m.StockQuantity, m.ReservedQuantity = stock, reserved
+	movement := &movementRow{meta: newMeta(), movement: *m}
+	movement.movement.CreatedAt = movement.createdAt
+	m.CreatedAt = movement.createdAt
+	db.movements = append(db.movements, movement)
+	return true, nil
+}
+
+func NewInventoryRepo(db *database) *InventoryRepo {
+	return &InventoryRepo{
+		db: db,
+	}
+}
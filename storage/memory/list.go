@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"app/api/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// listRow is a row that can be filtered and sorted by field name. field
+// returns nil for fields the row does not have
+type listRow interface {
+	field(name string) interface{}
+	key() string
+}
+
+// field returns the timestamps every row carries
+func (m meta) field(name string) interface{} {
+	switch name {
+	case "created_at":
+		return m.createdAt
+	case "updated_at":
+		return m.updatedAt
+	}
+	return nil
+}
+
+// matches reports whether row satisfies all filters. A field with several
+// values matches when any of them does, and ne then means none is equal
+func matches(row listRow, filters []models.Filter) (bool, error) {
+	for _, filter := range filters {
+		value := row.field(filter.Field)
+		if value == nil {
+			return false, fmt.Errorf("cannot filter by %q", filter.Field)
+		}
+
+		var (
+			ok  bool
+			err error
+		)
+		if values, many := value.([]string); many {
+			op := filter.Op
+			if op == models.FilterNe {
+				op = models.FilterEq
+			}
+			for _, v := range values {
+				ok, err = match(v, op, filter.Value)
+				if ok || err != nil {
+					break
+				}
+			}
+			if filter.Op == models.FilterNe {
+				ok = !ok
+			}
+		} else {
+			ok, err = match(value, filter.Op, filter.Value)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func match(value interface{}, op string, operand interface{}) (bool, error) {
+	switch op {
+	case models.FilterLike:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(operand))), nil
+	case models.FilterIn:
+		values, _ := operand.([]interface{})
+		for _, v := range values {
+			if compare(value, v) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case models.FilterEq:
+		return compare(value, operand) == 0, nil
+	case models.FilterNe:
+		return compare(value, operand) != 0, nil
+	case models.FilterGt:
+		return compare(value, operand) > 0, nil
+	case models.FilterGte:
+		return compare(value, operand) >= 0, nil
+	case models.FilterLt:
+		return compare(value, operand) < 0, nil
+	case models.FilterLte:
+		return compare(value, operand) <= 0, nil
+	}
+	return false, fmt.Errorf("unknown filter operator %q", op)
+}
+
+// less orders a before b by sorts, newest first when there are none, with
+// the key breaking ties like the postgres ORDER BY does
+func less(a, b listRow, sorts []models.Sort) bool {
+	if len(sorts) == 0 {
+		if c := compare(a.field("created_at"), b.field("created_at")); c != 0 {
+			return c > 0
+		}
+		return a.key() > b.key()
+	}
+	for _, sort := range sorts {
+		c := compare(a.field(sort.Field), b.field(sort.Field))
+		if c == 0 {
+			continue
+		}
+		return (c < 0) != sort.Desc
+	}
+	return a.key() < b.key()
+}
+
+// listPage returns the bounds of the requested page of n rows sorted by less
+// and the cursors of the pages around it. A cursor seeks past the cursor row
+// instead of skipping offset rows
+func listPage(n int, row func(i int) listRow, offset, limit int, cursor *models.Cursor, sorted bool) (start, end int, next, prev string, err error) {
+	if cursor == nil {
+		start, end = page(n, offset, limit)
+	} else {
+		if sorted {
+			return 0, 0, "", "", errors.New("cursor pages are only available in the default order")
+		}
+		if limit <= 0 {
+			limit = 10
+		}
+		// rows newer than the cursor row come before it
+		at := sort.Search(n, func(i int) bool {
+			c := compare(row(i).field("created_at"), cursor.CreatedAt)
+			return c < 0 || c == 0 && row(i).key() <= cursor.Id
+		})
+		if cursor.Before {
+			start, end = max(at-limit, 0), at
+		} else {
+			if at < n && row(at).key() == cursor.Id {
+				at++
+			}
+			start, end = at, min(at+limit, n)
+		}
+	}
+	if start == end || sorted {
+		return start, end, "", "", nil
+	}
+
+	if end < n {
+		next = rowCursor(row(end-1), false)
+	}
+	if start > 0 {
+		prev = rowCursor(row(start), true)
+	}
+	return start, end, next, prev, nil
+}
+
+func rowCursor(row listRow, before bool) string {
+	createdAt, _ := row.field("created_at").(time.Time)
+	return models.Cursor{CreatedAt: createdAt, Id: row.key(), Before: before}.Encode()
+}
+
+// checkSorts rejects sorts over fields rows of this kind do not have
+func checkSorts(row listRow, sorts []models.Sort) error {
+	for _, sort := range sorts {
+		if row.field(sort.Field) == nil {
+			return fmt.Errorf("cannot sort by %q", sort.Field)
+		}
+	}
+	return nil
+}
+
+// compare returns -1, 0 or 1 for values of the same type; values of
+// different types never compare equal
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case int:
+		if b, ok := b.(int); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	}
+	return 1
+}
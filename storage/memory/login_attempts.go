@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoginAttemptRepo struct {
+	db *database
+}
+
+func (s LoginAttemptRepo) Create(ctx context.Context, req *models.CreateLoginAttempt) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.loginAttempts = append(s.db.loginAttempts, &models.LoginAttempt{
+		Id:        id,
+		Username:  req.Username,
+		UserId:    req.UserId,
+		Ip:        req.Ip,
+		UserAgent: req.UserAgent,
+		Success:   req.Success,
+		Reason:    req.Reason,
+		CreatedAt: time.Now().UTC(),
+	})
+	return id, nil
+}
+
+// Update resolves a pending attempt
+func (s LoginAttemptRepo) Update(ctx context.Context, req *models.UpdateLoginAttempt) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.loginAttempts {
+		if row.Id == req.Id {
+			row.UserId = req.UserId
+			row.Success = req.Success
+			row.Reason = req.Reason
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// GetFailures walks attempts newest first, stopping at the username's last success
+func (s LoginAttemptRepo) GetFailures(ctx context.Context, req *models.LoginFailureRequest) (*models.LoginFailures, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp  = &models.LoginFailures{}
+		since = req.Since.UTC()
+	)
+	for i := len(s.db.loginAttempts) - 1; i >= 0; i-- {
+		row := s.db.loginAttempts[i]
+		if row.CreatedAt.Before(since) {
+			break
+		}
+		if req.Username != "" && row.Username != req.Username {
+			continue
+		}
+		if req.Username == "" && row.Ip != req.Ip {
+			continue
+		}
+		if row.Id == req.ExceptId {
+			continue
+		}
+		if row.Success {
+			if req.Username != "" {
+				break
+			}
+			continue
+		}
+		if row.Reason == models.LoginThrottled {
+			continue
+		}
+		if resp.Count == 0 {
+			resp.Last = row.CreatedAt
+		}
+		resp.Count++
+	}
+	return resp, nil
+}
+
+func (s LoginAttemptRepo) GetList(ctx context.Context, req *models.LoginAttemptGetListRequest) (*models.LoginAttemptGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.LoginAttemptGetListResponse{}
+		rows []*models.LoginAttempt
+	)
+	for i := len(s.db.loginAttempts) - 1; i >= 0; i-- {
+		row := s.db.loginAttempts[i]
+		if req.Username != "" && row.Username != req.Username {
+			continue
+		}
+		if req.Ip != "" && row.Ip != req.Ip {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	start, end := page(len(rows), req.Offset, req.Limit)
+	for _, row := range rows[start:end] {
+		attempt := *row
+		resp.LoginAttempts = append(resp.LoginAttempts, &attempt)
+		resp.Count = len(rows)
+	}
+	return resp, nil
+}
+
+func NewLoginAttemptRepo(db *database) *LoginAttemptRepo {
+	return &LoginAttemptRepo{
+		db: db,
+	}
+}
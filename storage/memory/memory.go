@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/config"
+	"app/storage"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// errNoRows is returned for missing rows so handlers treat both backends alike
+var errNoRows = pgx.ErrNoRows
+
+type database struct {
+	mu         sync.RWMutex
+	users      []*userRow
+	categories []*categoryRow
+	authors    []*authorRow
+	publishers []*publisherRow
+	books      []*bookRow
+	orders     []*orderRow
+	orderItems []*orderItemRow
+	movements  []*movementRow
+	carts      []*cartRow
+
+	orderHistory []*models.OrderStatusChange
+
+	refreshTokens      []*models.RefreshToken
+	revokedTokens      []*revokedTokenRow
+	passwordResets     []*models.PasswordReset
+	emailVerifications []*models.EmailVerification
+	loginAttempts      []*models.LoginAttempt
+}
+
+type meta struct {
+	createdAt time.Time
+	updatedAt time.Time
+	isDeleted bool
+}
+
+func newMeta() meta {
+	now := time.Now()
+	return meta{
+		createdAt: now,
+		updatedAt: now,
+	}
+}
+
+type store struct {
+	db                *database
+	user              *UserRepo
+	category          *CategoryRepo
+	author            *AuthorRepo
+	publisher         *PublisherRepo
+	inventory         *InventoryRepo
+	book              *BookRepo
+	order             *OrderRepo
+	orderItem         *OrderItemRepo
+	cart              *CartRepo
+	refreshToken      *RefreshTokenRepo
+	revokedToken      *RevokedTokenRepo
+	passwordReset     *PasswordResetRepo
+	emailVerification *EmailVerificationRepo
+	loginAttempt      *LoginAttemptRepo
+}
+
+func (s *store) Users() storage.UserRepoInterface {
+	if s.user == nil {
+		s.user = NewUserRepo(s.db)
+	}
+	return s.user
+}
+
+func (s *store) Category() storage.CategoryRepoInterface {
+	if s.category == nil {
+		s.category = NewCategoryRepo(s.db)
+	}
+	return s.category
+}
+
+func (s *store) Author() storage.AuthorRepoInterface {
+	if s.author == nil {
+		s.author = NewAuthorRepo(s.db)
+	}
+	return s.author
+}
+
+func (s *store) Publisher() storage.PublisherRepoInterface {
+	if s.publisher == nil {
+		s.publisher = NewPublisherRepo(s.db)
+	}
+	return s.publisher
+}
+
+func (s *store) Inventory() storage.InventoryRepoInterface {
+	if s.inventory == nil {
+		s.inventory = NewInventoryRepo(s.db)
+	}
+	return s.inventory
+}
+
+func (s *store) Books() storage.BookRepoInterface {
+	if s.book == nil {
+		s.book = NewBookRepo(s.db)
+	}
+	return s.book
+}
+
+func (s *store) Order() storage.OrderRepoInterface {
+	if s.order == nil {
+		s.order = NewOrderRepo(s.db)
+	}
+	return s.order
+}
+
+func (s *store) OrderItem() storage.OrderItemRepoInterface {
+	if s.orderItem == nil {
+		s.orderItem = NewOrderItemRepo(s.db)
+	}
+	return s.orderItem
+}
+
+func (s *store) Cart() storage.CartRepoInterface {
+	if s.cart == nil {
+		s.cart = NewCartRepo(s.db)
+	}
+	return s.cart
+}
+
+func (s *store) RefreshToken() storage.RefreshTokenRepoInterface {
+	if s.refreshToken == nil {
+		s.refreshToken = NewRefreshTokenRepo(s.db)
+	}
+	return s.refreshToken
+}
+
+func (s *store) RevokedToken() storage.RevokedTokenRepoInterface {
+	if s.revokedToken == nil {
+		s.revokedToken = NewRevokedTokenRepo(s.db)
+	}
+	return s.revokedToken
+}
+
+func (s *store) PasswordReset() storage.PasswordResetRepoInterface {
+	if s.passwordReset == nil {
+		s.passwordReset = NewPasswordResetRepo(s.db)
+	}
+	return s.passwordReset
+}
+
+func (s *store) EmailVerification() storage.EmailVerificationRepoInterface {
+	if s.emailVerification == nil {
+		s.emailVerification = NewEmailVerificationRepo(s.db)
+	}
+	return s.emailVerification
+}
+
+func (s *store) LoginAttempt() storage.LoginAttemptRepoInterface {
+	if s.loginAttempt == nil {
+		s.loginAttempt = NewLoginAttemptRepo(s.db)
+	}
+	return s.loginAttempt
+}
+
+func NewConnectionMemory(cfg *config.Config) (storage.StorageInterface, error) {
+	return &store{
+		db: &database{},
+	}, nil
+}
+
+func (s *store) Close() {}
+
+// checkVersion mirrors the versioned WHERE of the postgres repos: ok is false
+// when the row must not be written, with ErrVersionConflict if it is still live
+func checkVersion(m meta, current, expected int) (bool, error) {
+	if expected == 0 || current == expected {
+		return true, nil
+	}
+	if m.isDeleted {
+		return false, nil
+	}
+	return false, storage.ErrVersionConflict
+}
+
+// page mirrors the OFFSET/LIMIT defaults of the postgres repos and returns
+// the [start, end) window over total rows
+func page(total, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return offset, end
+}
@@ -0,0 +1,297 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type orderItemRow struct {
+	meta
+	orderItem models.OrderItem
+}
+
+func (row *orderItemRow) field(name string) interface{} {
+	switch name {
+	case "item_id":
+		return row.orderItem.ItemId
+	case "order_id":
+		return row.orderItem.OrderId
+	case "book_id":
+		return row.orderItem.BookId
+	case "quantity":
+		return row.orderItem.Quantity
+	case "line_total":
+		return int(row.orderItem.LineTotal)
+	}
+	return row.meta.field(name)
+}
+
+func (row *orderItemRow) key() string {
+	return row.orderItem.ItemId
+}
+
+type OrderItemRepo struct {
+	db *database
+}
+
+func (s OrderItemRepo) Create(ctx context.Context, req *models.CreateOrderItem) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	err := s.db.pendingOrders(req.OrderId)
+	if err != nil {
+		return "", err
+	}
+	item := models.OrderItem{
+		Version:   1,
+		OrderId:   req.OrderId,
+		BookId:    req.BookId,
+		Quantity:  req.Quantity,
+		UnitPrice: req.UnitPrice,
+		Currency:  req.Currency,
+		LineTotal: int64(req.Quantity) * req.UnitPrice,
+	}
+	err = s.db.holdStock(nil, &item, req.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	var id = uuid.New().String()
+	item.ItemId = id
+	s.db.orderItems = append(s.db.orderItems, &orderItemRow{
+		meta:      newMeta(),
+		orderItem: item,
+	})
+	return id, nil
+}
+
+func (s OrderItemRepo) Update(ctx context.Context, req *models.UpdateOrderItem) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row, err := s.db.orderItem(req.ItemId, req.Version)
+	if err != nil || row == nil {
+		return 0, err
+	}
+	err = s.db.pendingOrders(row.orderItem.OrderId, req.OrderId)
+	if err != nil {
+		return 0, err
+	}
+
+	item := row.orderItem
+	item.OrderId = req.OrderId
+	item.BookId = req.BookId
+	item.Quantity = req.Quantity
+	item.UnitPrice = req.UnitPrice
+	item.Currency = req.Currency
+	err = s.db.holdStock(&row.orderItem, &item, req.UserId)
+	if err != nil {
+		return 0, err
+	}
+	row.update(item)
+	return 1, nil
+}
+
+func (s OrderItemRepo) Patch(ctx context.Context, req *models.PatchOrderItem) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row, err := s.db.orderItem(req.ItemId, req.Version)
+	if err != nil || row == nil {
+		return 0, err
+	}
+
+	item := row.orderItem
+	if req.OrderId != nil {
+		item.OrderId = *req.OrderId
+	}
+	if req.BookId != nil {
+		item.BookId = *req.BookId
+	}
+	if req.Quantity != nil {
+		item.Quantity = *req.Quantity
+	}
+	if req.UnitPrice != nil {
+		item.UnitPrice = *req.UnitPrice
+	}
+	if req.Currency != nil {
+		item.Currency = *req.Currency
+	}
+	err = s.db.pendingOrders(row.orderItem.OrderId, item.OrderId)
+	if err != nil {
+		return 0, err
+	}
+	err = s.db.holdStock(&row.orderItem, &item, req.UserId)
+	if err != nil {
+		return 0, err
+	}
+	row.update(item)
+	return 1, nil
+}
+
+func (row *orderItemRow) update(item models.OrderItem) {
+	item.LineTotal = int64(item.Quantity) * item.UnitPrice
+	item.Version++
+	row.orderItem = item
+	row.updatedAt = time.Now()
+}
+
+func (s OrderItemRepo) GetById(ctx context.Context, req *models.OrderItemPrimaryKey) (*models.OrderItem, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.orderItems {
+		if !row.isDeleted && row.orderItem.ItemId == req.ItemId {
+			orderItem := row.orderItem
+			return &orderItem, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s OrderItemRepo) GetList(ctx context.Context, req *models.OrderItemGetListRequest) (*models.OrderItemGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.OrderItemGetListResponse{}
+		rows []*orderItemRow
+	)
+	for _, row := range s.db.orderItems {
+		if row.isDeleted || (req.UserId != "" && !s.db.orderOwnedBy(row.orderItem.OrderId, req.UserId)) {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&orderItemRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		orderItem := row.orderItem
+		resp.OrderItems = append(resp.OrderItems, &orderItem)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s OrderItemRepo) Delete(ctx context.Context, req *models.OrderItemPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row, err := s.db.orderItem(req.ItemId, req.Version)
+	if err != nil || row == nil {
+		return err
+	}
+	err = s.db.pendingOrders(row.orderItem.OrderId)
+	if err != nil {
+		return err
+	}
+	err = s.db.holdStock(&row.orderItem, nil, req.UserId)
+	if err != nil {
+		return err
+	}
+	row.isDeleted = true
+	row.orderItem.Version++
+	row.updatedAt = time.Now()
+	return nil
+}
+
+// orderItem finds the live item itemId, or nil when there is none; callers
+// hold db.mu
+func (db *database) orderItem(itemId string, version int) (*orderItemRow, error) {
+	for _, row := range db.orderItems {
+		if row.isDeleted || row.orderItem.ItemId != itemId {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.orderItem.Version, version); !ok {
+			return nil, err
+		}
+		return row, nil
+	}
+	return nil, nil
+}
+
+// pendingOrders returns errNoRows for a missing order and ErrOrderNotPending
+// unless every order an item write touches is pending; callers hold db.mu
+func (db *database) pendingOrders(orderIds ...string) error {
+	for _, orderId := range orderIds {
+		row := db.liveOrder(orderId)
+		if row == nil {
+			return errNoRows
+		}
+		if row.order.Status != models.OrderStatusPending {
+			return fmt.Errorf("%w: %s", storage.ErrOrderNotPending, orderId)
+		}
+	}
+	return nil
+}
+
+// holdStock reserves and releases stock so that the orders of from and to
+// hold what their items need once from becomes to. Every movement is checked
+// before any applies, so a failure leaves the books as they were; callers
+// hold db.mu
+func (db *database) holdStock(from, to *models.OrderItem, userId string) error {
+	movements := models.ItemReservations(from, to, userId)
+	reserved := map[string]int{}
+	for _, movement := range movements {
+		row := db.liveBook(movement.BookId)
+		if row == nil {
+			// a deleted book has nothing left to release
+			if movement.ReservedDelta > 0 {
+				return fmt.Errorf("%w: %s", storage.ErrBookNotFound, movement.BookId)
+			}
+			continue
+		}
+		reserved[movement.BookId] += movement.ReservedDelta
+		total := row.book.ReservedQuantity + reserved[movement.BookId]
+		if total < 0 || total > row.book.StockQuantity {
+			return fmt.Errorf("%w: %s", storage.ErrInsufficientStock, movement.BookId)
+		}
+	}
+	for _, movement := range movements {
+		_, err := db.moveStock(movement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderOwnedBy reports whether orderId belongs to userId; callers hold db.mu
+func (db *database) orderOwnedBy(orderId string, userId string) bool {
+	for _, row := range db.orders {
+		if row.order.OrderId == orderId {
+			return row.order.UserId == userId
+		}
+	}
+	return false
+}
+
+func NewOrderItemRepo(db *database) *OrderItemRepo {
+	return &OrderItemRepo{
+		db: db,
+	}
+}
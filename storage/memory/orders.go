@@ -0,0 +1,427 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type orderRow struct {
+	meta
+	order models.Order
+	// frozen is set once the order leaves pending, from when order holds
+	// the subtotal and currency it was placed with
+	frozen bool
+}
+
+func (row *orderRow) field(name string) interface{} {
+	switch name {
+	case "order_id":
+		return row.order.OrderId
+	case "user_id":
+		return row.order.UserId
+	case "status":
+		return row.order.Status
+	}
+	return row.meta.field(name)
+}
+
+func (row *orderRow) key() string {
+	return row.order.OrderId
+}
+
+type OrderRepo struct {
+	db *database
+}
+
+func (s OrderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.orders = append(s.db.orders, &orderRow{
+		meta: newMeta(),
+		order: models.Order{
+			OrderId: id,
+			Version: 1,
+			UserId:  req.UserId,
+			Status:  models.OrderStatusPending,
+			TaxRate: req.TaxRate,
+		},
+	})
+	s.db.recordStatus(&models.OrderStatusChange{OrderId: id, To: models.OrderStatusPending, UserId: req.UserId})
+	return id, nil
+}
+
+func (s OrderRepo) Checkout(ctx context.Context, req *models.Checkout) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// a cart that changed since its items were read is left for the user to
+	// check out again
+	var cart *cartRow
+	if req.CartId != "" {
+		cart = s.db.cart(&models.CartPrimaryKey{CartId: req.CartId})
+		if cart == nil || cart.cart.Version != req.CartVersion {
+			return "", storage.ErrVersionConflict
+		}
+	}
+
+	var currency string
+	for _, item := range req.Items {
+		row := s.db.liveBook(item.BookId)
+		if row == nil {
+			return "", fmt.Errorf("%w: %s", storage.ErrBookNotFound, item.BookId)
+		}
+		if currency != "" && row.book.Currency != currency {
+			return "", fmt.Errorf("%w: %s", storage.ErrCurrencyMismatch, item.BookId)
+		}
+		currency = row.book.Currency
+		if row.book.StockQuantity-row.book.ReservedQuantity < item.Quantity {
+			return "", fmt.Errorf("%w: %s", storage.ErrInsufficientStock, item.BookId)
+		}
+	}
+
+	var id = uuid.New().String()
+	s.db.orders = append(s.db.orders, &orderRow{
+		meta: newMeta(),
+		order: models.Order{
+			OrderId: id,
+			Version: 1,
+			UserId:  req.UserId,
+			Status:  models.OrderStatusPending,
+			TaxRate: req.TaxRate,
+		},
+	})
+	s.db.recordStatus(&models.OrderStatusChange{OrderId: id, To: models.OrderStatusPending, UserId: req.UserId})
+	for _, item := range req.Items {
+		book := s.db.liveBook(item.BookId).book
+		s.db.orderItems = append(s.db.orderItems, &orderItemRow{
+			meta: newMeta(),
+			orderItem: models.OrderItem{
+				ItemId:    uuid.New().String(),
+				Version:   1,
+				OrderId:   id,
+				BookId:    item.BookId,
+				Quantity:  item.Quantity,
+				UnitPrice: book.Price,
+				Currency:  book.Currency,
+				LineTotal: int64(item.Quantity) * book.Price,
+			},
+		})
+		_, err := s.db.moveStock(&models.InventoryMovement{
+			BookId:        item.BookId,
+			ReservedDelta: item.Quantity,
+			Reason:        models.InventoryReasonReserve,
+			UserId:        req.UserId,
+			OrderId:       id,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if cart != nil {
+		cart.items = nil
+		cart.touch()
+	}
+	return id, nil
+}
+
+func (s OrderRepo) Transition(ctx context.Context, req *models.OrderTransition) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveOrder(req.OrderId)
+	if row == nil {
+		return 0, nil
+	}
+	if req.Version > 0 && req.Version != row.order.Version {
+		return 0, storage.ErrVersionConflict
+	}
+	err := s.db.transition(row, req)
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// transition moves the order of row to req.Status, recording the change and
+// settling its stock; callers hold db.mu
+func (db *database) transition(row *orderRow, req *models.OrderTransition) error {
+	if !models.CanTransition(row.order.Status, req.Status) {
+		return fmt.Errorf("%w: %s to %s", storage.ErrInvalidTransition, row.order.Status, req.Status)
+	}
+
+	movements, err := db.settleStock(req)
+	if err != nil {
+		return err
+	}
+	for _, movement := range movements {
+		if _, err := db.moveStock(movement); err != nil {
+			return fmt.Errorf("%w: %s", err, movement.BookId)
+		}
+	}
+	db.recordStatus(&models.OrderStatusChange{OrderId: req.OrderId, From: row.order.Status, To: req.Status, Note: req.Note, UserId: req.UserId})
+	// the items of an order that has left pending cannot change, and
+	// neither can what it costs
+	if !row.frozen {
+		order := db.expandOrder(row)
+		row.order.Subtotal, row.order.Currency = order.Subtotal, order.Currency
+		row.frozen = true
+	}
+	row.order.Status = req.Status
+	row.updatedAt = time.Now()
+	row.order.Version++
+	return nil
+}
+
+// settleStock returns the movements the postgres settleStock would make,
+// after checking that every one of them can be applied
+func (db *database) settleStock(req *models.OrderTransition) ([]*models.InventoryMovement, error) {
+	if req.Status != models.OrderStatusShipped && req.Status != models.OrderStatusCancelled && req.Status != models.OrderStatusRefunded {
+		return nil, nil
+	}
+
+	var (
+		books      []string
+		sold, held = map[string]int{}, map[string]int{}
+	)
+	for _, row := range db.orderItems {
+		if !row.isDeleted && row.orderItem.OrderId == req.OrderId {
+			books = addUnique(books, row.orderItem.BookId)
+			sold[row.orderItem.BookId] += row.orderItem.Quantity
+		}
+	}
+	for _, row := range db.movements {
+		if row.movement.OrderId == req.OrderId {
+			books = addUnique(books, row.movement.BookId)
+			held[row.movement.BookId] += row.movement.ReservedDelta
+		}
+	}
+	sort.Strings(books)
+
+	var movements []*models.InventoryMovement
+	for _, bookId := range books {
+		movement := &models.InventoryMovement{
+			BookId:        bookId,
+			ReservedDelta: -held[bookId],
+			Reason:        models.InventoryReasonRelease,
+			UserId:        req.UserId,
+			OrderId:       req.OrderId,
+		}
+		if req.Status == models.OrderStatusShipped {
+			movement.Delta, movement.Reason = -sold[bookId], models.InventoryReasonSale
+		}
+		if movement.Delta == 0 && movement.ReservedDelta == 0 {
+			continue
+		}
+		if row := db.liveBook(bookId); row != nil {
+			stock, reserved := row.book.StockQuantity+movement.Delta, row.book.ReservedQuantity+movement.ReservedDelta
+			if reserved < 0 || stock < reserved {
+				return nil, fmt.Errorf("%w: %s", storage.ErrInsufficientStock, bookId)
+			}
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+func (s OrderRepo) GetHistory(ctx context.Context, req *models.OrderPrimaryKey) ([]*models.OrderStatusChange, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var history = []*models.OrderStatusChange{}
+	for _, change := range s.db.orderHistory {
+		if change.OrderId == req.OrderId {
+			change := *change
+			history = append(history, &change)
+		}
+	}
+	return history, nil
+}
+
+// recordStatus appends change to its order's status history
+func (db *database) recordStatus(change *models.OrderStatusChange) {
+	change.Id = uuid.New().String()
+	change.CreatedAt = time.Now()
+	db.orderHistory = append(db.orderHistory, change)
+}
+
+func (s OrderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.orders {
+		if row.order.OrderId != req.OrderId {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.order.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if row.frozen && req.Discount != row.order.Discount {
+			return 0, fmt.Errorf("%w: %s", storage.ErrOrderNotPending, req.OrderId)
+		}
+		row.order.UserId = req.UserId
+		row.order.Discount = req.Discount
+		row.updatedAt = time.Now()
+		row.order.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s OrderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.orders {
+		if row.order.OrderId != req.OrderId || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.order.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if row.frozen && req.Discount != nil && *req.Discount != row.order.Discount {
+			return 0, fmt.Errorf("%w: %s", storage.ErrOrderNotPending, req.OrderId)
+		}
+		if req.UserId != nil {
+			row.order.UserId = *req.UserId
+		}
+		if req.Discount != nil {
+			row.order.Discount = *req.Discount
+		}
+		row.updatedAt = time.Now()
+		row.order.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s OrderRepo) GetById(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.orders {
+		if !row.isDeleted && row.order.OrderId == req.OrderId {
+			return s.db.expandOrder(row), nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s OrderRepo) GetList(ctx context.Context, req *models.OrderGetListRequest) (*models.OrderGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.OrderGetListResponse{}
+		rows []*orderRow
+	)
+	for _, row := range s.db.orders {
+		if row.isDeleted || (req.UserId != "" && row.order.UserId != req.UserId) {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&orderRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		resp.Orders = append(resp.Orders, s.db.expandOrder(row))
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s OrderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	row := s.db.liveOrder(req.OrderId)
+	if row == nil {
+		return nil
+	}
+	if req.Version > 0 && req.Version != row.order.Version {
+		return storage.ErrVersionConflict
+	}
+	// an order still under way is cancelled first, releasing its stock
+	if !models.IsTerminal(row.order.Status) {
+		err := s.db.transition(row, &models.OrderTransition{OrderId: req.OrderId, Status: models.OrderStatusCancelled, Note: "deleted", UserId: req.UserId})
+		if err != nil {
+			return err
+		}
+	}
+	row.isDeleted = true
+	row.updatedAt = time.Now()
+	row.order.Version++
+	return nil
+}
+
+// expandOrder returns a copy of the row's order with the currency and totals
+// of its live items
+func (db *database) expandOrder(row *orderRow) *models.Order {
+	var (
+		order    = row.order
+		subtotal int64
+	)
+	if row.frozen {
+		order.SetTotals(order.Subtotal)
+		return &order
+	}
+	for _, item := range db.orderItems {
+		if item.isDeleted || item.orderItem.OrderId != order.OrderId {
+			continue
+		}
+		subtotal += item.orderItem.LineTotal
+		if order.Currency == "" || item.orderItem.Currency < order.Currency {
+			order.Currency = item.orderItem.Currency
+		}
+	}
+	order.SetTotals(subtotal)
+	return &order
+}
+
+func (db *database) liveOrder(id string) *orderRow {
+	for _, row := range db.orders {
+		if !row.isDeleted && row.order.OrderId == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func NewOrderRepo(db *database) *OrderRepo {
+	return &OrderRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/config"
+	"app/storage"
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestStore(t *testing.T) storage.StorageInterface {
+	t.Helper()
+	strg, err := NewConnectionMemory(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strg
+}
+
+// stockedBook adds a book with stock copies on hand
+func stockedBook(t *testing.T, strg storage.StorageInterface, stock int) string {
+	t.Helper()
+	ctx := context.Background()
+	id, err := strg.Books().Create(ctx, &models.CreateBook{Title: "Book", Price: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = strg.Inventory().Adjust(ctx, &models.AdjustInventory{BookId: id, Delta: stock, Reason: models.InventoryReasonRestock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func expectStock(t *testing.T, strg storage.StorageInterface, bookId string, stock, reserved int) {
+	t.Helper()
+	book, err := strg.Books().GetById(context.Background(), &models.BookPrimaryKey{Id: bookId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.StockQuantity != stock || book.ReservedQuantity != reserved {
+		t.Fatalf("stock %d reserved %d, want %d and %d", book.StockQuantity, book.ReservedQuantity, stock, reserved)
+	}
+}
+
+func checkout(t *testing.T, strg storage.StorageInterface, items ...*models.CheckoutItem) string {
+	t.Helper()
+	id, err := strg.Order().Checkout(context.Background(), &models.Checkout{Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func transition(t *testing.T, strg storage.StorageInterface, orderId string, statuses ...string) {
+	t.Helper()
+	for _, status := range statuses {
+		affected, err := strg.Order().Transition(context.Background(), &models.OrderTransition{OrderId: orderId, Status: status})
+		if err != nil || affected != 1 {
+			t.Fatalf("transition to %s = %d, %v", status, affected, err)
+		}
+	}
+}
+
+func TestCheckoutReservesStock(t *testing.T) {
+	strg := newTestStore(t)
+	a, b := stockedBook(t, strg, 5), stockedBook(t, strg, 2)
+
+	checkout(t, strg, &models.CheckoutItem{BookId: a, Quantity: 3}, &models.CheckoutItem{BookId: b, Quantity: 2})
+	expectStock(t, strg, a, 5, 3)
+	expectStock(t, strg, b, 2, 2)
+
+	// only what is not reserved can be ordered, and a refused checkout
+	// reserves nothing
+	_, err := strg.Order().Checkout(context.Background(), &models.Checkout{Items: []*models.CheckoutItem{
+		{BookId: a, Quantity: 2},
+		{BookId: b, Quantity: 1},
+	}})
+	if !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("checkout beyond stock = %v, want ErrInsufficientStock", err)
+	}
+	expectStock(t, strg, a, 5, 3)
+	expectStock(t, strg, b, 2, 2)
+
+	orders, err := strg.Order().GetList(context.Background(), &models.OrderGetListRequest{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders.Orders) != 1 {
+		t.Fatalf("%d orders, want only the first", len(orders.Orders))
+	}
+}
+
+func TestSettleStock(t *testing.T) {
+	tests := []struct {
+		name            string
+		statuses        []string
+		stock, reserved int
+	}{
+		{"pending", nil, 10, 3},
+		{"paid", []string{models.OrderStatusPaid}, 10, 3},
+		{"shipped sells what was reserved", []string{models.OrderStatusPaid, models.OrderStatusShipped}, 7, 0},
+		{"cancelled while pending", []string{models.OrderStatusCancelled}, 10, 0},
+		{"cancelled once paid", []string{models.OrderStatusPaid, models.OrderStatusCancelled}, 10, 0},
+		{"refunded before shipping", []string{models.OrderStatusPaid, models.OrderStatusRefunded}, 10, 0},
+		{"refunded after delivery", []string{models.OrderStatusPaid, models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusRefunded}, 7, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := newTestStore(t)
+			bookId := stockedBook(t, strg, 10)
+			orderId := checkout(t, strg, &models.CheckoutItem{BookId: bookId, Quantity: 3})
+			transition(t, strg, orderId, tt.statuses...)
+			expectStock(t, strg, bookId, tt.stock, tt.reserved)
+		})
+	}
+}
+
+func TestSettleStockUsesReservedQuantities(t *testing.T) {
+	strg := newTestStore(t)
+	ctx := context.Background()
+	bookId := stockedBook(t, strg, 10)
+
+	orderId, err := strg.Order().Create(ctx, &models.CreateOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := strg.OrderItem().Create(ctx, &models.CreateOrderItem{OrderId: orderId, BookId: bookId, Quantity: 2, UnitPrice: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStock(t, strg, bookId, 10, 2)
+	quantity := 4
+	_, err = strg.OrderItem().Patch(ctx, &models.PatchOrderItem{ItemId: itemId, Quantity: &quantity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStock(t, strg, bookId, 10, 4)
+
+	transition(t, strg, orderId, models.OrderStatusPaid)
+	quantity = 1
+	_, err = strg.OrderItem().Patch(ctx, &models.PatchOrderItem{ItemId: itemId, Quantity: &quantity})
+	if !errors.Is(err, storage.ErrOrderNotPending) {
+		t.Fatalf("patching an item of a paid order = %v, want ErrOrderNotPending", err)
+	}
+	transition(t, strg, orderId, models.OrderStatusShipped)
+	expectStock(t, strg, bookId, 6, 0)
+}
+
+func TestOrderItemStockIsAllOrNothing(t *testing.T) {
+	strg := newTestStore(t)
+	ctx := context.Background()
+	a, b := stockedBook(t, strg, 5), stockedBook(t, strg, 1)
+
+	orderId, err := strg.Order().Create(ctx, &models.CreateOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := strg.OrderItem().Create(ctx, &models.CreateOrderItem{OrderId: orderId, BookId: a, Quantity: 3, UnitPrice: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// moving the item to a book without enough stock must not release a
+	quantity := 3
+	_, err = strg.OrderItem().Patch(ctx, &models.PatchOrderItem{ItemId: itemId, BookId: &b, Quantity: &quantity})
+	if !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("patch beyond stock = %v, want ErrInsufficientStock", err)
+	}
+	expectStock(t, strg, a, 5, 3)
+	expectStock(t, strg, b, 1, 0)
+
+	err = strg.OrderItem().Delete(ctx, &models.OrderItemPrimaryKey{ItemId: itemId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStock(t, strg, a, 5, 0)
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepo struct {
+	db *database
+}
+
+// Create stores a new reset code, superseding any code still pending for the user
+func (s PasswordResetRepo) Create(ctx context.Context, req *models.CreatePasswordReset) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var (
+		id  = uuid.New().String()
+		now = time.Now().UTC()
+	)
+	for _, row := range s.db.passwordResets {
+		if row.UserId == req.UserId && row.UsedAt == nil {
+			usedAt := now
+			row.UsedAt = &usedAt
+		}
+	}
+
+	s.db.passwordResets = append(s.db.passwordResets, &models.PasswordReset{
+		Id:        id,
+		UserId:    req.UserId,
+		CodeHash:  req.CodeHash,
+		ExpiresAt: req.ExpiresAt.UTC(),
+		CreatedAt: now,
+	})
+	return id, nil
+}
+
+func (s PasswordResetRepo) GetPending(ctx context.Context, req *models.PasswordResetPrimaryKey) (*models.PasswordReset, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var now = time.Now().UTC()
+	for i := len(s.db.passwordResets) - 1; i >= 0; i-- {
+		row := s.db.passwordResets[i]
+		if row.UsedAt != nil || !row.ExpiresAt.After(now) {
+			continue
+		}
+		if (req.Id != "" && row.Id == req.Id) || (req.Id == "" && row.UserId == req.UserId) {
+			reset := *row
+			return &reset, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s PasswordResetRepo) CountSince(ctx context.Context, req *models.PasswordResetCountRequest) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var count int
+	for _, row := range s.db.passwordResets {
+		if row.UserId == req.UserId && !row.CreatedAt.Before(req.Since.UTC()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// AddAttempt reserves a guess before the code is compared, returning the
+// attempts made so far; no rows means the code is used, expired or out of guesses
+func (s PasswordResetRepo) AddAttempt(ctx context.Context, req *models.PasswordResetAttempt) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var now = time.Now().UTC()
+	for _, row := range s.db.passwordResets {
+		if row.Id == req.Id && row.Attempts < req.MaxAttempts && row.UsedAt == nil && row.ExpiresAt.After(now) {
+			row.Attempts++
+			return row.Attempts, nil
+		}
+	}
+	return 0, errNoRows
+}
+
+// Consume marks the code used; zero rows affected means it was already used
+func (s PasswordResetRepo) Consume(ctx context.Context, req *models.PasswordResetAttempt) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.passwordResets {
+		if row.Id == req.Id && row.UsedAt == nil && row.Attempts <= req.MaxAttempts {
+			usedAt := time.Now().UTC()
+			row.UsedAt = &usedAt
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func NewPasswordResetRepo(db *database) *PasswordResetRepo {
+	return &PasswordResetRepo{
+		db: db,
+	}
+}
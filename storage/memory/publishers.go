@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type publisherRow struct {
+	meta
+	publisher models.Publisher
+}
+
+func (row *publisherRow) field(name string) interface{} {
+	switch name {
+	case "id":
+		return row.publisher.Id
+	case "name":
+		return row.publisher.Name
+	}
+	return row.meta.field(name)
+}
+
+func (row *publisherRow) key() string {
+	return row.publisher.Id
+}
+
+type PublisherRepo struct {
+	db *database
+}
+
+func (s PublisherRepo) Create(ctx context.Context, req *models.CreatePublisher) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.publishers = append(s.db.publishers, &publisherRow{
+		meta: newMeta(),
+		publisher: models.Publisher{
+			Id:      id,
+			Version: 1,
+			Name:    req.Name,
+			Website: req.Website,
+		},
+	})
+	return id, nil
+}
+
+func (s PublisherRepo) Update(ctx context.Context, req *models.UpdatePublisher) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.publishers {
+		if row.publisher.Id != req.Id {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.publisher.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		row.publisher = models.Publisher{
+			Id:      req.Id,
+			Version: row.publisher.Version,
+			Name:    req.Name,
+			Website: req.Website,
+		}
+		row.updatedAt = time.Now()
+		row.publisher.Version++
+		affected++
+	}
+	if affected > 0 {
+		s.db.refreshBookNames()
+	}
+	return affected, nil
+}
+
+func (s PublisherRepo) Patch(ctx context.Context, req *models.PatchPublisher) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.publishers {
+		if row.publisher.Id != req.Id || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.publisher.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if req.Name != nil {
+			row.publisher.Name = *req.Name
+		}
+		if req.Website != nil {
+			row.publisher.Website = *req.Website
+		}
+		row.updatedAt = time.Now()
+		row.publisher.Version++
+		affected++
+	}
+	if affected > 0 && req.Name != nil {
+		s.db.refreshBookNames()
+	}
+	return affected, nil
+}
+
+func (s PublisherRepo) GetById(ctx context.Context, req *models.PublisherPrimaryKey) (*models.Publisher, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.publishers {
+		if !row.isDeleted && row.publisher.Id == req.Id {
+			publisher := row.publisher
+			return &publisher, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s PublisherRepo) GetList(ctx context.Context, req *models.PublisherGetListRequest) (*models.PublisherGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.PublisherGetListResponse{}
+		rows []*publisherRow
+	)
+	for _, row := range s.db.publishers {
+		if row.isDeleted {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&publisherRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		publisher := row.publisher
+		resp.Publishers = append(resp.Publishers, &publisher)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s PublisherRepo) Delete(ctx context.Context, req *models.PublisherPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.publishers {
+		if row.publisher.Id == req.Id {
+			if ok, err := checkVersion(row.meta, row.publisher.Version, req.Version); !ok {
+				return err
+			}
+			row.isDeleted = true
+			row.publisher.Version++
+			row.updatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (db *database) publisher(id string) *publisherRow {
+	for _, row := range db.publishers {
+		if row.publisher.Id == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func NewPublisherRepo(db *database) *PublisherRepo {
+	return &PublisherRepo{
+		db: db,
+	}
+}
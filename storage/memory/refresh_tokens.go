@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepo struct {
+	db *database
+}
+
+func (s RefreshTokenRepo) Create(ctx context.Context, req *models.CreateRefreshToken) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var id = uuid.New().String()
+	s.db.refreshTokens = append(s.db.refreshTokens, &models.RefreshToken{
+		Id:        id,
+		UserId:    req.UserId,
+		FamilyId:  req.FamilyId,
+		TokenHash: req.TokenHash,
+		ExpiresAt: req.ExpiresAt.UTC(),
+	})
+	return id, nil
+}
+
+func (s RefreshTokenRepo) GetById(ctx context.Context, req *models.RefreshTokenPrimaryKey) (*models.RefreshToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.refreshTokens {
+		if (req.Id != "" && row.Id == req.Id) || (req.Id == "" && row.TokenHash == req.TokenHash) {
+			token := *row
+			return &token, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s RefreshTokenRepo) Revoke(ctx context.Context, req *models.RevokeRefreshToken) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var (
+		affected int64
+		now      = time.Now().UTC()
+	)
+	for _, row := range s.db.refreshTokens {
+		if row.RevokedAt != nil {
+			continue
+		}
+		switch {
+		case req.Id != "":
+			if row.Id != req.Id {
+				continue
+			}
+		case req.FamilyId != "":
+			if row.FamilyId != req.FamilyId {
+				continue
+			}
+		default:
+			if row.UserId != req.UserId {
+				continue
+			}
+		}
+		revokedAt := now
+		row.RevokedAt = &revokedAt
+		row.ReplacedBy = req.ReplacedBy
+		affected++
+	}
+	return affected, nil
+}
+
+func NewRefreshTokenRepo(db *database) *RefreshTokenRepo {
+	return &RefreshTokenRepo{
+		db: db,
+	}
+}
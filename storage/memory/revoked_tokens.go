@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"time"
+)
+
+type revokedTokenRow struct {
+	jti       string
+	userId    string
+	expiresAt time.Time
+	revokedAt time.Time
+}
+
+type RevokedTokenRepo struct {
+	db *database
+}
+
+func (s RevokedTokenRepo) Create(ctx context.Context, req *models.CreateRevokedToken) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if req.Jti != "" {
+		for _, row := range s.db.revokedTokens {
+			if row.jti == req.Jti {
+				return nil
+			}
+		}
+	}
+
+	s.db.revokedTokens = append(s.db.revokedTokens, &revokedTokenRow{
+		jti:       req.Jti,
+		userId:    req.UserId,
+		expiresAt: req.ExpiresAt.UTC(),
+		revokedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+func (s RevokedTokenRepo) IsRevoked(ctx context.Context, req *models.RevokedTokenPrimaryKey) (bool, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.revokedTokens {
+		if req.Jti != "" && row.jti == req.Jti {
+			return true, nil
+		}
+		if row.jti == "" && row.userId == req.UserId && row.revokedAt.After(req.IssuedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s RevokedTokenRepo) Purge(ctx context.Context, req *models.PurgeRevokedTokens) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var (
+		kept     = s.db.revokedTokens[:0]
+		affected int64
+	)
+	for _, row := range s.db.revokedTokens {
+		if !row.expiresAt.IsZero() && row.expiresAt.Before(req.Before) {
+			affected++
+			continue
+		}
+		kept = append(kept, row)
+	}
+	s.db.revokedTokens = kept
+	return affected, nil
+}
+
+func NewRevokedTokenRepo(db *database) *RevokedTokenRepo {
+	return &RevokedTokenRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Field weights follow the defaults of Postgres ts_rank for the A, B and C
+// weights given to title, author and publisher
+const (
+	titleWeight     = 1.0
+	authorWeight    = 0.4
+	publisherWeight = 0.2
+)
+
+// searchTerm is one word of a query; a negated term ("-word") excludes a book
+type searchTerm struct {
+	stem    string
+	negated bool
+}
+
+// parseSearch splits query into stemmed terms. Unlike websearch_to_tsquery it
+// has no phrase or OR support: every positive term must match
+func parseSearch(query, lang string) []searchTerm {
+	var terms []searchTerm
+	for _, field := range strings.Fields(query) {
+		negated := strings.HasPrefix(field, "-")
+		for _, word := range words(field) {
+			terms = append(terms, searchTerm{stem: stem(word, lang), negated: negated})
+		}
+	}
+	return terms
+}
+
+// words returns the lower-cased runs of letters and digits in text
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stem is a light suffix stripper for English; other languages match whole
+// words, like the "simple" text search config
+func stem(word, lang string) string {
+	if !strings.EqualFold(lang, "en") {
+		return word
+	}
+	for _, suffix := range []string{"ingly", "edly", "ing", "ies", "ed", "es", "ly", "s"} {
+		if !strings.HasSuffix(word, suffix) || len(word)-len(suffix) < 3 {
+			continue
+		}
+		base := strings.TrimSuffix(word, suffix)
+		switch suffix {
+		case "ies":
+			base += "y"
+		case "ing", "ed", "ingly", "edly":
+			// running -> run, stopped -> stop
+			if n := len(base); base[n-1] == base[n-2] && !strings.ContainsRune("aeiouls", rune(base[n-1])) {
+				base = base[:n-1]
+			}
+		}
+		return base
+	}
+	return word
+}
+
+// stems returns the set of stems occurring in text
+func stems(text, lang string) map[string]bool {
+	var set = map[string]bool{}
+	for _, word := range words(text) {
+		set[stem(word, lang)] = true
+	}
+	return set
+}
+
+// highlight wraps every word of text whose stem is in match in <mark> tags
+func highlight(text, lang string, match map[string]bool) string {
+	var (
+		out   strings.Builder
+		runes = []rune(text)
+	)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		word := string(runes[i:j])
+		if match[stem(strings.ToLower(word), lang)] {
+			out.WriteString("<mark>" + word + "</mark>")
+		} else {
+			out.WriteString(word)
+		}
+		i = j
+	}
+	return out.String()
+}
@@ -0,0 +1,296 @@
+package memory
+
+import (
+	"app/api/models"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type userRow struct {
+	meta
+	user models.User
+}
+
+func (row *userRow) field(name string) interface{} {
+	switch name {
+	case "id":
+		return row.user.Id
+	case "first_name":
+		return row.user.FirstName
+	case "last_name":
+		return row.user.LastName
+	case "age":
+		return row.user.Age
+	case "username":
+		return row.user.Username
+	case "email":
+		return row.user.Email
+	case "role":
+		return row.user.Role
+	case "is_verified":
+		return row.user.IsVerified
+	}
+	return row.meta.field(name)
+}
+
+func (row *userRow) key() string {
+	return row.user.Id
+}
+
+type UserRepo struct {
+	db *database
+}
+
+func (s UserRepo) Create(ctx context.Context, req *models.CreateUser) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.users {
+		if row.user.Username == req.Username {
+			return "", errors.New("duplicate key value violates unique constraint \"users_username_key\"")
+		}
+		if req.Email != "" && strings.EqualFold(row.user.Email, req.Email) {
+			return "", errors.New("duplicate key value violates unique constraint \"users_email_key\"")
+		}
+	}
+
+	var id = uuid.New().String()
+	if req.Role == "" {
+		req.Role = models.RoleCustomer
+	}
+	s.db.users = append(s.db.users, &userRow{
+		meta: newMeta(),
+		user: models.User{
+			Id:        id,
+			Version:   1,
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+			Age:       req.Age,
+			Phone:     req.Phone,
+			Picture:   req.Picture,
+			Username:  req.Username,
+			Email:     req.Email,
+			Password:  req.Password,
+			CardNo:    req.CardNo,
+			Role:      req.Role,
+		},
+	})
+	return id, nil
+}
+
+func (s UserRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.users {
+		if row.user.Id != req.Id {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.user.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		verified := row.user.IsVerified && row.user.Email == req.Email
+		row.user = models.User{
+			Id:         req.Id,
+			Version:    row.user.Version,
+			FirstName:  req.FirstName,
+			LastName:   req.LastName,
+			Age:        req.Age,
+			Phone:      req.Phone,
+			Picture:    req.Picture,
+			Username:   req.Username,
+			Email:      req.Email,
+			Password:   row.user.Password,
+			CardNo:     req.CardNo,
+			Role:       req.Role,
+			IsVerified: verified,
+		}
+		row.updatedAt = time.Now()
+		row.user.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s UserRepo) Patch(ctx context.Context, req *models.PatchUser) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.users {
+		if row.user.Id != req.Id || row.isDeleted {
+			continue
+		}
+		if ok, err := checkVersion(row.meta, row.user.Version, req.Version); !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if req.FirstName != nil {
+			row.user.FirstName = *req.FirstName
+		}
+		if req.LastName != nil {
+			row.user.LastName = *req.LastName
+		}
+		if req.Age != nil {
+			row.user.Age = *req.Age
+		}
+		if req.Phone != nil {
+			row.user.Phone = *req.Phone
+		}
+		if req.Picture != nil {
+			row.user.Picture = *req.Picture
+		}
+		if req.Username != nil {
+			row.user.Username = *req.Username
+		}
+		if req.Email != nil {
+			row.user.IsVerified = row.user.IsVerified && row.user.Email == *req.Email
+			row.user.Email = *req.Email
+		}
+		if req.CardNo != nil {
+			row.user.CardNo = *req.CardNo
+		}
+		if req.Role != nil {
+			row.user.Role = *req.Role
+		}
+		row.updatedAt = time.Now()
+		row.user.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s UserRepo) UpdatePassword(ctx context.Context, req *models.UpdatePassword) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.users {
+		if row.user.Id != req.Id {
+			continue
+		}
+		row.user.Password = req.Password
+		row.updatedAt = time.Now()
+		row.user.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s UserRepo) Verify(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var affected int64
+	for _, row := range s.db.users {
+		if row.isDeleted || row.user.Id != req.Id {
+			continue
+		}
+		row.user.IsVerified = true
+		row.updatedAt = time.Now()
+		row.user.Version++
+		affected++
+	}
+	return affected, nil
+}
+
+func (s UserRepo) GetById(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.users {
+		if row.isDeleted {
+			continue
+		}
+		var match bool
+		switch {
+		case req.Id != "":
+			match = row.user.Id == req.Id
+		case req.Username != "":
+			match = row.user.Username == req.Username
+		default:
+			match = row.user.Email != "" && strings.EqualFold(row.user.Email, req.Email)
+		}
+		if match {
+			user := row.user
+			return &user, nil
+		}
+	}
+	return nil, errNoRows
+}
+
+func (s UserRepo) GetList(ctx context.Context, req *models.UserGetListRequest) (*models.UserGetListResponse, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var (
+		resp = &models.UserGetListResponse{}
+		rows []*userRow
+	)
+	for _, row := range s.db.users {
+		if row.isDeleted {
+			continue
+		}
+		ok, err := matches(row, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := checkSorts(&userRow{}, req.Sorts); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j], req.Sorts) })
+
+	start, end, next, prev, err := listPage(len(rows), func(i int) listRow { return rows[i] }, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows[start:end] {
+		user := row.user
+		resp.Users = append(resp.Users, &user)
+	}
+	if req.Count {
+		count := len(rows)
+		resp.Count = &count
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s UserRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, row := range s.db.users {
+		if row.user.Id == req.Id {
+			if ok, err := checkVersion(row.meta, row.user.Version, req.Version); !ok {
+				return err
+			}
+			row.isDeleted = true
+			row.user.Version++
+			row.updatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func NewUserRepo(db *database) *UserRepo {
+	return &UserRepo{
+		db: db,
+	}
+}
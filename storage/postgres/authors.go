@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
+)
+
+// authorColumns maps the fields a list may be filtered and sorted by to columns
+var authorColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type AuthorRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s AuthorRepo) Create(ctx context.Context, req *models.CreateAuthor) (string, error) {
+	var id = uuid.New().String()
+	query := `INSERT INTO authors(id, name, bio) VALUES ($1, $2, $3)`
+
+	_, err := s.db.Exec(ctx, query, id, req.Name, req.Bio)
+
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s AuthorRepo) Update(ctx context.Context, req *models.UpdateAuthor) (int64, error) {
+	var params map[string]interface{}
+	query := `
+		UPDATE authors 
+		SET name = :name,
+		    bio = :bio,
+		    updated_at = now(),
+		    version = version + 1
+		WHERE id = :id`
+
+	params = map[string]interface{}{
+		"id":   req.Id,
+		"name": req.Name,
+		"bio":  req.Bio,
+	}
+
+	query = whereVersion(query, params, req.Version)
+	query, args := helper.ReplaceQueryParams(query, params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = refreshBookNames(ctx, tx, "id IN (SELECT book_id FROM book_authors WHERE author_id = $1)", req.Id)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "authors", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s AuthorRepo) Patch(ctx context.Context, req *models.PatchAuthor) (int64, error) {
+	var p = newPatch()
+	if req.Name != nil {
+		p.add("name", *req.Name)
+	}
+	if req.Bio != nil {
+		p.add("bio", *req.Bio)
+	}
+
+	params := map[string]interface{}{"id": req.Id}
+	query, args := p.query("authors", whereVersion("id = :id AND is_deleted = FALSE", params, req.Version), params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if req.Name != nil {
+		err = refreshBookNames(ctx, tx, "id IN (SELECT book_id FROM book_authors WHERE author_id = $1)", req.Id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "authors", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s AuthorRepo) GetById(ctx context.Context, req *models.AuthorPrimaryKey) (*models.Author, error) {
+	var (
+		id      sql.NullString
+		name    sql.NullString
+		bio     sql.NullString
+		version int
+	)
+
+	query := `SELECT id, name, bio, version FROM authors WHERE id = $1 AND is_deleted = FALSE`
+
+	err := s.db.QueryRow(ctx, query, req.Id).Scan(
+		&id,
+		&name,
+		&bio,
+		&version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Author{
+		Id:      id.String,
+		Name:    name.String,
+		Bio:     bio.String,
+		Version: version,
+	}, nil
+}
+
+func (s AuthorRepo) GetList(ctx context.Context, req *models.AuthorGetListRequest) (*models.AuthorGetListResponse, error) {
+	var (
+		resp  = &models.AuthorGetListResponse{}
+		where = " WHERE is_deleted = FALSE "
+		keys  []models.Cursor
+	)
+	query := `SELECT id, name, bio, version, created_at FROM authors`
+	var args []interface{}
+	where, args, err := listWhere(where, req.Filters, authorColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "authors", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, authorColumns, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	query += where + page
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			name      sql.NullString
+			bio       sql.NullString
+			version   int
+			createdAt sql.NullTime
+		)
+		err := rows.Scan(
+			&id,
+			&name,
+			&bio,
+			&version,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		resp.Authors = append(
+			resp.Authors,
+			&models.Author{
+				Id:      id.String,
+				Name:    name.String,
+				Bio:     bio.String,
+				Version: version,
+			})
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: id.String})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Authors = resp.Authors[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Authors)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s AuthorRepo) Delete(ctx context.Context, req *models.AuthorPrimaryKey) error {
+	params := map[string]interface{}{"id": req.Id}
+	query, args := helper.ReplaceQueryParams(`UPDATE authors SET is_deleted = true, updated_at = now(), version = version + 1 WHERE `+whereVersion("id = :id", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkVersion(ctx, s.db, "authors", "id", req.Id, req.Version, result.RowsAffected())
+	return err
+}
+
+func NewAuthorRepo(db *pgxpool.Pool) *AuthorRepo {
+	return &AuthorRepo{
+		db: db,
+	}
+}
@@ -7,23 +7,65 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
 )
 
+// bookColumns maps the fields a list may be filtered and sorted by to columns
+var bookColumns = map[string]string{
+	"id":             "id",
+	"title":          "title",
+	"author":         "author",
+	"publisher":      "publisher",
+	"author_id":      "id IN (SELECT book_id FROM book_authors WHERE author_id %s)",
+	"publisher_id":   "publisher_id",
+	"category":       "id IN (SELECT book_id FROM book_categories WHERE category_id %s)",
+	"tag":            "id IN (SELECT book_id FROM book_tags WHERE tag %s)",
+	"num_pages":      "num_pages",
+	"lang":           "lang",
+	"price":          "price",
+	"currency":       "currency",
+	"stock_quantity": "stock_quantity",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}
+
 type BookRepo struct {
 	db *pgxpool.Pool
 }
 
 func (s BookRepo) Create(ctx context.Context, req *models.CreateBook) (string, error) {
 	var id = uuid.New().String()
-	query := `INSERT INTO books(id, title, author, publisher, category, num_pages, picture, lang) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	query := `INSERT INTO books(id, title, author, publisher, publisher_id, num_pages, picture, lang, price, currency) VALUES ($1, $2, '', '', $3, $4, $5, $6, $7, $8)`
 
-	_, err := s.db.Exec(ctx, query, id, req.Title, req.Author, req.Publisher, req.Category, req.NumPages, req.Picture, req.Lang)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
 
+	_, err = tx.Exec(ctx, query, id, req.Title, helper.NewNullString(req.PublisherId), req.NumPages, req.Picture, req.Lang, req.Price, req.Currency)
+	if err != nil {
+		return "", err
+	}
+	err = setBookAuthors(ctx, tx, id, req.Authors, false)
 	if err != nil {
 		return "", err
 	}
-	return id, nil
+	err = refreshBookNames(ctx, tx, "id = $1", id)
+	if err != nil {
+		return "", err
+	}
+	err = setBookCategories(ctx, tx, id, req.CategoryIds, false)
+	if err != nil {
+		return "", err
+	}
+	err = setBookTags(ctx, tx, id, req.Tags, false)
+	if err != nil {
+		return "", err
+	}
+	return id, tx.Commit(ctx)
 }
 
 func (s BookRepo) Update(ctx context.Context, req *models.UpdateBook) (int64, error) {
@@ -31,86 +73,313 @@ func (s BookRepo) Update(ctx context.Context, req *models.UpdateBook) (int64, er
 	query := `
 		UPDATE books 
 		SET title = :title,
-		    author = :author, 
-		    publisher = :publisher,
-		    category = :category, 
+		    publisher_id = :publisher_id,
 		    num_pages = :num_pages,
 		    picture = :picture,
 			lang = :lang,
-			updated_at = now()
+			price = :price,
+			currency = :currency,
+			updated_at = now(),
+		    version = version + 1
 		WHERE id = :id`
 
 	params = map[string]interface{}{
-		"id":        req.Id,
-		"title":     req.Title,
-		"author":    req.Author,
-		"publisher": req.Publisher,
-		"category":  req.Category,
-		"num_pages": req.NumPages,
-		"picture":   req.Picture,
-		"lang":      req.Lang,
+		"id":           req.Id,
+		"title":        req.Title,
+		"publisher_id": helper.NewNullString(req.PublisherId),
+		"num_pages":    req.NumPages,
+		"picture":      req.Picture,
+		"lang":         req.Lang,
+		"price":        req.Price,
+		"currency":     req.Currency,
 	}
 
+	query = whereVersion(query, params, req.Version)
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	result, err := s.db.Exec(ctx, query, args...)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback(ctx)
 
-	return result.RowsAffected(), nil
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if result.RowsAffected() > 0 {
+		err = setBookCategories(ctx, tx, req.Id, req.CategoryIds, true)
+		if err != nil {
+			return 0, err
+		}
+		err = setBookTags(ctx, tx, req.Id, req.Tags, true)
+		if err != nil {
+			return 0, err
+		}
+		err = setBookAuthors(ctx, tx, req.Id, req.Authors, true)
+		if err != nil {
+			return 0, err
+		}
+		err = refreshBookNames(ctx, tx, "id = $1", req.Id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "books", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s BookRepo) Patch(ctx context.Context, req *models.PatchBook) (int64, error) {
+	var p = newPatch()
+	if req.Title != nil {
+		p.add("title", *req.Title)
+	}
+	if req.PublisherId != nil {
+		p.add("publisher_id", helper.NewNullString(*req.PublisherId))
+	}
+	if req.NumPages != nil {
+		p.add("num_pages", *req.NumPages)
+	}
+	if req.Picture != nil {
+		p.add("picture", *req.Picture)
+	}
+	if req.Lang != nil {
+		p.add("lang", *req.Lang)
+	}
+	if req.Price != nil {
+		p.add("price", *req.Price)
+	}
+	if req.Currency != nil {
+		p.add("currency", *req.Currency)
+	}
+
+	params := map[string]interface{}{"id": req.Id}
+	query, args := p.query("books", whereVersion("id = :id AND is_deleted = FALSE", params, req.Version), params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if result.RowsAffected() > 0 && req.CategoryIds != nil {
+		err = setBookCategories(ctx, tx, req.Id, *req.CategoryIds, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if result.RowsAffected() > 0 && req.Tags != nil {
+		err = setBookTags(ctx, tx, req.Id, *req.Tags, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if result.RowsAffected() > 0 && req.Authors != nil {
+		err = setBookAuthors(ctx, tx, req.Id, *req.Authors, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if result.RowsAffected() > 0 && (req.Authors != nil || req.PublisherId != nil) {
+		err = refreshBookNames(ctx, tx, "id = $1", req.Id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "books", "id", req.Id, req.Version, result.RowsAffected())
 }
 
 func (s BookRepo) GetById(ctx context.Context, req *models.BookPrimaryKey) (*models.Book, error) {
 	var (
-		id        sql.NullString
-		title     sql.NullString
-		author    sql.NullString
-		publisher sql.NullString
-		category  sql.NullString
-		numPages  int
-		picture   sql.NullString
-		lang      sql.NullString
+		id          sql.NullString
+		title       sql.NullString
+		author      sql.NullString
+		publisher   sql.NullString
+		publisherId sql.NullString
+		numPages    int
+		picture     sql.NullString
+		lang        sql.NullString
+		price       int64
+		currency    sql.NullString
+		stock       int
+		reserved    int
+		version     int
 	)
 
-	query := `SELECT id, title, author, publisher, category, num_pages, picture, lang FROM books WHERE id = $1 AND is_deleted = 'False'`
+	query := `SELECT id, title, author, publisher, publisher_id, num_pages, picture, lang, price, currency, stock_quantity, reserved_quantity, version FROM books WHERE id = $1 AND is_deleted = 'False'`
 
 	err := s.db.QueryRow(ctx, query, req.Id).Scan(
 		&id,
 		&title,
 		&author,
 		&publisher,
-		&category,
+		&publisherId,
 		&numPages,
 		&picture,
 		&lang,
+		&price,
+		&currency,
+		&stock,
+		&reserved,
+		&version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Book{
-		Id:        id.String,
-		Title:     title.String,
-		Author:    author.String,
-		Publisher: publisher.String,
-		Category:  category.String,
-		NumPages:  numPages,
-		Picture:   picture.String,
-		Lang:      lang.String,
-	}, nil
+	book := &models.Book{
+		Id:               id.String,
+		Title:            title.String,
+		Author:           author.String,
+		Publisher:        publisher.String,
+		PublisherId:      publisherId.String,
+		NumPages:         numPages,
+		Picture:          picture.String,
+		Lang:             lang.String,
+		Price:            price,
+		Currency:         currency.String,
+		StockQuantity:    stock,
+		ReservedQuantity: reserved,
+		Version:          version,
+	}
+	return book, s.expand(ctx, book)
 }
 
 func (s BookRepo) GetList(ctx context.Context, req *models.BookGetListRequest) (*models.BookGetListResponse, error) {
 	var (
-		resp   = &models.BookGetListResponse{}
-		where  = " WHERE is_deleted = False "
+		resp  = &models.BookGetListResponse{}
+		where = " WHERE is_deleted = False "
+		keys  []models.Cursor
+	)
+	query := `SELECT id, title, author, publisher, publisher_id, num_pages, picture, lang, price, currency, stock_quantity, reserved_quantity, version, created_at FROM books`
+	var args []interface{}
+	where, args, err := listWhere(where, req.Filters, bookColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "books", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, bookColumns, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	query += where + page
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			id          sql.NullString
+			title       sql.NullString
+			author      sql.NullString
+			publisher   sql.NullString
+			publisherId sql.NullString
+			numPages    int
+			picture     sql.NullString
+			lang        sql.NullString
+			price       int64
+			currency    sql.NullString
+			stock       int
+			reserved    int
+			version     int
+			createdAt   sql.NullTime
+		)
+		err := rows.Scan(
+			&id,
+			&title,
+			&author,
+			&publisher,
+			&publisherId,
+			&numPages,
+			&picture,
+			&lang,
+			&price,
+			&currency,
+			&stock,
+			&reserved,
+			&version,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		resp.Books = append(
+			resp.Books,
+			&models.Book{
+				Id:               id.String,
+				Title:            title.String,
+				Author:           author.String,
+				Publisher:        publisher.String,
+				PublisherId:      publisherId.String,
+				NumPages:         numPages,
+				Picture:          picture.String,
+				Lang:             lang.String,
+				Price:            price,
+				Currency:         currency.String,
+				StockQuantity:    stock,
+				ReservedQuantity: reserved,
+				Version:          version,
+			})
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: id.String})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Books = resp.Books[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Books)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, s.expand(ctx, resp.Books...)
+}
+
+// bookSearchConfigs lists every text search config book_search_config can return
+var bookSearchConfigs = []string{"simple", "english", "russian", "german", "french", "spanish", "italian", "portuguese", "turkish"}
+
+// Search parses the query once per text search config and matches each book
+// against the one for its lang, so that stemming follows the book's language
+func (s BookRepo) Search(ctx context.Context, req *models.BookSearchRequest) (*models.BookSearchResponse, error) {
+	var (
+		resp   = &models.BookSearchResponse{}
+		where  = " WHERE b.is_deleted = FALSE "
+		order  = " ORDER BY rank DESC, b.id "
 		offset = " OFFSET 0"
 		limit  = " LIMIT 10"
-		//order  = " ORDER BY created_at DESC "
+		args   = []interface{}{req.Query, "StartSel=<mark>, StopSel=</mark>, HighlightAll=TRUE", bookSearchConfigs}
 	)
-	query := `SELECT COUNT(*) OVER(), id, title, author, publisher, category, num_pages, picture, lang FROM books`
+	query := `
+		WITH q AS (
+			SELECT name::regconfig AS cfg, websearch_to_tsquery(name::regconfig, $1) AS query
+			FROM unnest($3::text[]) AS name
+		)
+		SELECT COUNT(*) OVER(), b.id, b.title, b.author, b.publisher, b.publisher_id, b.num_pages, b.picture, b.lang, b.price, b.currency, b.stock_quantity, b.reserved_quantity, b.version,
+			ts_rank_cd(b.search_vector, q.query) AS rank,
+			ts_headline(q.cfg, b.title, q.query, $2),
+			ts_headline(q.cfg, b.author, q.query, $2),
+			ts_headline(q.cfg, b.publisher, q.query, $2)
+		FROM books b
+		JOIN q ON q.cfg = book_search_config(b.lang) AND b.search_vector @@ q.query`
 	if req.Offset > 0 {
 		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
 	}
@@ -119,29 +388,37 @@ func (s BookRepo) GetList(ctx context.Context, req *models.BookGetListRequest) (
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	//if req.Search != "" {
-	//	where += ` AND title ILIKE '%' || '` + req.Search + `' || '%'`
-	//}
+	if req.Lang != "" {
+		args = append(args, req.Lang)
+		where += fmt.Sprintf(" AND LOWER(b.lang) = LOWER($%d) ", len(args))
+	}
 
-	//query += where + order + offset + limit
-	query += where + offset + limit
+	query += where + order + offset + limit
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
-			count     int
-			id        sql.NullString
-			title     sql.NullString
-			author    sql.NullString
-			publisher sql.NullString
-			category  sql.NullString
-			numPages  int
-			picture   sql.NullString
-			lang      sql.NullString
+			count       int
+			id          sql.NullString
+			title       sql.NullString
+			author      sql.NullString
+			publisher   sql.NullString
+			publisherId sql.NullString
+			numPages    int
+			picture     sql.NullString
+			lang        sql.NullString
+			price       int64
+			currency    sql.NullString
+			stock       int
+			reserved    int
+			version     int
+			rank        float64
+			highlight   models.BookHighlight
 		)
 		err := rows.Scan(
 			&count,
@@ -149,33 +426,314 @@ func (s BookRepo) GetList(ctx context.Context, req *models.BookGetListRequest) (
 			&title,
 			&author,
 			&publisher,
-			&category,
+			&publisherId,
 			&numPages,
 			&picture,
 			&lang,
+			&price,
+			&currency,
+			&stock,
+			&reserved,
+			&version,
+			&rank,
+			&highlight.Title,
+			&highlight.Author,
+			&highlight.Publisher,
 		)
 		if err != nil {
 			return nil, err
 		}
-		resp.Books = append(
-			resp.Books,
-			&models.Book{
-				Id:        id.String,
-				Title:     title.String,
-				Author:    author.String,
-				Publisher: publisher.String,
-				Category:  category.String,
-				NumPages:  numPages,
-				Picture:   picture.String,
-				Lang:      lang.String,
-			})
+		resp.Books = append(resp.Books, &models.BookSearchResult{
+			Book: &models.Book{
+				Id:               id.String,
+				Title:            title.String,
+				Author:           author.String,
+				Publisher:        publisher.String,
+				PublisherId:      publisherId.String,
+				NumPages:         numPages,
+				Picture:          picture.String,
+				Lang:             lang.String,
+				Price:            price,
+				Currency:         currency.String,
+				StockQuantity:    stock,
+				ReservedQuantity: reserved,
+				Version:          version,
+			},
+			Rank:      rank,
+			Highlight: highlight,
+		})
 		resp.Count = count
 	}
-	return resp, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var books = make([]*models.Book, 0, len(resp.Books))
+	for _, result := range resp.Books {
+		books = append(books, result.Book)
+	}
+	return resp, s.expand(ctx, books...)
+}
+
+// AddCategories links the book to req.CategoryIds and returns 0 when the book
+// does not exist
+func (s BookRepo) AddCategories(ctx context.Context, req *models.BookCategories) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	affected, err := touchBook(ctx, tx, req.BookId)
+	if err != nil || affected == 0 {
+		return 0, err
+	}
+	err = setBookCategories(ctx, tx, req.BookId, req.CategoryIds, false)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+// RemoveCategory unlinks the book from req.CategoryId and returns 0 when they
+// were not linked
+func (s BookRepo) RemoveCategory(ctx context.Context, req *models.BookCategoryPrimaryKey) (int64, error) {
+	return s.unlink(ctx, `DELETE FROM book_categories WHERE book_id = $1 AND category_id = $2`, req.BookId, req.CategoryId)
+}
+
+// AddTags tags the book with req.Tags and returns 0 when the book does not exist
+func (s BookRepo) AddTags(ctx context.Context, req *models.BookTags) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	affected, err := touchBook(ctx, tx, req.BookId)
+	if err != nil || affected == 0 {
+		return 0, err
+	}
+	err = setBookTags(ctx, tx, req.BookId, req.Tags, false)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+// RemoveTag removes req.Tag from the book and returns 0 when it was not tagged
+func (s BookRepo) RemoveTag(ctx context.Context, req *models.BookTagPrimaryKey) (int64, error) {
+	return s.unlink(ctx, `DELETE FROM book_tags WHERE book_id = $1 AND tag = $2`, req.BookId, req.Tag)
+}
+
+// unlink runs a DELETE of one category or tag of a live book and bumps the
+// book's version when it removed anything
+func (s BookRepo) unlink(ctx context.Context, query, bookId, value string) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	affected, err := touchBook(ctx, tx, bookId)
+	if err != nil || affected == 0 {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, query, bookId, value)
+	if err != nil || result.RowsAffected() == 0 {
+		return 0, err
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
+// expand loads the authors, categories and tags of books
+func (s BookRepo) expand(ctx context.Context, books ...*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	var (
+		ids  = make([]string, 0, len(books))
+		byId = make(map[string]*models.Book, len(books))
+	)
+	for _, book := range books {
+		book.Authors, book.Categories, book.Tags = []*models.BookAuthor{}, []*models.Category{}, []string{}
+		ids = append(ids, book.Id)
+		byId[book.Id] = book
+	}
+
+	query := `
+		SELECT ba.book_id, a.id, a.name, ba.role
+		FROM book_authors ba
+		JOIN authors a ON a.id = ba.author_id AND a.is_deleted = FALSE
+		WHERE ba.book_id = ANY($1::text[]::uuid[])
+		ORDER BY ba.position, ba.role`
+	rows, err := s.db.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			bookId string
+			author models.BookAuthor
+		)
+		err := rows.Scan(&bookId, &author.AuthorId, &author.Name, &author.Role)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		byId[bookId].Authors = append(byId[bookId].Authors, &author)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		SELECT bc.book_id, c.id, c.parent_id, c.name, c.type, c.picture, c.version
+		FROM book_categories bc
+		JOIN categories c ON c.id = bc.category_id AND c.is_deleted = FALSE
+		WHERE bc.book_id = ANY($1::text[]::uuid[])
+		ORDER BY c.name, c.id`
+	rows, err = s.db.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			bookId   string
+			id       sql.NullString
+			parentId sql.NullString
+			name     sql.NullString
+			Type     sql.NullString
+			picture  sql.NullString
+			version  int
+		)
+		err := rows.Scan(&bookId, &id, &parentId, &name, &Type, &picture, &version)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		book := byId[bookId]
+		book.Categories = append(book.Categories, &models.Category{
+			Id:       id.String,
+			ParentId: parentId.String,
+			Name:     name.String,
+			Type:     Type.String,
+			Picture:  picture.String,
+			Version:  version,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(ctx, `SELECT book_id, tag FROM book_tags WHERE book_id = ANY($1::text[]::uuid[]) ORDER BY tag`, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var bookId, tag string
+		err := rows.Scan(&bookId, &tag)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		byId[bookId].Tags = append(byId[bookId].Tags, tag)
+	}
+	return rows.Err()
+}
+
+// touchBook bumps the version of a live book whose categories or tags change
+func touchBook(ctx context.Context, tx pgx.Tx, bookId string) (int64, error) {
+	result, err := tx.Exec(ctx, `UPDATE books SET updated_at = now(), version = version + 1 WHERE id = $1 AND is_deleted = FALSE`, bookId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// setBookCategories links bookId to categoryIds, replacing its current
+// categories when replace is set
+func setBookCategories(ctx context.Context, tx pgx.Tx, bookId string, categoryIds []string, replace bool) error {
+	if replace {
+		_, err := tx.Exec(ctx, `DELETE FROM book_categories WHERE book_id = $1`, bookId)
+		if err != nil {
+			return err
+		}
+	}
+	if len(categoryIds) == 0 {
+		return nil
+	}
+	query := `INSERT INTO book_categories(book_id, category_id) SELECT $1::uuid, unnest($2::text[]::uuid[]) ON CONFLICT DO NOTHING`
+	_, err := tx.Exec(ctx, query, bookId, categoryIds)
+	return err
+}
+
+// setBookTags tags bookId with tags, replacing its current tags when replace
+// is set
+func setBookTags(ctx context.Context, tx pgx.Tx, bookId string, tags []string, replace bool) error {
+	if replace {
+		_, err := tx.Exec(ctx, `DELETE FROM book_tags WHERE book_id = $1`, bookId)
+		if err != nil {
+			return err
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	query := `INSERT INTO book_tags(book_id, tag) SELECT $1::uuid, unnest($2::text[]) ON CONFLICT DO NOTHING`
+	_, err := tx.Exec(ctx, query, bookId, tags)
+	return err
+}
+
+// setBookAuthors credits authors on bookId in the given order, replacing its
+// current authors when replace is set
+func setBookAuthors(ctx context.Context, tx pgx.Tx, bookId string, authors []*models.BookAuthor, replace bool) error {
+	if replace {
+		_, err := tx.Exec(ctx, `DELETE FROM book_authors WHERE book_id = $1`, bookId)
+		if err != nil {
+			return err
+		}
+	}
+	for i, author := range authors {
+		query := `INSERT INTO book_authors(book_id, author_id, role, position) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`
+		_, err := tx.Exec(ctx, query, bookId, author.AuthorId, author.Role, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshBookNames rewrites the author and publisher text of the books
+// matching where from the credited authors and the publisher, which keeps
+// search and the text filters working on current names
+func refreshBookNames(ctx context.Context, tx pgx.Tx, where string, args ...interface{}) error {
+	query := `
+		UPDATE books b
+		SET author = COALESCE((
+				SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+				FROM book_authors ba
+				JOIN authors a ON a.id = ba.author_id AND a.is_deleted = FALSE
+				WHERE ba.book_id = b.id AND ba.role = 'author'
+			), ''),
+		    publisher = COALESCE((
+				SELECT p.name FROM publishers p WHERE p.id = b.publisher_id AND p.is_deleted = FALSE
+			), '')
+		WHERE ` + where
+	_, err := tx.Exec(ctx, query, args...)
+	return err
 }
 
 func (s BookRepo) Delete(ctx context.Context, req *models.BookPrimaryKey) error {
-	_, err := s.db.Exec(ctx, "UPDATE books SET is_deleted = true, updated_at = now() WHERE id = $1", req.Id)
+	params := map[string]interface{}{"id": req.Id}
+	query, args := helper.ReplaceQueryParams(`UPDATE books SET is_deleted = true, updated_at = now(), version = version + 1 WHERE `+whereVersion("id = :id", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkVersion(ctx, s.db, "books", "id", req.Id, req.Version, result.RowsAffected())
 	return err
 }
 
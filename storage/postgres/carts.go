@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type CartRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s CartRepo) Create(ctx context.Context, req *models.CreateCart) (string, error) {
+	var id string
+	err := s.db.QueryRow(ctx, createCart, uuid.New().String(), helper.NewNullString(req.UserId)).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// createCart inserts a cart, or returns the user's when they have one. A
+// visitor cart has a null user_id, which never conflicts
+const createCart = `
+	INSERT INTO carts(cart_id, user_id) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET updated_at = carts.updated_at
+	RETURNING cart_id`
+
+func (s CartRepo) GetById(ctx context.Context, req *models.CartPrimaryKey) (*models.Cart, error) {
+	var (
+		cart   models.Cart
+		userId sql.NullString
+	)
+	query := `SELECT cart_id, user_id, version FROM carts WHERE cart_id = $1`
+	args := []interface{}{req.CartId}
+	if req.CartId == "" {
+		query = `SELECT cart_id, user_id, version FROM carts WHERE user_id = $1`
+		args = []interface{}{req.UserId}
+	}
+	err := s.db.QueryRow(ctx, query, args...).Scan(&cart.CartId, &userId, &cart.Version)
+	if err != nil {
+		return nil, err
+	}
+	cart.UserId = userId.String
+
+	rows, err := s.db.Query(ctx, `SELECT book_id, quantity FROM cart_items WHERE cart_id = $1 ORDER BY created_at, book_id`, cart.CartId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart.Items = []*models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		err := rows.Scan(&item.BookId, &item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, &item)
+	}
+	return &cart, rows.Err()
+}
+
+func (s CartRepo) AddItem(ctx context.Context, req *models.CartItemRequest) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ok, err := lockCart(ctx, tx, req.CartId, req.Version)
+	if err != nil || !ok {
+		return 0, err
+	}
+	affected, err := touchCart(ctx, tx, req.CartId)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO cart_items(cart_id, book_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (cart_id, book_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`
+	_, err = tx.Exec(ctx, query, req.CartId, req.BookId, req.Quantity)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+func (s CartRepo) SetItem(ctx context.Context, req *models.CartItemRequest) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ok, err := lockCart(ctx, tx, req.CartId, req.Version)
+	if err != nil || !ok {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, `UPDATE cart_items SET quantity = $3 WHERE cart_id = $1 AND book_id = $2`, req.CartId, req.BookId, req.Quantity)
+	if err != nil || result.RowsAffected() == 0 {
+		return 0, err
+	}
+	affected, err := touchCart(ctx, tx, req.CartId)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+func (s CartRepo) RemoveItem(ctx context.Context, req *models.CartItemPrimaryKey) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ok, err := lockCart(ctx, tx, req.CartId, req.Version)
+	if err != nil || !ok {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND book_id = $2`, req.CartId, req.BookId)
+	if err != nil || result.RowsAffected() == 0 {
+		return 0, err
+	}
+	affected, err := touchCart(ctx, tx, req.CartId)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+func (s CartRepo) Clear(ctx context.Context, req *models.CartPrimaryKey) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ok, err := lockCart(ctx, tx, req.CartId, req.Version)
+	if err != nil || !ok {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, req.CartId)
+	if err != nil {
+		return 0, err
+	}
+	_, err = touchCart(ctx, tx, req.CartId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
+func (s CartRepo) Merge(ctx context.Context, req *models.MergeCart) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var from string
+	err = tx.QueryRow(ctx, `SELECT cart_id FROM carts WHERE cart_id = $1 AND user_id IS NULL FOR UPDATE`, req.CartId).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var into string
+	err = tx.QueryRow(ctx, createCart, uuid.New().String(), req.UserId).Scan(&into)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO cart_items(cart_id, book_id, quantity, created_at)
+		SELECT $2, book_id, quantity, created_at FROM cart_items WHERE cart_id = $1
+		ON CONFLICT (cart_id, book_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`
+	_, err = tx.Exec(ctx, query, from, into)
+	if err != nil {
+		return 0, err
+	}
+	_, err = touchCart(ctx, tx, into)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM carts WHERE cart_id = $1`, from)
+	if err != nil {
+		return 0, err
+	}
+	return 1, tx.Commit(ctx)
+}
+
+// lockCart locks the cart cartId for the rest of tx. It returns false when
+// there is no such cart and ErrVersionConflict when version is set and the
+// cart is at another
+func lockCart(ctx context.Context, tx pgx.Tx, cartId string, version int) (bool, error) {
+	var current int
+	err := tx.QueryRow(ctx, `SELECT version FROM carts WHERE cart_id = $1 FOR UPDATE`, cartId).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if version > 0 && version != current {
+		return false, storage.ErrVersionConflict
+	}
+	return true, nil
+}
+
+// touchCart bumps the version of a cart whose items change, returning 0 when
+// there is no such cart
+func touchCart(ctx context.Context, tx pgx.Tx, cartId string) (int64, error) {
+	result, err := tx.Exec(ctx, `UPDATE carts SET updated_at = now(), version = version + 1 WHERE cart_id = $1`, cartId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func NewCartRepo(db *pgxpool.Pool) *CartRepo {
+	return &CartRepo{
+		db: db,
+	}
+}
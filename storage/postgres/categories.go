@@ -3,22 +3,34 @@ package postgres
 import (
 	"app/api/models"
 	"app/pkg/helper"
+	"app/storage"
 	"context"
 	"database/sql"
-	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
 )
 
+// categoryColumns maps the fields a list may be filtered and sorted by to columns
+var categoryColumns = map[string]string{
+	"id":         "id",
+	"parent_id":  "parent_id",
+	"name":       "name",
+	"type":       "type",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type CategoryRepo struct {
 	db *pgxpool.Pool
 }
 
 func (s CategoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
 	var id = uuid.New().String()
-	query := `INSERT INTO categories(id, name, type, picture) VALUES ($1, $2, $3, $4)`
+	query := `INSERT INTO categories(id, parent_id, name, type, picture) VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := s.db.Exec(ctx, query, id, req.Name, req.Type, req.Picture)
+	_, err := s.db.Exec(ctx, query, id, helper.NewNullString(req.ParentId), req.Name, req.Type, req.Picture)
 
 	if err != nil {
 		return "", err
@@ -30,44 +42,108 @@ func (s CategoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (i
 	var params map[string]interface{}
 	query := `
 		UPDATE categories 
-		SET name = :name, 
+		SET parent_id = :parent_id,
+		    name = :name,
 		    type = :type,
 		    picture = :picture,
-		    updated_at = now()
+		    updated_at = now(),
+		    version = version + 1
 		WHERE id = :id`
 
 	params = map[string]interface{}{
-		"id":      req.Id,
-		"name":    req.Name,
-		"type":    req.Type,
-		"picture": req.Picture,
+		"id":        req.Id,
+		"parent_id": helper.NewNullString(req.ParentId),
+		"name":      req.Name,
+		"type":      req.Type,
+		"picture":   req.Picture,
 	}
 
+	query = whereVersion(query, params, req.Version)
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	result, err := s.db.Exec(ctx, query, args...)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockTree(ctx, tx, req.Id, req.ParentId)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
 	if err != nil {
 		return 0, err
 	}
 
-	return result.RowsAffected(), nil
+	return checkVersion(ctx, s.db, "categories", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s CategoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	var p = newPatch()
+	if req.ParentId != nil {
+		p.add("parent_id", helper.NewNullString(*req.ParentId))
+	}
+	if req.Name != nil {
+		p.add("name", *req.Name)
+	}
+	if req.Type != nil {
+		p.add("type", *req.Type)
+	}
+	if req.Picture != nil {
+		p.add("picture", *req.Picture)
+	}
+
+	params := map[string]interface{}{"id": req.Id}
+	query, args := p.query("categories", whereVersion("id = :id AND is_deleted = FALSE", params, req.Version), params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if req.ParentId != nil {
+		err = lockTree(ctx, tx, req.Id, *req.ParentId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "categories", "id", req.Id, req.Version, result.RowsAffected())
 }
 
 func (s CategoryRepo) GetById(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
 	var (
-		id      sql.NullString
-		name    sql.NullString
-		Type    sql.NullString
-		picture sql.NullString
+		id       sql.NullString
+		parentId sql.NullString
+		name     sql.NullString
+		Type     sql.NullString
+		picture  sql.NullString
+		version  int
 	)
 
-	query := `SELECT id, name, type, picture FROM categories WHERE id = $1 AND is_deleted = FALSE`
+	query := `SELECT id, parent_id, name, type, picture, version FROM categories WHERE id = $1 AND is_deleted = FALSE`
 
 	err := s.db.QueryRow(ctx, query, req.Id).Scan(
 		&id,
+		&parentId,
 		&name,
 		&Type,
 		&picture,
+		&version,
 	)
 
 	if err != nil {
@@ -75,56 +151,63 @@ func (s CategoryRepo) GetById(ctx context.Context, req *models.CategoryPrimaryKe
 	}
 
 	return &models.Category{
-		Id:      id.String,
-		Name:    name.String,
-		Type:    Type.String,
-		Picture: picture.String,
+		Id:       id.String,
+		ParentId: parentId.String,
+		Name:     name.String,
+		Type:     Type.String,
+		Picture:  picture.String,
+		Version:  version,
 	}, nil
 }
 
 func (s CategoryRepo) GetList(ctx context.Context, req *models.CategoryGetListRequest) (*models.CategoryGetListResponse, error) {
 	var (
-		resp   = &models.CategoryGetListResponse{}
-		where  = " WHERE is_deleted = FALSE "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		//order  = " ORDER BY created_at DESC "
+		resp  = &models.CategoryGetListResponse{}
+		where = " WHERE is_deleted = FALSE "
+		keys  []models.Cursor
 	)
-	query := `SELECT COUNT(*) OVER(), id, name, type, picture FROM categories`
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	query := `SELECT id, parent_id, name, type, picture, version, created_at FROM categories`
+	var args []interface{}
+	where, args, err := listWhere(where, req.Filters, categoryColumns, args)
+	if err != nil {
+		return nil, err
 	}
-
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "categories", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, categoryColumns, "id")
+	if err != nil {
+		return nil, err
 	}
 
-	//if req.Search != "" {
-	//	where += ` AND title ILIKE '%' || '` + req.Search + `' || '%'`
-	//}
-
-	//query += where + order + offset + limit
-	query += where + offset + limit
+	query += where + page
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	for rows.Next() {
 		var (
-			count   int
-			id      sql.NullString
-			name    sql.NullString
-			Type    sql.NullString
-			picture sql.NullString
+			id        sql.NullString
+			parentId  sql.NullString
+			name      sql.NullString
+			Type      sql.NullString
+			picture   sql.NullString
+			version   int
+			createdAt sql.NullTime
 		)
 		err := rows.Scan(
-			&count,
 			&id,
+			&parentId,
 			&name,
 			&Type,
 			&picture,
+			&version,
+			&createdAt,
 		)
 		if err != nil {
 			return nil, err
@@ -132,22 +215,146 @@ func (s CategoryRepo) GetList(ctx context.Context, req *models.CategoryGetListRe
 		resp.Categories = append(
 			resp.Categories,
 			&models.Category{
-				Id:      id.String,
-				Name:    name.String,
-				Type:    Type.String,
-				Picture: picture.String,
+				Id:       id.String,
+				ParentId: parentId.String,
+				Name:     name.String,
+				Type:     Type.String,
+				Picture:  picture.String,
+				Version:  version,
 			})
-		resp.Count = count
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: id.String})
 	}
 
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Categories = resp.Categories[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Categories)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
 	return resp, nil
 }
 
+// GetTree returns the live categories under req.RootId, the root included, or
+// all of them when RootId is empty
+func (s CategoryRepo) GetTree(ctx context.Context, req *models.CategoryTreeRequest) ([]*models.Category, error) {
+	var (
+		root = "parent_id IS NULL"
+		args []interface{}
+	)
+	if req.RootId != "" {
+		root = "id = $1"
+		args = append(args, req.RootId)
+	}
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, parent_id, name, type, picture, version FROM categories
+			WHERE is_deleted = FALSE AND ` + root + `
+			UNION
+			SELECT c.id, c.parent_id, c.name, c.type, c.picture, c.version FROM categories c
+			JOIN tree t ON c.parent_id = t.id
+			WHERE c.is_deleted = FALSE
+		)
+		SELECT id, parent_id, name, type, picture, version FROM tree ORDER BY name, id`
+
+	return s.scanCategories(ctx, query, args...)
+}
+
+// GetAncestors returns the path from the top-level category down to req.Id
+func (s CategoryRepo) GetAncestors(ctx context.Context, req *models.CategoryPrimaryKey) ([]*models.Category, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, name, type, picture, version, 0 AS depth FROM categories
+			WHERE id = $1 AND is_deleted = FALSE
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.type, c.picture, c.version, a.depth + 1 FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth < 100
+		)
+		SELECT id, parent_id, name, type, picture, version FROM ancestors ORDER BY depth DESC`
+
+	return s.scanCategories(ctx, query, req.Id)
+}
+
+func (s CategoryRepo) scanCategories(ctx context.Context, query string, args ...interface{}) ([]*models.Category, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		var (
+			id       sql.NullString
+			parentId sql.NullString
+			name     sql.NullString
+			Type     sql.NullString
+			picture  sql.NullString
+			version  int
+		)
+		err := rows.Scan(
+			&id,
+			&parentId,
+			&name,
+			&Type,
+			&picture,
+			&version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, &models.Category{
+			Id:       id.String,
+			ParentId: parentId.String,
+			Name:     name.String,
+			Type:     Type.String,
+			Picture:  picture.String,
+			Version:  version,
+		})
+	}
+	return categories, rows.Err()
+}
+
 func (s CategoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
-	_, err := s.db.Exec(ctx, "UPDATE categories SET is_deleted = true, updated_at = now() WHERE id = $1", req.Id)
+	params := map[string]interface{}{"id": req.Id}
+	query, args := helper.ReplaceQueryParams(`UPDATE categories SET is_deleted = true, updated_at = now(), version = version + 1 WHERE `+whereVersion("id = :id", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkVersion(ctx, s.db, "categories", "id", req.Id, req.Version, result.RowsAffected())
 	return err
 }
 
+// lockTree serialises category moves for the rest of tx and rejects moving
+// id under parentId when id is parentId or one of its ancestors
+func lockTree(ctx context.Context, tx pgx.Tx, id, parentId string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('categories.parent_id'))`)
+	if err != nil || parentId == "" {
+		return err
+	}
+
+	var cycle bool
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`
+	err = tx.QueryRow(ctx, query, parentId, id).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return storage.ErrCategoryCycle
+	}
+	return nil
+}
+
 func NewCategoryRepo(db *pgxpool.Pool) *CategoryRepo {
 	return &CategoryRepo{
 		db: db,
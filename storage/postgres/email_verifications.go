@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"app/api/models"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type EmailVerificationRepo struct {
+	db *pgxpool.Pool
+}
+
+// Create stores a new verification token, superseding any token still pending for the user
+func (s EmailVerificationRepo) Create(ctx context.Context, req *models.CreateEmailVerification) (string, error) {
+	var (
+		id  = uuid.New().String()
+		now = time.Now().UTC()
+	)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE email_verifications SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, now, req.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO email_verifications(id, user_id, email, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, query, id, req.UserId, req.Email, req.TokenHash, req.ExpiresAt.UTC(), now)
+	if err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+func (s EmailVerificationRepo) GetPending(ctx context.Context, req *models.EmailVerificationPrimaryKey) (*models.EmailVerification, error) {
+	var (
+		id        sql.NullString
+		userId    sql.NullString
+		email     sql.NullString
+		tokenHash sql.NullString
+		expiresAt time.Time
+		createdAt time.Time
+		key       = req.Id
+	)
+
+	query := `SELECT id, user_id, email, token_hash, expires_at, created_at FROM email_verifications WHERE id = $1 AND used_at IS NULL AND expires_at > $2`
+	if req.Id == "" {
+		query = `SELECT id, user_id, email, token_hash, expires_at, created_at FROM email_verifications WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2`
+		key = req.TokenHash
+	}
+	err := s.db.QueryRow(ctx, query, key, time.Now().UTC()).Scan(
+		&id,
+		&userId,
+		&email,
+		&tokenHash,
+		&expiresAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EmailVerification{
+		Id:        id.String,
+		UserId:    userId.String,
+		Email:     email.String,
+		TokenHash: tokenHash.String,
+		ExpiresAt: expiresAt,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (s EmailVerificationRepo) CountSince(ctx context.Context, req *models.EmailVerificationCountRequest) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM email_verifications WHERE user_id = $1 AND created_at >= $2`
+	err := s.db.QueryRow(ctx, query, req.UserId, req.Since.UTC()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Consume marks the token used; zero rows affected means it was already used
+func (s EmailVerificationRepo) Consume(ctx context.Context, req *models.EmailVerificationPrimaryKey) (int64, error) {
+	result, err := s.db.Exec(ctx, "UPDATE email_verifications SET used_at = $1 WHERE id = $2 AND used_at IS NULL", time.Now().UTC(), req.Id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func NewEmailVerificationRepo(db *pgxpool.Pool) *EmailVerificationRepo {
+	return &EmailVerificationRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
+)
+
+// movementColumns maps the fields a list may be filtered and sorted by to columns
+var movementColumns = map[string]string{
+	"reason":     "reason",
+	"user_id":    "user_id",
+	"order_id":   "order_id",
+	"created_at": "created_at",
+}
+
+type InventoryRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s InventoryRepo) Adjust(ctx context.Context, req *models.AdjustInventory) (*models.InventoryMovement, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	movement := &models.InventoryMovement{
+		BookId: req.BookId,
+		Delta:  req.Delta,
+		Reason: req.Reason,
+		Note:   req.Note,
+		UserId: req.UserId,
+	}
+	ok, err := moveStock(ctx, tx, movement)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return movement, tx.Commit(ctx)
+}
+
+func (s InventoryRepo) GetMovements(ctx context.Context, req *models.InventoryMovementGetListRequest) (*models.InventoryMovementGetListResponse, error) {
+	var (
+		resp  = &models.InventoryMovementGetListResponse{}
+		where = " WHERE book_id = $1 "
+		args  = []interface{}{req.BookId}
+		keys  []models.Cursor
+	)
+	query := `SELECT id, book_id, delta, reserved_delta, reason, note, user_id, order_id, stock_quantity, reserved_quantity, created_at FROM inventory_movements`
+	where, args, err := listWhere(where, req.Filters, movementColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "inventory_movements", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, movementColumns, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	query += where + page
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			movement  models.InventoryMovement
+			userId    sql.NullString
+			orderId   sql.NullString
+			createdAt sql.NullTime
+		)
+		err := rows.Scan(
+			&movement.Id,
+			&movement.BookId,
+			&movement.Delta,
+			&movement.ReservedDelta,
+			&movement.Reason,
+			&movement.Note,
+			&userId,
+			&orderId,
+			&movement.StockQuantity,
+			&movement.ReservedQuantity,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movement.UserId, movement.OrderId, movement.CreatedAt = userId.String, orderId.String, createdAt.Time
+		resp.Movements = append(resp.Movements, &movement)
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: movement.Id})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Movements = resp.Movements[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Movements)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+// moveStock applies m.Delta to the stock and m.ReservedDelta to the reserved
+// quantity of a live book and records m in the ledger, filling in its id,
+// resulting quantities and time. It reports false when the book does not exist
+func moveStock(ctx context.Context, tx pgx.Tx, m *models.InventoryMovement) (bool, error) {
+	query := `
+		UPDATE books
+		SET stock_quantity = stock_quantity + $2,
+		    reserved_quantity = reserved_quantity + $3,
+		    updated_at = now(),
+		    version = version + 1
+		WHERE id = $1 AND is_deleted = FALSE
+		  AND reserved_quantity + $3 >= 0
+		  AND stock_quantity + $2 >= reserved_quantity + $3
+		RETURNING stock_quantity, reserved_quantity`
+	err := tx.QueryRow(ctx, query, m.BookId, m.Delta, m.ReservedDelta).Scan(&m.StockQuantity, &m.ReservedQuantity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM books WHERE id = $1 AND is_deleted = FALSE)`, m.BookId).Scan(&exists)
+		if err != nil || !exists {
+			return false, err
+		}
+		return false, storage.ErrInsufficientStock
+	}
+	if err != nil {
+		return false, err
+	}
+
+	m.Id = uuid.New().String()
+	query = `
+		INSERT INTO inventory_movements(id, book_id, delta, reserved_delta, reason, note, user_id, order_id, stock_quantity, reserved_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`
+	err = tx.QueryRow(ctx, query, m.Id, m.BookId, m.Delta, m.ReservedDelta, m.Reason, m.Note, helper.NewNullString(m.UserId), helper.NewNullString(m.OrderId), m.StockQuantity, m.ReservedQuantity).Scan(&m.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func NewInventoryRepo(db *pgxpool.Pool) *InventoryRepo {
+	return &InventoryRepo{
+		db: db,
+	}
+}
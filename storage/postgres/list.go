@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"app/api/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+var comparisons = map[string]string{
+	models.FilterEq:  "=",
+	models.FilterNe:  "<>",
+	models.FilterGt:  ">",
+	models.FilterGte: ">=",
+	models.FilterLt:  "<",
+	models.FilterLte: "<=",
+}
+
+// listWhere appends filters to where as conditions over columns, numbering
+// the placeholders after the ones already in args. A column containing %s is
+// a condition over a related table, such as "id IN (SELECT ... WHERE x %s)",
+// which matches when any related value does; ne then means none does
+func listWhere(where string, filters []models.Filter, columns map[string]string, args []interface{}) (string, []interface{}, error) {
+	for _, filter := range filters {
+		column, ok := columns[filter.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("cannot filter by %q", filter.Field)
+		}
+
+		var (
+			op     = filter.Op
+			negate = false
+			cond   string
+			err    error
+		)
+		if strings.Contains(column, "%s") && op == models.FilterNe {
+			op, negate = models.FilterEq, true
+		}
+		cond, args, err = listCondition(op, filter.Value, args)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if strings.Contains(column, "%s") {
+			cond = fmt.Sprintf(column, cond)
+		} else {
+			cond = column + " " + cond
+		}
+		if negate {
+			cond = "NOT " + cond
+		}
+		where += " AND " + cond
+	}
+	return where, args, nil
+}
+
+// listCondition returns the right-hand side of a filter condition, such as
+// "= $3", adding its values to args
+func listCondition(op string, value interface{}, args []interface{}) (string, []interface{}, error) {
+	switch op {
+	case models.FilterLike:
+		args = append(args, likeEscaper.Replace(fmt.Sprint(value)))
+		return fmt.Sprintf("ILIKE '%%' || $%d || '%%'", len(args)), args, nil
+	case models.FilterIn:
+		values, _ := value.([]interface{})
+		if len(values) == 0 {
+			return "IN (NULL)", args, nil
+		}
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		return "IN (" + strings.Join(placeholders, ", ") + ")", args, nil
+	}
+
+	comparison, ok := comparisons[op]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown filter operator %q", op)
+	}
+	args = append(args, value)
+	return fmt.Sprintf("%s $%d", comparison, len(args)), args, nil
+}
+
+// listOrder returns the ORDER BY clause for sorts, newest first when there
+// are none. idColumn breaks ties so pages do not overlap
+func listOrder(sorts []models.Sort, columns map[string]string, idColumn string) (string, error) {
+	if len(sorts) == 0 {
+		return " ORDER BY created_at DESC, " + idColumn + " DESC", nil
+	}
+
+	var order []string
+	for _, sort := range sorts {
+		column, ok := columns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("cannot sort by %q", sort.Field)
+		}
+		if sort.Desc {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	return " ORDER BY " + strings.Join(order, ", ") + ", " + idColumn, nil
+}
+
+// listCount counts the rows of table matching where
+func listCount(ctx context.Context, db *pgxpool.Pool, table, where string, args []interface{}) (*int, error) {
+	var count int
+	err := db.QueryRow(ctx, `SELECT COUNT(*) FROM `+table+where, args...).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// listPage returns where and the ORDER BY, OFFSET and LIMIT of a list query.
+// It asks for one row more than limit so listCursors can tell whether
+// another page follows. A cursor seeks past the cursor row instead of
+// skipping offset rows, oldest first for a Before cursor
+func listPage(where string, args []interface{}, offset, limit int, cursor *models.Cursor, sorts []models.Sort, columns map[string]string, idColumn string) (string, string, []interface{}, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if cursor == nil {
+		order, err := listOrder(sorts, columns, idColumn)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return where, order + fmt.Sprintf(" OFFSET %d LIMIT %d", offset, limit+1), args, nil
+	}
+	if len(sorts) > 0 {
+		return "", "", nil, errors.New("cursor pages are only available in the default order")
+	}
+
+	seek, order := "<", " ORDER BY created_at DESC, "+idColumn+" DESC"
+	if cursor.Before {
+		seek, order = ">", " ORDER BY created_at, "+idColumn
+	}
+	args = append(args, cursor.CreatedAt, cursor.Id)
+	where += fmt.Sprintf(" AND (created_at, %s) %s ($%d::timestamp, $%d::uuid)", idColumn, seek, len(args)-1, len(args))
+	return where, order + fmt.Sprintf(" LIMIT %d", limit+1), args, nil
+}
+
+// listCursors takes the created_at and id of the rows fetched by listPage, in
+// fetch order, and returns how many of them belong to the page along with
+// the cursors of the pages around it. Rows fetched for a Before cursor are
+// oldest first and have to be reversed once trimmed. Custom sorts get no
+// cursors
+func listCursors(keys []models.Cursor, offset, limit int, cursor *models.Cursor, sorted bool) (n int, next, prev string) {
+	if limit <= 0 {
+		limit = 10
+	}
+	more := len(keys) > limit
+	if more {
+		keys = keys[:limit]
+	}
+	n = len(keys)
+	if n == 0 || sorted {
+		return n, "", ""
+	}
+
+	first, last := keys[0], keys[n-1]
+	switch {
+	case cursor == nil:
+		if more {
+			next = last.Encode()
+		}
+		if offset > 0 {
+			first.Before = true
+			prev = first.Encode()
+		}
+	case cursor.Before:
+		next = first.Encode()
+		if more {
+			last.Before = true
+			prev = last.Encode()
+		}
+	default:
+		if more {
+			next = last.Encode()
+		}
+		first.Before = true
+		prev = first.Encode()
+	}
+	return n, next, prev
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type LoginAttemptRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s LoginAttemptRepo) Create(ctx context.Context, req *models.CreateLoginAttempt) (string, error) {
+	var id = uuid.New().String()
+
+	query := `INSERT INTO login_attempts(id, username, user_id, ip, user_agent, success, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.db.Exec(ctx, query, id, req.Username, helper.NewNullString(req.UserId), req.Ip, req.UserAgent, req.Success, req.Reason, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Update resolves a pending attempt
+func (s LoginAttemptRepo) Update(ctx context.Context, req *models.UpdateLoginAttempt) (int64, error) {
+	query := `UPDATE login_attempts SET user_id = $2, success = $3, reason = $4 WHERE id = $1`
+	result, err := s.db.Exec(ctx, query, req.Id, helper.NewNullString(req.UserId), req.Success, req.Reason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// GetFailures counts failures for the username since its last success, or for the ip
+func (s LoginAttemptRepo) GetFailures(ctx context.Context, req *models.LoginFailureRequest) (*models.LoginFailures, error) {
+	var (
+		count int
+		last  sql.NullTime
+		key   = req.Username
+	)
+
+	query := `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts a
+		WHERE username = $1 AND success = FALSE AND reason <> $2 AND created_at >= $3 AND id::text <> $4
+		  AND NOT EXISTS (SELECT 1 FROM login_attempts s WHERE s.username = $1 AND s.success = TRUE AND s.created_at >= a.created_at)`
+	if req.Username == "" {
+		query = `SELECT COUNT(*), MAX(created_at) FROM login_attempts WHERE ip = $1 AND success = FALSE AND reason <> $2 AND created_at >= $3 AND id::text <> $4`
+		key = req.Ip
+	}
+	err := s.db.QueryRow(ctx, query, key, models.LoginThrottled, req.Since.UTC(), req.ExceptId).Scan(&count, &last)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginFailures{
+		Count: count,
+		Last:  last.Time,
+	}, nil
+}
+
+func (s LoginAttemptRepo) GetList(ctx context.Context, req *models.LoginAttemptGetListRequest) (*models.LoginAttemptGetListResponse, error) {
+	var (
+		resp   = &models.LoginAttemptGetListResponse{}
+		where  = " WHERE TRUE "
+		order  = " ORDER BY created_at DESC "
+		offset = " OFFSET 0"
+		limit  = " LIMIT 10"
+		args   []interface{}
+	)
+	query := `SELECT COUNT(*) OVER(), id, username, user_id, ip, user_agent, success, reason, created_at FROM login_attempts`
+	if req.Offset > 0 {
+		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	}
+
+	if req.Limit > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	}
+
+	if req.Username != "" {
+		args = append(args, req.Username)
+		where += fmt.Sprintf(" AND username = $%d", len(args))
+	}
+
+	if req.Ip != "" {
+		args = append(args, req.Ip)
+		where += fmt.Sprintf(" AND ip = $%d", len(args))
+	}
+
+	query += where + order + offset + limit
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			count     int
+			id        sql.NullString
+			username  sql.NullString
+			userId    sql.NullString
+			ip        sql.NullString
+			userAgent sql.NullString
+			success   bool
+			reason    sql.NullString
+			createdAt time.Time
+		)
+		err := rows.Scan(
+			&count,
+			&id,
+			&username,
+			&userId,
+			&ip,
+			&userAgent,
+			&success,
+			&reason,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		resp.LoginAttempts = append(resp.LoginAttempts, &models.LoginAttempt{
+			Id:        id.String,
+			Username:  username.String,
+			UserId:    userId.String,
+			Ip:        ip.String,
+			UserAgent: userAgent.String,
+			Success:   success,
+			Reason:    reason.String,
+			CreatedAt: createdAt,
+		})
+		resp.Count = count
+	}
+
+	return resp, nil
+}
+
+func NewLoginAttemptRepo(db *pgxpool.Pool) *LoginAttemptRepo {
+	return &LoginAttemptRepo{
+		db: db,
+	}
+}
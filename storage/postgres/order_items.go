@@ -3,67 +3,181 @@ package postgres
 import (
 	"app/api/models"
 	"app/pkg/helper"
+	"app/storage"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
 )
 
+// orderItemColumns maps the fields a list may be filtered and sorted by to columns
+var orderItemColumns = map[string]string{
+	"item_id":    "item_id",
+	"order_id":   "order_id",
+	"book_id":    "book_id",
+	"quantity":   "quantity",
+	"line_total": "line_total",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type OrderItemRepo struct {
 	db *pgxpool.Pool
 }
 
 func (s OrderItemRepo) Create(ctx context.Context, req *models.CreateOrderItem) (string, error) {
 	var id = uuid.New().String()
-	query := `INSERT INTO order_items(item_id, order_id, book_id) VALUES ($1, $2, $3)`
+	query := `INSERT INTO order_items(item_id, order_id, book_id, quantity, unit_price, currency) VALUES ($1, $2, $3, $4, $5, $6)`
 
-	_, err := s.db.Exec(ctx, query, id, req.OrderId, req.BookId)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
 
+	err = lockPendingOrders(ctx, tx, req.OrderId)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx, query, id, req.OrderId, req.BookId, req.Quantity, req.UnitPrice, req.Currency)
+	if err != nil {
+		return "", err
+	}
+	err = holdStock(ctx, tx, nil, &models.OrderItem{OrderId: req.OrderId, BookId: req.BookId, Quantity: req.Quantity}, req.UserId)
 	if err != nil {
 		return "", err
 	}
-	return id, nil
+	return id, tx.Commit(ctx)
 }
 
 func (s OrderItemRepo) Update(ctx context.Context, req *models.UpdateOrderItem) (int64, error) {
-	var params map[string]interface{}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockItem(ctx, tx, req.ItemId, req.Version)
+	if err != nil || item == nil {
+		return 0, err
+	}
+	err = lockPendingOrders(ctx, tx, item.OrderId, req.OrderId)
+	if err != nil {
+		return 0, err
+	}
+
 	query := `
 		UPDATE order_items 
 		SET order_id = :order_id,
 		    book_id = :book_id,
-		    updated_at = now()
+		    quantity = :quantity,
+		    unit_price = :unit_price,
+		    currency = :currency,
+		    updated_at = now(),
+		    version = version + 1
 		WHERE item_id = :item_id`
 
-	params = map[string]interface{}{
-		"book_id":  req.BookId,
-		"order_id": req.OrderId,
-		"item_id":  req.ItemId,
+	params := map[string]interface{}{
+		"book_id":    req.BookId,
+		"order_id":   req.OrderId,
+		"item_id":    req.ItemId,
+		"quantity":   req.Quantity,
+		"unit_price": req.UnitPrice,
+		"currency":   req.Currency,
 	}
-
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	result, err := s.db.Exec(ctx, query, args...)
+	result, err := tx.Exec(ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
+	err = holdStock(ctx, tx, item, &models.OrderItem{OrderId: req.OrderId, BookId: req.BookId, Quantity: req.Quantity}, req.UserId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
+func (s OrderItemRepo) Patch(ctx context.Context, req *models.PatchOrderItem) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
 
-	return result.RowsAffected(), nil
+	item, err := lockItem(ctx, tx, req.ItemId, req.Version)
+	if err != nil || item == nil {
+		return 0, err
+	}
+
+	var (
+		p      = newPatch()
+		target = *item
+	)
+	if req.OrderId != nil {
+		p.add("order_id", *req.OrderId)
+		target.OrderId = *req.OrderId
+	}
+	if req.BookId != nil {
+		p.add("book_id", *req.BookId)
+		target.BookId = *req.BookId
+	}
+	if req.Quantity != nil {
+		p.add("quantity", *req.Quantity)
+		target.Quantity = *req.Quantity
+	}
+	if req.UnitPrice != nil {
+		p.add("unit_price", *req.UnitPrice)
+	}
+	if req.Currency != nil {
+		p.add("currency", *req.Currency)
+	}
+	err = lockPendingOrders(ctx, tx, item.OrderId, target.OrderId)
+	if err != nil {
+		return 0, err
+	}
+
+	params := map[string]interface{}{"item_id": req.ItemId}
+	query, args := p.query("order_items", "item_id = :item_id", params)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = holdStock(ctx, tx, item, &target, req.UserId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
 }
 
 func (s OrderItemRepo) GetById(ctx context.Context, req *models.OrderItemPrimaryKey) (*models.OrderItem, error) {
 	var (
-		itemId  sql.NullString
-		orderId sql.NullString
-		bookId  sql.NullString
+		itemId    sql.NullString
+		orderId   sql.NullString
+		bookId    sql.NullString
+		quantity  int
+		unitPrice int64
+		currency  sql.NullString
+		lineTotal int64
+		version   int
 	)
 
-	query := `SELECT item_id, order_id, book_id FROM order_items WHERE item_id = $1 AND is_deleted = 'False'`
+	query := `SELECT item_id, order_id, book_id, quantity, unit_price, currency, line_total, version FROM order_items WHERE item_id = $1 AND is_deleted = 'False'`
 
 	err := s.db.QueryRow(ctx, query, req.ItemId).Scan(
 		&itemId,
 		&orderId,
 		&bookId,
+		&quantity,
+		&unitPrice,
+		&currency,
+		&lineTotal,
+		&version,
 	)
 
 	if err != nil {
@@ -71,53 +185,74 @@ func (s OrderItemRepo) GetById(ctx context.Context, req *models.OrderItemPrimary
 	}
 
 	return &models.OrderItem{
-		ItemId:  itemId.String,
-		OrderId: orderId.String,
-		BookId:  bookId.String,
+		ItemId:    itemId.String,
+		OrderId:   orderId.String,
+		BookId:    bookId.String,
+		Quantity:  quantity,
+		UnitPrice: unitPrice,
+		Currency:  currency.String,
+		LineTotal: lineTotal,
+		Version:   version,
 	}, nil
 }
 
 func (s OrderItemRepo) GetList(ctx context.Context, req *models.OrderItemGetListRequest) (*models.OrderItemGetListResponse, error) {
 	var (
-		resp   = &models.OrderItemGetListResponse{}
-		where  = " WHERE is_deleted = False "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		//order  = " ORDER BY created_at DESC "
+		resp  = &models.OrderItemGetListResponse{}
+		where = " WHERE is_deleted = False "
+		keys  []models.Cursor
 	)
-	query := `SELECT COUNT(*) OVER(), item_id, order_id, book_id FROM order_items`
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	query := `SELECT item_id, order_id, book_id, quantity, unit_price, currency, line_total, version, created_at FROM order_items`
+	var args []interface{}
+	if req.UserId != "" {
+		where += " AND order_id IN (SELECT order_id FROM orders WHERE user_id = $1) "
+		args = append(args, req.UserId)
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	where, args, err := listWhere(where, req.Filters, orderItemColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "order_items", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, orderItemColumns, "item_id")
+	if err != nil {
+		return nil, err
 	}
 
-	//if req.Search != "" {
-	//	where += ` AND title ILIKE '%' || '` + req.Search + `' || '%'`
-	//}
-
-	//query += where + order + offset + limit
-	query += where + offset + limit
+	query += where + page
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	for rows.Next() {
 		var (
-			itemId  sql.NullString
-			orderId sql.NullString
-			bookId  sql.NullString
-			count   int
+			itemId    sql.NullString
+			orderId   sql.NullString
+			bookId    sql.NullString
+			quantity  int
+			unitPrice int64
+			currency  sql.NullString
+			lineTotal int64
+			version   int
+			createdAt sql.NullTime
 		)
 		err := rows.Scan(
-			&count,
 			&itemId,
 			&orderId,
 			&bookId,
+			&quantity,
+			&unitPrice,
+			&currency,
+			&lineTotal,
+			&version,
+			&createdAt,
 		)
 		if err != nil {
 			return nil, err
@@ -125,18 +260,106 @@ func (s OrderItemRepo) GetList(ctx context.Context, req *models.OrderItemGetList
 		resp.OrderItems = append(
 			resp.OrderItems,
 			&models.OrderItem{
-				ItemId:  itemId.String,
-				OrderId: orderId.String,
-				BookId:  bookId.String,
+				ItemId:    itemId.String,
+				OrderId:   orderId.String,
+				BookId:    bookId.String,
+				Quantity:  quantity,
+				UnitPrice: unitPrice,
+				Currency:  currency.String,
+				LineTotal: lineTotal,
+				Version:   version,
 			})
-		resp.Count = count
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: itemId.String})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.OrderItems = resp.OrderItems[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.OrderItems)
 	}
+	resp.NextCursor, resp.PrevCursor = next, prev
 	return resp, nil
 }
 
 func (s OrderItemRepo) Delete(ctx context.Context, req *models.OrderItemPrimaryKey) error {
-	_, err := s.db.Exec(ctx, "UPDATE order_items SET is_deleted = true, updated_at = now() WHERE item_id = $1", req.ItemId)
-	return err
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockItem(ctx, tx, req.ItemId, req.Version)
+	if err != nil || item == nil {
+		return err
+	}
+	err = lockPendingOrders(ctx, tx, item.OrderId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE order_items SET is_deleted = true, updated_at = now(), version = version + 1 WHERE item_id = $1`, req.ItemId)
+	if err != nil {
+		return err
+	}
+	err = holdStock(ctx, tx, item, nil, req.UserId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockItem locks the live item itemId for the rest of tx and returns it, or
+// nil when there is no such item
+func lockItem(ctx context.Context, tx pgx.Tx, itemId string, version int) (*models.OrderItem, error) {
+	var item models.OrderItem
+	query := `SELECT item_id, order_id, book_id, quantity, version FROM order_items WHERE item_id = $1 AND is_deleted = FALSE FOR UPDATE`
+	err := tx.QueryRow(ctx, query, itemId).Scan(&item.ItemId, &item.OrderId, &item.BookId, &item.Quantity, &item.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 && version != item.Version {
+		return nil, storage.ErrVersionConflict
+	}
+	return &item, nil
+}
+
+// lockPendingOrders locks the orders an item write touches, in id order like
+// Checkout locks books, so that no transition can run until tx ends. It
+// returns pgx.ErrNoRows for a missing order and ErrOrderNotPending unless
+// every order is pending
+func lockPendingOrders(ctx context.Context, tx pgx.Tx, orderIds ...string) error {
+	slices.Sort(orderIds)
+	orderIds = slices.Compact(orderIds)
+	for _, orderId := range orderIds {
+		var status string
+		err := tx.QueryRow(ctx, `SELECT status FROM orders WHERE order_id = $1 AND is_deleted = FALSE FOR UPDATE`, orderId).Scan(&status)
+		if err != nil {
+			return err
+		}
+		if status != models.OrderStatusPending {
+			return fmt.Errorf("%w: %s", storage.ErrOrderNotPending, orderId)
+		}
+	}
+	return nil
+}
+
+// holdStock reserves and releases stock so that the orders of from and to
+// hold what their items need once from becomes to
+func holdStock(ctx context.Context, tx pgx.Tx, from, to *models.OrderItem, userId string) error {
+	for _, movement := range models.ItemReservations(from, to, userId) {
+		ok, err := moveStock(ctx, tx, movement)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, movement.BookId)
+		}
+		// a deleted book has nothing left to release
+		if !ok && movement.ReservedDelta > 0 {
+			return fmt.Errorf("%w: %s", storage.ErrBookNotFound, movement.BookId)
+		}
+	}
+	return nil
 }
 
 func NewOrderItemRepo(db *pgxpool.Pool) *OrderItemRepo {
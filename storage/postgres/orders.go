@@ -3,131 +3,568 @@ package postgres
 import (
 	"app/api/models"
 	"app/pkg/helper"
+	"app/storage"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
 )
 
+// orderColumns maps the fields a list may be filtered and sorted by to columns
+var orderColumns = map[string]string{
+	"order_id":   "order_id",
+	"user_id":    "user_id",
+	"status":     "status",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// itemsSubtotal and itemsCurrency sum and price the live items of an order
+const (
+	itemsSubtotal = `(SELECT COALESCE(SUM(i.line_total), 0) FROM order_items i WHERE i.order_id = orders.order_id AND i.is_deleted = FALSE)`
+	itemsCurrency = `(SELECT COALESCE(MIN(i.currency), '') FROM order_items i WHERE i.order_id = orders.order_id AND i.is_deleted = FALSE)`
+)
+
+// orderTotals selects an order's discount and tax rate followed by its
+// subtotal and currency. These are read from its live items while it is
+// pending and frozen once it leaves pending
+const orderTotals = `discount, tax_rate,
+	COALESCE(orders.subtotal, ` + itemsSubtotal + `),
+	COALESCE(orders.currency, ` + itemsCurrency + `)`
+
 type OrderRepo struct {
 	db *pgxpool.Pool
 }
 
 func (s OrderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
 	var id = uuid.New().String()
-	query := `INSERT INTO orders(order_id, user_id) VALUES ($1, $2)`
+	query := `INSERT INTO orders(order_id, user_id, status, tax_rate) VALUES ($1, $2, 'pending', $3)`
 
-	_, err := s.db.Exec(ctx, query, id, req.UserId)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
 
+	_, err = tx.Exec(ctx, query, id, req.UserId, req.TaxRate)
+	if err != nil {
+		return "", err
+	}
+	err = recordStatus(ctx, tx, &models.OrderStatusChange{OrderId: id, To: models.OrderStatusPending, UserId: req.UserId})
 	if err != nil {
 		return "", err
 	}
-	return id, nil
+	return id, tx.Commit(ctx)
+}
+
+func (s OrderRepo) Checkout(ctx context.Context, req *models.Checkout) (string, error) {
+	var (
+		id  = uuid.New().String()
+		ids = make([]string, 0, len(req.Items))
+	)
+	for _, item := range req.Items {
+		ids = append(ids, item.BookId)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// a cart that changed since its items were read is left for the user to
+	// check out again
+	if req.CartId != "" {
+		ok, err := lockCart(ctx, tx, req.CartId, req.CartVersion)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", storage.ErrVersionConflict
+		}
+	}
+
+	// lock in id order so that concurrent checkouts of the same books queue
+	// up instead of deadlocking
+	rows, err := tx.Query(ctx, `
+		SELECT id, price, currency, stock_quantity - reserved_quantity FROM books
+		WHERE id = ANY($1::text[]::uuid[]) AND is_deleted = FALSE
+		ORDER BY id FOR UPDATE`, ids)
+	if err != nil {
+		return "", err
+	}
+	var books = make(map[string]*models.Book, len(ids))
+	for rows.Next() {
+		var (
+			book      models.Book
+			available int
+		)
+		err := rows.Scan(&book.Id, &book.Price, &book.Currency, &available)
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		book.StockQuantity = available
+		books[book.Id] = &book
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	err = checkoutBooks(req.Items, books)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO orders(order_id, user_id, status, tax_rate) VALUES ($1, $2, 'pending', $3)`, id, req.UserId, req.TaxRate)
+	if err != nil {
+		return "", err
+	}
+	err = recordStatus(ctx, tx, &models.OrderStatusChange{OrderId: id, To: models.OrderStatusPending, UserId: req.UserId})
+	if err != nil {
+		return "", err
+	}
+	for _, item := range req.Items {
+		book := books[item.BookId]
+		query := `INSERT INTO order_items(item_id, order_id, book_id, quantity, unit_price, currency) VALUES ($1, $2, $3, $4, $5, $6)`
+		_, err = tx.Exec(ctx, query, uuid.New().String(), id, item.BookId, item.Quantity, book.Price, book.Currency)
+		if err != nil {
+			return "", err
+		}
+		_, err = moveStock(ctx, tx, &models.InventoryMovement{
+			BookId:        item.BookId,
+			ReservedDelta: item.Quantity,
+			Reason:        models.InventoryReasonReserve,
+			UserId:        req.UserId,
+			OrderId:       id,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if req.CartId != "" {
+		_, err = tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, req.CartId)
+		if err != nil {
+			return "", err
+		}
+		_, err = touchCart(ctx, tx, req.CartId)
+		if err != nil {
+			return "", err
+		}
+	}
+	return id, tx.Commit(ctx)
+}
+
+// checkoutBooks checks items against the locked books, whose StockQuantity
+// holds the quantity available for sale
+func checkoutBooks(items []*models.CheckoutItem, books map[string]*models.Book) error {
+	var currency string
+	for _, item := range items {
+		book, ok := books[item.BookId]
+		if !ok {
+			return fmt.Errorf("%w: %s", storage.ErrBookNotFound, item.BookId)
+		}
+		if currency != "" && book.Currency != currency {
+			return fmt.Errorf("%w: %s", storage.ErrCurrencyMismatch, item.BookId)
+		}
+		currency = book.Currency
+		if book.StockQuantity < item.Quantity {
+			return fmt.Errorf("%w: %s", storage.ErrInsufficientStock, item.BookId)
+		}
+	}
+	return nil
+}
+
+func (s OrderRepo) Transition(ctx context.Context, req *models.OrderTransition) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockOrder(ctx, tx, req.OrderId, req.Version)
+	if err != nil || status == "" {
+		return 0, err
+	}
+	err = transition(ctx, tx, req, status)
+	if err != nil {
+		return 0, err
+	}
+	return 1, tx.Commit(ctx)
+}
+
+// lockOrder locks the live order orderId for the rest of tx and returns its
+// status, or "" when there is no such order
+func lockOrder(ctx context.Context, tx pgx.Tx, orderId string, version int) (string, error) {
+	var (
+		status  string
+		current int
+	)
+	query := `SELECT status, version FROM orders WHERE order_id = $1 AND is_deleted = FALSE FOR UPDATE`
+	err := tx.QueryRow(ctx, query, orderId).Scan(&status, &current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if version > 0 && version != current {
+		return "", storage.ErrVersionConflict
+	}
+	return status, nil
+}
+
+// transition moves an order locked by tx from status to req.Status, recording
+// the change and settling its stock
+func transition(ctx context.Context, tx pgx.Tx, req *models.OrderTransition, status string) error {
+	if !models.CanTransition(status, req.Status) {
+		return fmt.Errorf("%w: %s to %s", storage.ErrInvalidTransition, status, req.Status)
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE orders SET status = $2, updated_at = now(), version = version + 1 WHERE order_id = $1`, req.OrderId, req.Status)
+	if err != nil {
+		return err
+	}
+	// the items of an order that has left pending cannot change, and
+	// neither can what it costs
+	if status == models.OrderStatusPending {
+		_, err = tx.Exec(ctx, `UPDATE orders SET subtotal = `+itemsSubtotal+`, currency = `+itemsCurrency+` WHERE order_id = $1`, req.OrderId)
+		if err != nil {
+			return err
+		}
+	}
+	err = recordStatus(ctx, tx, &models.OrderStatusChange{OrderId: req.OrderId, From: status, To: req.Status, Note: req.Note, UserId: req.UserId})
+	if err != nil {
+		return err
+	}
+	return settleStock(ctx, tx, req)
+}
+
+// settleStock sells the items of an order that ships and releases whatever
+// stock the order still holds reserved once it ships, is cancelled or is
+// refunded
+func settleStock(ctx context.Context, tx pgx.Tx, req *models.OrderTransition) error {
+	if req.Status != models.OrderStatusShipped && req.Status != models.OrderStatusCancelled && req.Status != models.OrderStatusRefunded {
+		return nil
+	}
+
+	query := `
+		SELECT book_id, SUM(sold)::int, SUM(held)::int FROM (
+			SELECT book_id, quantity AS sold, 0 AS held FROM order_items WHERE order_id = $1 AND is_deleted = FALSE
+			UNION ALL
+			SELECT book_id, 0, reserved_delta FROM inventory_movements WHERE order_id = $1
+		) stock
+		GROUP BY book_id
+		ORDER BY book_id`
+	rows, err := tx.Query(ctx, query, req.OrderId)
+	if err != nil {
+		return err
+	}
+	var movements []*models.InventoryMovement
+	for rows.Next() {
+		var (
+			bookId     string
+			sold, held int
+		)
+		err := rows.Scan(&bookId, &sold, &held)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		movement := &models.InventoryMovement{
+			BookId:        bookId,
+			ReservedDelta: -held,
+			Reason:        models.InventoryReasonRelease,
+			UserId:        req.UserId,
+			OrderId:       req.OrderId,
+		}
+		if req.Status == models.OrderStatusShipped {
+			movement.Delta, movement.Reason = -sold, models.InventoryReasonSale
+		}
+		if movement.Delta != 0 || movement.ReservedDelta != 0 {
+			movements = append(movements, movement)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movement := range movements {
+		_, err := moveStock(ctx, tx, movement)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, movement.BookId)
+		}
+	}
+	return nil
+}
+
+func (s OrderRepo) GetHistory(ctx context.Context, req *models.OrderPrimaryKey) ([]*models.OrderStatusChange, error) {
+	query := `
+		SELECT id, order_id, from_status, to_status, note, user_id, created_at FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id`
+	rows, err := s.db.Query(ctx, query, req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history = []*models.OrderStatusChange{}
+	for rows.Next() {
+		var (
+			change    models.OrderStatusChange
+			from      sql.NullString
+			userId    sql.NullString
+			createdAt sql.NullTime
+		)
+		err := rows.Scan(&change.Id, &change.OrderId, &from, &change.To, &change.Note, &userId, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		change.From, change.UserId, change.CreatedAt = from.String, userId.String, createdAt.Time
+		history = append(history, &change)
+	}
+	return history, rows.Err()
+}
+
+// recordStatus appends change to its order's status history
+func recordStatus(ctx context.Context, tx pgx.Tx, change *models.OrderStatusChange) error {
+	query := `INSERT INTO order_status_history(id, order_id, from_status, to_status, note, user_id) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := tx.Exec(ctx, query, uuid.New().String(), change.OrderId, helper.NewNullString(change.From), change.To, change.Note, helper.NewNullString(change.UserId))
+	return err
 }
 
 func (s OrderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
 	var params map[string]interface{}
 	query := `
 		UPDATE orders 
-		SET user_id = :user_id
+		SET user_id = :user_id,
+		    discount = :discount,
+		    updated_at = now(),
+		    version = version + 1
 		WHERE order_id = :order_id`
 
 	params = map[string]interface{}{
 		"user_id":  req.UserId,
+		"discount": req.Discount,
 		"order_id": req.OrderId,
 	}
 
+	query = whereVersion(query, params, req.Version)
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	result, err := s.db.Exec(ctx, query, args...)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = checkDiscount(ctx, tx, req.OrderId, req.Discount)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "orders", "order_id", req.OrderId, req.Version, result.RowsAffected())
+}
+
+func (s OrderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	var p = newPatch()
+	if req.UserId != nil {
+		p.add("user_id", *req.UserId)
+	}
+	if req.Discount != nil {
+		p.add("discount", *req.Discount)
+	}
+
+	params := map[string]interface{}{"order_id": req.OrderId}
+	query, args := p.query("orders", whereVersion("order_id = :order_id AND is_deleted = FALSE", params, req.Version), params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if req.Discount != nil {
+		err = checkDiscount(ctx, tx, req.OrderId, *req.Discount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
 	if err != nil {
 		return 0, err
 	}
 
-	return result.RowsAffected(), nil
+	return checkVersion(ctx, s.db, "orders", "order_id", req.OrderId, req.Version, result.RowsAffected())
+}
+
+// checkDiscount locks the order orderId for the rest of tx and returns
+// ErrOrderNotPending when discount would change what it costs after it has
+// left pending. A missing order is left for the update to find
+func checkDiscount(ctx context.Context, tx pgx.Tx, orderId string, discount int64) error {
+	var (
+		status  string
+		current int64
+	)
+	err := tx.QueryRow(ctx, `SELECT status, discount FROM orders WHERE order_id = $1 AND is_deleted = FALSE FOR UPDATE`, orderId).Scan(&status, &current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.OrderStatusPending && discount != current {
+		return fmt.Errorf("%w: %s", storage.ErrOrderNotPending, orderId)
+	}
+	return nil
 }
 
 func (s OrderRepo) GetById(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
 	var (
-		userId  sql.NullString
-		orderId sql.NullString
+		userId   sql.NullString
+		status   sql.NullString
+		version  int
+		orderId  sql.NullString
+		subtotal int64
+		order    models.Order
 	)
 
-	query := `SELECT order_id, user_id FROM orders WHERE order_id = $1 AND is_deleted = 'False'`
+	query := `SELECT order_id, user_id, status, ` + orderTotals + `, version FROM orders WHERE order_id = $1 AND is_deleted = 'False'`
 
 	err := s.db.QueryRow(ctx, query, req.OrderId).Scan(
 		&orderId,
 		&userId,
+		&status,
+		&order.Discount,
+		&order.TaxRate,
+		&subtotal,
+		&order.Currency,
+		&version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Order{
-		OrderId: orderId.String,
-		UserId:  userId.String,
-	}, nil
+	order.OrderId, order.UserId, order.Status, order.Version = orderId.String, userId.String, status.String, version
+	order.SetTotals(subtotal)
+	return &order, nil
 }
 
 func (s OrderRepo) GetList(ctx context.Context, req *models.OrderGetListRequest) (*models.OrderGetListResponse, error) {
 	var (
-		resp   = &models.OrderGetListResponse{}
-		where  = " WHERE is_deleted = False "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		//order  = " ORDER BY created_at DESC "
+		resp  = &models.OrderGetListResponse{}
+		where = " WHERE is_deleted = False "
+		keys  []models.Cursor
 	)
-	query := `SELECT COUNT(*) OVER(), order_id, user_id FROM orders`
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	query := `SELECT order_id, user_id, status, ` + orderTotals + `, version, created_at FROM orders`
+	var args []interface{}
+	if req.UserId != "" {
+		where += " AND user_id = $1 "
+		args = append(args, req.UserId)
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	where, args, err := listWhere(where, req.Filters, orderColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "orders", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, orderColumns, "order_id")
+	if err != nil {
+		return nil, err
 	}
 
-	//if req.Search != "" {
-	//	where += ` AND title ILIKE '%' || '` + req.Search + `' || '%'`
-	//}
-
-	//query += where + order + offset + limit
-	query += where + offset + limit
+	query += where + page
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	for rows.Next() {
 		var (
-			userId  sql.NullString
-			orderId sql.NullString
-			count   int
+			userId    sql.NullString
+			status    sql.NullString
+			version   int
+			createdAt sql.NullTime
+			orderId   sql.NullString
+			subtotal  int64
+			order     models.Order
 		)
 		err := rows.Scan(
-			&count,
 			&orderId,
 			&userId,
+			&status,
+			&order.Discount,
+			&order.TaxRate,
+			&subtotal,
+			&order.Currency,
+			&version,
+			&createdAt,
 		)
 		if err != nil {
 			return nil, err
 		}
-		resp.Orders = append(
-			resp.Orders,
-			&models.Order{
-				OrderId: orderId.String,
-				UserId:  userId.String,
-			})
-		resp.Count = count
+		order.OrderId, order.UserId, order.Status, order.Version = orderId.String, userId.String, status.String, version
+		order.SetTotals(subtotal)
+		resp.Orders = append(resp.Orders, &order)
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: orderId.String})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Orders = resp.Orders[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Orders)
 	}
+	resp.NextCursor, resp.PrevCursor = next, prev
 	return resp, nil
 }
 
 func (s OrderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
-	_, err := s.db.Exec(ctx, "UPDATE orders SET is_deleted = true WHERE order_id = $1", req.OrderId)
-	return err
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockOrder(ctx, tx, req.OrderId, req.Version)
+	if err != nil || status == "" {
+		return err
+	}
+	// an order still under way is cancelled first, releasing its stock
+	if !models.IsTerminal(status) {
+		err = transition(ctx, tx, &models.OrderTransition{OrderId: req.OrderId, Status: models.OrderStatusCancelled, Note: "deleted", UserId: req.UserId}, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE orders SET is_deleted = true, updated_at = now(), version = version + 1 WHERE order_id = $1`, req.OrderId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func NewOrderRepo(db *pgxpool.Pool) *OrderRepo {
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"app/api/models"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type PasswordResetRepo struct {
+	db *pgxpool.Pool
+}
+
+// Create stores a new reset code, superseding any code still pending for the user
+func (s PasswordResetRepo) Create(ctx context.Context, req *models.CreatePasswordReset) (string, error) {
+	var (
+		id  = uuid.New().String()
+		now = time.Now().UTC()
+	)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, now, req.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO password_resets(id, user_id, code_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(ctx, query, id, req.UserId, req.CodeHash, req.ExpiresAt.UTC(), now)
+	if err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+func (s PasswordResetRepo) GetPending(ctx context.Context, req *models.PasswordResetPrimaryKey) (*models.PasswordReset, error) {
+	var (
+		id        sql.NullString
+		userId    sql.NullString
+		codeHash  sql.NullString
+		attempts  int
+		expiresAt time.Time
+		createdAt time.Time
+		key       = req.Id
+	)
+
+	query := `SELECT id, user_id, code_hash, attempts, expires_at, created_at FROM password_resets WHERE id = $1 AND used_at IS NULL AND expires_at > $2`
+	if req.Id == "" {
+		query = `SELECT id, user_id, code_hash, attempts, expires_at, created_at FROM password_resets WHERE user_id = $1 AND used_at IS NULL AND expires_at > $2 ORDER BY created_at DESC LIMIT 1`
+		key = req.UserId
+	}
+	err := s.db.QueryRow(ctx, query, key, time.Now().UTC()).Scan(
+		&id,
+		&userId,
+		&codeHash,
+		&attempts,
+		&expiresAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PasswordReset{
+		Id:        id.String,
+		UserId:    userId.String,
+		CodeHash:  codeHash.String,
+		Attempts:  attempts,
+		ExpiresAt: expiresAt,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (s PasswordResetRepo) CountSince(ctx context.Context, req *models.PasswordResetCountRequest) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM password_resets WHERE user_id = $1 AND created_at >= $2`
+	err := s.db.QueryRow(ctx, query, req.UserId, req.Since.UTC()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AddAttempt reserves a guess before the code is compared, returning the
+// attempts made so far; no rows means the code is used, expired or out of guesses
+func (s PasswordResetRepo) AddAttempt(ctx context.Context, req *models.PasswordResetAttempt) (int, error) {
+	var attempts int
+
+	query := `UPDATE password_resets SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2 AND used_at IS NULL AND expires_at > $3 RETURNING attempts`
+	err := s.db.QueryRow(ctx, query, req.Id, req.MaxAttempts, time.Now().UTC()).Scan(&attempts)
+	if err != nil {
+		return 0, err
+	}
+	return attempts, nil
+}
+
+// Consume marks the code used; zero rows affected means it was already used
+func (s PasswordResetRepo) Consume(ctx context.Context, req *models.PasswordResetAttempt) (int64, error) {
+	query := `UPDATE password_resets SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND attempts <= $3`
+	result, err := s.db.Exec(ctx, query, time.Now().UTC(), req.Id, req.MaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func NewPasswordResetRepo(db *pgxpool.Pool) *PasswordResetRepo {
+	return &PasswordResetRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// patch collects the SET clauses of a partial update so that only the
+// columns present in the request are written
+type patch struct {
+	set    []string
+	params map[string]interface{}
+}
+
+func newPatch() *patch {
+	return &patch{params: map[string]interface{}{}}
+}
+
+// add sets column to value
+func (p *patch) add(column string, value interface{}) {
+	p.set = append(p.set, column+" = :"+column)
+	p.params[column] = value
+}
+
+// expr adds a raw SET clause which may refer to params added with add
+func (p *patch) expr(clause string) {
+	p.set = append(p.set, clause)
+}
+
+// query returns the UPDATE statement for table, always bumping updated_at and
+// version; where is a named condition over params such as "id = :id"
+func (p *patch) query(table, where string, params map[string]interface{}) (string, []interface{}) {
+	for k, v := range params {
+		p.params[k] = v
+	}
+	set := append(p.set, "updated_at = now()", "version = version + 1")
+	query := `UPDATE ` + table + ` SET ` + strings.Join(set, ", ") + ` WHERE ` + where
+	return helper.ReplaceQueryParams(query, p.params)
+}
+
+// whereVersion narrows the named condition where to rows at version, if set
+func whereVersion(where string, params map[string]interface{}, version int) string {
+	if version > 0 {
+		params["version"] = version
+		where += " AND version = :version"
+	}
+	return where
+}
+
+// checkVersion tells a version conflict apart from a missing row once a
+// versioned write matched nothing
+func checkVersion(ctx context.Context, db *pgxpool.Pool, table, idColumn, id string, version int, affected int64) (int64, error) {
+	if affected > 0 || version == 0 {
+		return affected, nil
+	}
+
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM ` + table + ` WHERE ` + idColumn + ` = $1 AND is_deleted = FALSE)`
+	err := db.QueryRow(ctx, query, id).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, storage.ErrVersionConflict
+	}
+	return 0, nil
+}
@@ -9,12 +9,21 @@ import (
 )
 
 type store struct {
-	db        *pgxpool.Pool
-	user      *UserRepo
-	category  *CategoryRepo
-	book      *BookRepo
-	order     *OrderRepo
-	orderItem *OrderItemRepo
+	db                *pgxpool.Pool
+	user              *UserRepo
+	category          *CategoryRepo
+	author            *AuthorRepo
+	publisher         *PublisherRepo
+	inventory         *InventoryRepo
+	book              *BookRepo
+	order             *OrderRepo
+	orderItem         *OrderItemRepo
+	cart              *CartRepo
+	refreshToken      *RefreshTokenRepo
+	revokedToken      *RevokedTokenRepo
+	passwordReset     *PasswordResetRepo
+	emailVerification *EmailVerificationRepo
+	loginAttempt      *LoginAttemptRepo
 }
 
 func (s *store) Users() storage.UserRepoInterface {
@@ -31,6 +40,27 @@ func (s *store) Category() storage.CategoryRepoInterface {
 	return s.category
 }
 
+func (s *store) Author() storage.AuthorRepoInterface {
+	if s.author == nil {
+		s.author = NewAuthorRepo(s.db)
+	}
+	return s.author
+}
+
+func (s *store) Publisher() storage.PublisherRepoInterface {
+	if s.publisher == nil {
+		s.publisher = NewPublisherRepo(s.db)
+	}
+	return s.publisher
+}
+
+func (s *store) Inventory() storage.InventoryRepoInterface {
+	if s.inventory == nil {
+		s.inventory = NewInventoryRepo(s.db)
+	}
+	return s.inventory
+}
+
 func (s *store) Books() storage.BookRepoInterface {
 	if s.book == nil {
 		s.book = NewBookRepo(s.db)
@@ -52,6 +82,48 @@ func (s *store) OrderItem() storage.OrderItemRepoInterface {
 	return s.orderItem
 }
 
+func (s *store) Cart() storage.CartRepoInterface {
+	if s.cart == nil {
+		s.cart = NewCartRepo(s.db)
+	}
+	return s.cart
+}
+
+func (s *store) RefreshToken() storage.RefreshTokenRepoInterface {
+	if s.refreshToken == nil {
+		s.refreshToken = NewRefreshTokenRepo(s.db)
+	}
+	return s.refreshToken
+}
+
+func (s *store) RevokedToken() storage.RevokedTokenRepoInterface {
+	if s.revokedToken == nil {
+		s.revokedToken = NewRevokedTokenRepo(s.db)
+	}
+	return s.revokedToken
+}
+
+func (s *store) PasswordReset() storage.PasswordResetRepoInterface {
+	if s.passwordReset == nil {
+		s.passwordReset = NewPasswordResetRepo(s.db)
+	}
+	return s.passwordReset
+}
+
+func (s *store) EmailVerification() storage.EmailVerificationRepoInterface {
+	if s.emailVerification == nil {
+		s.emailVerification = NewEmailVerificationRepo(s.db)
+	}
+	return s.emailVerification
+}
+
+func (s *store) LoginAttempt() storage.LoginAttemptRepoInterface {
+	if s.loginAttempt == nil {
+		s.loginAttempt = NewLoginAttemptRepo(s.db)
+	}
+	return s.loginAttempt
+}
+
 func NewConnectionPostgres(cfg *config.Config) (storage.StorageInterface, error) {
 
 	connect, err := pgxpool.ParseConfig(fmt.Sprintf(
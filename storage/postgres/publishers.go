@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"slices"
+)
+
+// publisherColumns maps the fields a list may be filtered and sorted by to columns
+var publisherColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type PublisherRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s PublisherRepo) Create(ctx context.Context, req *models.CreatePublisher) (string, error) {
+	var id = uuid.New().String()
+	query := `INSERT INTO publishers(id, name, website) VALUES ($1, $2, $3)`
+
+	_, err := s.db.Exec(ctx, query, id, req.Name, req.Website)
+
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s PublisherRepo) Update(ctx context.Context, req *models.UpdatePublisher) (int64, error) {
+	var params map[string]interface{}
+	query := `
+		UPDATE publishers 
+		SET name = :name,
+		    website = :website,
+		    updated_at = now(),
+		    version = version + 1
+		WHERE id = :id`
+
+	params = map[string]interface{}{
+		"id":      req.Id,
+		"name":    req.Name,
+		"website": req.Website,
+	}
+
+	query = whereVersion(query, params, req.Version)
+	query, args := helper.ReplaceQueryParams(query, params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	err = refreshBookNames(ctx, tx, "publisher_id = $1", req.Id)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "publishers", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s PublisherRepo) Patch(ctx context.Context, req *models.PatchPublisher) (int64, error) {
+	var p = newPatch()
+	if req.Name != nil {
+		p.add("name", *req.Name)
+	}
+	if req.Website != nil {
+		p.add("website", *req.Website)
+	}
+
+	params := map[string]interface{}{"id": req.Id}
+	query, args := p.query("publishers", whereVersion("id = :id AND is_deleted = FALSE", params, req.Version), params)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if req.Name != nil {
+		err = refreshBookNames(ctx, tx, "publisher_id = $1", req.Id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "publishers", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s PublisherRepo) GetById(ctx context.Context, req *models.PublisherPrimaryKey) (*models.Publisher, error) {
+	var (
+		id      sql.NullString
+		name    sql.NullString
+		website sql.NullString
+		version int
+	)
+
+	query := `SELECT id, name, website, version FROM publishers WHERE id = $1 AND is_deleted = FALSE`
+
+	err := s.db.QueryRow(ctx, query, req.Id).Scan(
+		&id,
+		&name,
+		&website,
+		&version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Publisher{
+		Id:      id.String,
+		Name:    name.String,
+		Website: website.String,
+		Version: version,
+	}, nil
+}
+
+func (s PublisherRepo) GetList(ctx context.Context, req *models.PublisherGetListRequest) (*models.PublisherGetListResponse, error) {
+	var (
+		resp  = &models.PublisherGetListResponse{}
+		where = " WHERE is_deleted = FALSE "
+		keys  []models.Cursor
+	)
+	query := `SELECT id, name, website, version, created_at FROM publishers`
+	var args []interface{}
+	where, args, err := listWhere(where, req.Filters, publisherColumns, args)
+	if err != nil {
+		return nil, err
+	}
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "publishers", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, publisherColumns, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	query += where + page
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			name      sql.NullString
+			website   sql.NullString
+			version   int
+			createdAt sql.NullTime
+		)
+		err := rows.Scan(
+			&id,
+			&name,
+			&website,
+			&version,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		resp.Publishers = append(
+			resp.Publishers,
+			&models.Publisher{
+				Id:      id.String,
+				Name:    name.String,
+				Website: website.String,
+				Version: version,
+			})
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: id.String})
+	}
+
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Publishers = resp.Publishers[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Publishers)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
+	return resp, nil
+}
+
+func (s PublisherRepo) Delete(ctx context.Context, req *models.PublisherPrimaryKey) error {
+	params := map[string]interface{}{"id": req.Id}
+	query, args := helper.ReplaceQueryParams(`UPDATE publishers SET is_deleted = true, updated_at = now(), version = version + 1 WHERE `+whereVersion("id = :id", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkVersion(ctx, s.db, "publishers", "id", req.Id, req.Version, result.RowsAffected())
+	return err
+}
+
+func NewPublisherRepo(db *pgxpool.Pool) *PublisherRepo {
+	return &PublisherRepo{
+		db: db,
+	}
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"app/api/models"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RefreshTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s RefreshTokenRepo) Create(ctx context.Context, req *models.CreateRefreshToken) (string, error) {
+	var id = uuid.New().String()
+	query := `INSERT INTO refresh_tokens(id, user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.Exec(ctx, query, id, req.UserId, req.FamilyId, req.TokenHash, req.ExpiresAt.UTC())
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s RefreshTokenRepo) GetById(ctx context.Context, req *models.RefreshTokenPrimaryKey) (*models.RefreshToken, error) {
+	var (
+		id         sql.NullString
+		userId     sql.NullString
+		familyId   sql.NullString
+		tokenHash  sql.NullString
+		expiresAt  time.Time
+		revokedAt  sql.NullTime
+		replacedBy sql.NullString
+		key        = req.Id
+	)
+
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by FROM refresh_tokens WHERE id = $1`
+	if req.Id == "" {
+		query = `SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by FROM refresh_tokens WHERE token_hash = $1`
+		key = req.TokenHash
+	}
+	err := s.db.QueryRow(ctx, query, key).Scan(
+		&id,
+		&userId,
+		&familyId,
+		&tokenHash,
+		&expiresAt,
+		&revokedAt,
+		&replacedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.RefreshToken{
+		Id:         id.String,
+		UserId:     userId.String,
+		FamilyId:   familyId.String,
+		TokenHash:  tokenHash.String,
+		ExpiresAt:  expiresAt,
+		ReplacedBy: replacedBy.String,
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+func (s RefreshTokenRepo) Revoke(ctx context.Context, req *models.RevokeRefreshToken) (int64, error) {
+	var (
+		query = `UPDATE refresh_tokens SET revoked_at = $1, replaced_by = $2 WHERE id = $3 AND revoked_at IS NULL`
+		key   = req.Id
+	)
+	switch {
+	case req.Id != "":
+	case req.FamilyId != "":
+		query = `UPDATE refresh_tokens SET revoked_at = $1, replaced_by = $2 WHERE family_id = $3 AND revoked_at IS NULL`
+		key = req.FamilyId
+	default:
+		query = `UPDATE refresh_tokens SET revoked_at = $1, replaced_by = $2 WHERE user_id = $3 AND revoked_at IS NULL`
+		key = req.UserId
+	}
+
+	var replacedBy interface{}
+	if req.ReplacedBy != "" {
+		replacedBy = req.ReplacedBy
+	}
+
+	result, err := s.db.Exec(ctx, query, time.Now().UTC(), replacedBy, key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func NewRefreshTokenRepo(db *pgxpool.Pool) *RefreshTokenRepo {
+	return &RefreshTokenRepo{
+		db: db,
+	}
+}
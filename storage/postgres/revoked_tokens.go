@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RevokedTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func (s RevokedTokenRepo) Create(ctx context.Context, req *models.CreateRevokedToken) error {
+	var (
+		id        = uuid.New().String()
+		expiresAt interface{}
+	)
+	if !req.ExpiresAt.IsZero() {
+		expiresAt = req.ExpiresAt.UTC()
+	}
+
+	query := `INSERT INTO revoked_tokens(id, jti, user_id, expires_at, revoked_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (jti) DO NOTHING`
+
+	_, err := s.db.Exec(ctx, query, id, helper.NewNullString(req.Jti), req.UserId, expiresAt, time.Now().UTC())
+	return err
+}
+
+func (s RevokedTokenRepo) IsRevoked(ctx context.Context, req *models.RevokedTokenPrimaryKey) (bool, error) {
+	var revoked bool
+
+	query := `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS(SELECT 1 FROM revoked_tokens WHERE user_id = $2 AND jti IS NULL AND revoked_at > $3)`
+
+	err := s.db.QueryRow(ctx, query, helper.NewNullString(req.Jti), req.UserId, req.IssuedAt.UTC()).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+func (s RevokedTokenRepo) Purge(ctx context.Context, req *models.PurgeRevokedTokens) (int64, error) {
+	result, err := s.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, req.Before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func NewRevokedTokenRepo(db *pgxpool.Pool) *RevokedTokenRepo {
+	return &RevokedTokenRepo{
+		db: db,
+	}
+}
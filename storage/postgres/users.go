@@ -5,21 +5,38 @@ import (
 	"app/pkg/helper"
 	"context"
 	"database/sql"
-	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/spf13/cast"
+	"slices"
 )
 
+// userColumns maps the fields a list may be filtered and sorted by to columns
+var userColumns = map[string]string{
+	"id":          "id",
+	"first_name":  "first_name",
+	"last_name":   "last_name",
+	"age":         "age",
+	"username":    "username",
+	"email":       "email",
+	"role":        "role",
+	"is_verified": "is_verified",
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+}
+
 type UserRepo struct {
 	db *pgxpool.Pool
 }
 
 func (s UserRepo) Create(ctx context.Context, req *models.CreateUser) (string, error) {
 	var id = cast.ToString(uuid.New())
-	query := `INSERT INTO users(id, first_name, last_name, age, phone, picture, username, password, card_no) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	if req.Role == "" {
+		req.Role = models.RoleCustomer
+	}
+	query := `INSERT INTO users(id, first_name, last_name, age, phone, picture, username, email, password, card_no, role) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	_, err := s.db.Exec(ctx, query, id, req.FirstName, req.LastName, req.Age, req.Phone, req.Picture, req.Username, req.Password, req.CardNo)
+	_, err := s.db.Exec(ctx, query, id, req.FirstName, req.LastName, req.Age, req.Phone, req.Picture, req.Username, helper.NewNullString(req.Email), req.Password, req.CardNo, req.Role)
 	if err != nil {
 		return "", err
 	}
@@ -36,9 +53,12 @@ func (s UserRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, er
 		    phone = :phone, 
 		    picture = :picture,
 		    username = :username,
-		    password = :password,
+		    email = :email,
+		    is_verified = CASE WHEN email IS DISTINCT FROM :email THEN FALSE ELSE is_verified END,
 		    card_no = :card_no,
-		    updated_at = now()
+		    role = :role,
+		    updated_at = now(),
+		    version = version + 1
 		WHERE id = :id`
 
 	params = map[string]interface{}{
@@ -49,10 +69,12 @@ func (s UserRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, er
 		"phone":      req.Phone,
 		"picture":    req.Picture,
 		"username":   req.Username,
-		"password":   req.Password,
+		"email":      helper.NewNullString(req.Email),
 		"card_no":    req.CardNo,
+		"role":       req.Role,
 	}
 
+	query = whereVersion(query, params, req.Version)
 	query, args := helper.ReplaceQueryParams(query, params)
 
 	result, err := s.db.Exec(ctx, query, args...)
@@ -60,6 +82,66 @@ func (s UserRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, er
 		return 0, err
 	}
 
+	return checkVersion(ctx, s.db, "users", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s UserRepo) Patch(ctx context.Context, req *models.PatchUser) (int64, error) {
+	var p = newPatch()
+	if req.FirstName != nil {
+		p.add("first_name", *req.FirstName)
+	}
+	if req.LastName != nil {
+		p.add("last_name", *req.LastName)
+	}
+	if req.Age != nil {
+		p.add("age", *req.Age)
+	}
+	if req.Phone != nil {
+		p.add("phone", *req.Phone)
+	}
+	if req.Picture != nil {
+		p.add("picture", *req.Picture)
+	}
+	if req.Username != nil {
+		p.add("username", *req.Username)
+	}
+	if req.Email != nil {
+		p.expr("is_verified = CASE WHEN email IS DISTINCT FROM :email THEN FALSE ELSE is_verified END")
+		p.add("email", helper.NewNullString(*req.Email))
+	}
+	if req.CardNo != nil {
+		p.add("card_no", *req.CardNo)
+	}
+	if req.Role != nil {
+		p.add("role", *req.Role)
+	}
+
+	params := map[string]interface{}{"id": req.Id}
+	query, args := p.query("users", whereVersion("id = :id AND is_deleted = FALSE", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkVersion(ctx, s.db, "users", "id", req.Id, req.Version, result.RowsAffected())
+}
+
+func (s UserRepo) UpdatePassword(ctx context.Context, req *models.UpdatePassword) (int64, error) {
+	result, err := s.db.Exec(ctx, "UPDATE users SET password = $1, updated_at = now(), version = version + 1 WHERE id = $2", req.Password, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (s UserRepo) Verify(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	result, err := s.db.Exec(ctx, "UPDATE users SET is_verified = TRUE, updated_at = now(), version = version + 1 WHERE id = $1 AND is_deleted = FALSE", req.Id)
+	if err != nil {
+		return 0, err
+	}
+
 	return result.RowsAffected(), nil
 }
 
@@ -72,16 +154,26 @@ func (s UserRepo) GetById(ctx context.Context, req *models.UserPrimaryKey) (*mod
 		phone     sql.NullString
 		picture   sql.NullString
 		username  sql.NullString
+		email     sql.NullString
 		password  sql.NullString
 		cardNo    sql.NullString
+		role      sql.NullString
+		verified  bool
+		version   int
+		key       = req.Id
 	)
 
-	query := `SELECT id, first_name, last_name, age, phone, picture, username, password, card_no FROM users WHERE id = $1 AND is_deleted = FALSE`
-	if req.Id == "" {
-		query = `SELECT id, first_name, last_name, age, phone, picture, username, password, card_no FROM users WHERE username = $1 AND is_deleted = FALSE`
-		req.Id = req.Username
+	query := `SELECT id, first_name, last_name, age, phone, picture, username, email, password, card_no, role, is_verified, version FROM users WHERE id = $1 AND is_deleted = FALSE`
+	switch {
+	case req.Id != "":
+	case req.Username != "":
+		query = `SELECT id, first_name, last_name, age, phone, picture, username, email, password, card_no, role, is_verified, version FROM users WHERE username = $1 AND is_deleted = FALSE`
+		key = req.Username
+	default:
+		query = `SELECT id, first_name, last_name, age, phone, picture, username, email, password, card_no, role, is_verified, version FROM users WHERE LOWER(email) = LOWER($1) AND is_deleted = FALSE`
+		key = req.Email
 	}
-	err := s.db.QueryRow(ctx, query, req.Id).Scan(
+	err := s.db.QueryRow(ctx, query, key).Scan(
 		&id,
 		&firstName,
 		&lastName,
@@ -89,8 +181,12 @@ func (s UserRepo) GetById(ctx context.Context, req *models.UserPrimaryKey) (*mod
 		&phone,
 		&picture,
 		&username,
+		&email,
 		&password,
 		&cardNo,
+		&role,
+		&verified,
+		&version,
 	)
 
 	if err != nil {
@@ -98,50 +194,54 @@ func (s UserRepo) GetById(ctx context.Context, req *models.UserPrimaryKey) (*mod
 	}
 
 	return &models.User{
-		Id:        id.String,
-		FirstName: firstName.String,
-		LastName:  lastName.String,
-		Age:       age,
-		Phone:     phone.String,
-		Picture:   picture.String,
-		Username:  username.String,
-		Password:  password.String,
-		CardNo:    cardNo.String,
+		Id:         id.String,
+		FirstName:  firstName.String,
+		LastName:   lastName.String,
+		Age:        age,
+		Phone:      phone.String,
+		Picture:    picture.String,
+		Username:   username.String,
+		Email:      email.String,
+		Password:   password.String,
+		CardNo:     cardNo.String,
+		Role:       role.String,
+		IsVerified: verified,
+		Version:    version,
 	}, nil
 }
 
 func (s UserRepo) GetList(ctx context.Context, req *models.UserGetListRequest) (*models.UserGetListResponse, error) {
 	var (
-		resp   = &models.UserGetListResponse{}
-		where  = " WHERE is_deleted = FALSE "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		//order  = " ORDER BY created_at DESC "
+		resp  = &models.UserGetListResponse{}
+		where = " WHERE is_deleted = FALSE "
+		keys  []models.Cursor
 	)
-	query := `SELECT COUNT(*) OVER(), id, first_name, last_name, age, phone, picture, username, password, card_no FROM users`
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	query := `SELECT id, first_name, last_name, age, phone, picture, username, email, password, card_no, role, is_verified, version, created_at FROM users`
+	var args []interface{}
+	where, args, err := listWhere(where, req.Filters, userColumns, args)
+	if err != nil {
+		return nil, err
 	}
-
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if req.Count {
+		resp.Count, err = listCount(ctx, s.db, "users", where, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	where, page, args, err := listPage(where, args, req.Offset, req.Limit, req.Cursor, req.Sorts, userColumns, "id")
+	if err != nil {
+		return nil, err
 	}
 
-	//if req.Search != "" {
-	//	where += ` AND title ILIKE '%' || '` + req.Search + `' || '%'`
-	//}
-
-	//query += where + order + offset + limit
-	query += where + offset + limit
+	query += where + page
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	for rows.Next() {
 		var (
-			count     int
 			id        sql.NullString
 			firstName sql.NullString
 			lastName  sql.NullString
@@ -149,11 +249,15 @@ func (s UserRepo) GetList(ctx context.Context, req *models.UserGetListRequest) (
 			phone     sql.NullString
 			picture   sql.NullString
 			username  sql.NullString
+			email     sql.NullString
 			password  sql.NullString
 			cardNo    sql.NullString
+			role      sql.NullString
+			verified  bool
+			version   int
+			createdAt sql.NullTime
 		)
 		err := rows.Scan(
-			&count,
 			&id,
 			&firstName,
 			&lastName,
@@ -161,8 +265,13 @@ func (s UserRepo) GetList(ctx context.Context, req *models.UserGetListRequest) (
 			&phone,
 			&picture,
 			&username,
+			&email,
 			&password,
 			&cardNo,
+			&role,
+			&verified,
+			&version,
+			&createdAt,
 		)
 		if err != nil {
 			return nil, err
@@ -170,24 +279,42 @@ func (s UserRepo) GetList(ctx context.Context, req *models.UserGetListRequest) (
 		resp.Users = append(
 			resp.Users,
 			&models.User{
-				Id:        id.String,
-				FirstName: firstName.String,
-				LastName:  lastName.String,
-				Age:       age,
-				Phone:     phone.String,
-				Picture:   picture.String,
-				Username:  username.String,
-				Password:  password.String,
-				CardNo:    cardNo.String,
+				Id:         id.String,
+				FirstName:  firstName.String,
+				LastName:   lastName.String,
+				Age:        age,
+				Phone:      phone.String,
+				Picture:    picture.String,
+				Username:   username.String,
+				Email:      email.String,
+				Password:   password.String,
+				CardNo:     cardNo.String,
+				Role:       role.String,
+				IsVerified: verified,
+				Version:    version,
 			})
-		resp.Count = count
+		keys = append(keys, models.Cursor{CreatedAt: createdAt.Time, Id: id.String})
 	}
 
+	n, next, prev := listCursors(keys, req.Offset, req.Limit, req.Cursor, len(req.Sorts) > 0)
+	resp.Users = resp.Users[:n]
+	if req.Cursor != nil && req.Cursor.Before {
+		slices.Reverse(resp.Users)
+	}
+	resp.NextCursor, resp.PrevCursor = next, prev
 	return resp, nil
 }
 
 func (s UserRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) error {
-	_, err := s.db.Exec(ctx, "UPDATE users SET is_deleted = true, updated_at = now() WHERE id = $1", req.Id)
+	params := map[string]interface{}{"id": req.Id}
+	query, args := helper.ReplaceQueryParams(`UPDATE users SET is_deleted = true, updated_at = now(), version = version + 1 WHERE `+whereVersion("id = :id", params, req.Version), params)
+
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkVersion(ctx, s.db, "users", "id", req.Id, req.Version, result.RowsAffected())
 	return err
 }
 
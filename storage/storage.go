@@ -3,20 +3,63 @@ package storage
 import (
 	"app/api/models"
 	"context"
+	"errors"
 )
 
+// ErrVersionConflict is returned by Update, Patch and Delete when the request
+// carries a Version and the row exists at a different one. A zero Version
+// skips the check
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrCategoryCycle is returned when a category would become its own ancestor
+var ErrCategoryCycle = errors.New("category cannot be moved under itself")
+
+// ErrInsufficientStock is returned when a stock change would leave a book
+// with less stock than is reserved, or with a negative quantity
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrBookNotFound is returned when an order refers to a missing book
+var ErrBookNotFound = errors.New("book does not exist")
+
+// ErrCurrencyMismatch is returned when an order mixes books priced in
+// different currencies
+var ErrCurrencyMismatch = errors.New("books are priced in different currencies")
+
+// ErrOrderNotPending is returned when the items of an order that is no longer
+// pending would change
+var ErrOrderNotPending = errors.New("order is not pending")
+
+// ErrInvalidTransition is returned when an order cannot move from its current
+// status to the requested one
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
 type StorageInterface interface {
 	Close()
 	Users() UserRepoInterface
 	Category() CategoryRepoInterface
+	Author() AuthorRepoInterface
+	Publisher() PublisherRepoInterface
+	Inventory() InventoryRepoInterface
 	Books() BookRepoInterface
 	Order() OrderRepoInterface
 	OrderItem() OrderItemRepoInterface
+	Cart() CartRepoInterface
+	RefreshToken() RefreshTokenRepoInterface
+	RevokedToken() RevokedTokenRepoInterface
+	PasswordReset() PasswordResetRepoInterface
+	EmailVerification() EmailVerificationRepoInterface
+	LoginAttempt() LoginAttemptRepoInterface
 }
 
 type BookRepoInterface interface {
 	Create(ctx context.Context, req *models.CreateBook) (string, error)
 	Update(ctx context.Context, req *models.UpdateBook) (int64, error)
+	Patch(ctx context.Context, req *models.PatchBook) (int64, error)
+	AddCategories(ctx context.Context, req *models.BookCategories) (int64, error)
+	RemoveCategory(ctx context.Context, req *models.BookCategoryPrimaryKey) (int64, error)
+	AddTags(ctx context.Context, req *models.BookTags) (int64, error)
+	RemoveTag(ctx context.Context, req *models.BookTagPrimaryKey) (int64, error)
+	Search(ctx context.Context, req *models.BookSearchRequest) (*models.BookSearchResponse, error)
 	GetById(ctx context.Context, req *models.BookPrimaryKey) (*models.Book, error)
 	GetList(ctx context.Context, req *models.BookGetListRequest) (*models.BookGetListResponse, error)
 	Delete(ctx context.Context, req *models.BookPrimaryKey) error
@@ -25,6 +68,9 @@ type BookRepoInterface interface {
 type UserRepoInterface interface {
 	Create(ctx context.Context, req *models.CreateUser) (string, error)
 	Update(ctx context.Context, req *models.UpdateUser) (int64, error)
+	Patch(ctx context.Context, req *models.PatchUser) (int64, error)
+	UpdatePassword(ctx context.Context, req *models.UpdatePassword) (int64, error)
+	Verify(ctx context.Context, req *models.UserPrimaryKey) (int64, error)
 	GetById(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error)
 	GetList(ctx context.Context, req *models.UserGetListRequest) (*models.UserGetListResponse, error)
 	Delete(ctx context.Context, req *models.UserPrimaryKey) error
@@ -33,23 +79,130 @@ type UserRepoInterface interface {
 type CategoryRepoInterface interface {
 	Create(ctx context.Context, req *models.CreateCategory) (string, error)
 	Update(ctx context.Context, req *models.UpdateCategory) (int64, error)
+	Patch(ctx context.Context, req *models.PatchCategory) (int64, error)
 	GetById(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error)
 	GetList(ctx context.Context, req *models.CategoryGetListRequest) (*models.CategoryGetListResponse, error)
+	GetTree(ctx context.Context, req *models.CategoryTreeRequest) ([]*models.Category, error)
+	GetAncestors(ctx context.Context, req *models.CategoryPrimaryKey) ([]*models.Category, error)
 	Delete(ctx context.Context, req *models.CategoryPrimaryKey) error
 }
 
+type AuthorRepoInterface interface {
+	Create(ctx context.Context, req *models.CreateAuthor) (string, error)
+	Update(ctx context.Context, req *models.UpdateAuthor) (int64, error)
+	Patch(ctx context.Context, req *models.PatchAuthor) (int64, error)
+	GetById(ctx context.Context, req *models.AuthorPrimaryKey) (*models.Author, error)
+	GetList(ctx context.Context, req *models.AuthorGetListRequest) (*models.AuthorGetListResponse, error)
+	Delete(ctx context.Context, req *models.AuthorPrimaryKey) error
+}
+
+type PublisherRepoInterface interface {
+	Create(ctx context.Context, req *models.CreatePublisher) (string, error)
+	Update(ctx context.Context, req *models.UpdatePublisher) (int64, error)
+	Patch(ctx context.Context, req *models.PatchPublisher) (int64, error)
+	GetById(ctx context.Context, req *models.PublisherPrimaryKey) (*models.Publisher, error)
+	GetList(ctx context.Context, req *models.PublisherGetListRequest) (*models.PublisherGetListResponse, error)
+	Delete(ctx context.Context, req *models.PublisherPrimaryKey) error
+}
+
+type InventoryRepoInterface interface {
+	// Adjust changes the stock of a live book and records the movement; it
+	// returns a nil movement when the book does not exist
+	Adjust(ctx context.Context, req *models.AdjustInventory) (*models.InventoryMovement, error)
+	GetMovements(ctx context.Context, req *models.InventoryMovementGetListRequest) (*models.InventoryMovementGetListResponse, error)
+}
+
 type OrderRepoInterface interface {
 	Create(ctx context.Context, req *models.CreateOrder) (string, error)
+	// Checkout creates a pending order with its items and reserves their
+	// stock atomically. ErrBookNotFound, ErrCurrencyMismatch and
+	// ErrInsufficientStock come wrapped with the offending book id. The cart
+	// req.CartId is emptied in the same transaction, and ErrVersionConflict
+	// returned when it is no longer at req.CartVersion
+	Checkout(ctx context.Context, req *models.Checkout) (string, error)
+	// Transition moves an order to req.Status, records the change and
+	// reserves, sells or releases its stock to match. It returns 0 when the
+	// order does not exist
+	Transition(ctx context.Context, req *models.OrderTransition) (int64, error)
+	GetHistory(ctx context.Context, req *models.OrderPrimaryKey) ([]*models.OrderStatusChange, error)
+	// Update and Patch return ErrOrderNotPending for a discount change to an
+	// order that has left pending, whose totals are frozen
 	Update(ctx context.Context, req *models.UpdateOrder) (int64, error)
+	Patch(ctx context.Context, req *models.PatchOrder) (int64, error)
 	GetById(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error)
 	GetList(ctx context.Context, req *models.OrderGetListRequest) (*models.OrderGetListResponse, error)
+	// Delete removes an order. One that is not yet cancelled, delivered or
+	// refunded is cancelled first, in the same transaction, releasing its
+	// stock; ErrInvalidTransition is returned when it cannot be
 	Delete(ctx context.Context, req *models.OrderPrimaryKey) error
 }
 
+// OrderItemRepoInterface writes only items of pending orders and moves the
+// stock those orders hold to match in the same transaction. Writes fail with
+// ErrOrderNotPending otherwise, and with ErrInsufficientStock or a wrapped
+// ErrBookNotFound when the new stock cannot be reserved
 type OrderItemRepoInterface interface {
 	Create(ctx context.Context, req *models.CreateOrderItem) (string, error)
 	Update(ctx context.Context, req *models.UpdateOrderItem) (int64, error)
+	Patch(ctx context.Context, req *models.PatchOrderItem) (int64, error)
 	GetById(ctx context.Context, req *models.OrderItemPrimaryKey) (*models.OrderItem, error)
 	GetList(ctx context.Context, req *models.OrderItemGetListRequest) (*models.OrderItemGetListResponse, error)
 	Delete(ctx context.Context, req *models.OrderItemPrimaryKey) error
 }
+
+// CartRepoInterface writes return ErrVersionConflict when the request has a
+// Version and the cart is at another
+type CartRepoInterface interface {
+	// Create returns the id of a new visitor cart, or of the user's cart,
+	// which is only created the first time
+	Create(ctx context.Context, req *models.CreateCart) (string, error)
+	// GetById returns the cart with the book id and quantity of each item
+	GetById(ctx context.Context, req *models.CartPrimaryKey) (*models.Cart, error)
+	// AddItem adds to the quantity of a book already in the cart
+	AddItem(ctx context.Context, req *models.CartItemRequest) (int64, error)
+	// SetItem returns 0 when the book is not in the cart
+	SetItem(ctx context.Context, req *models.CartItemRequest) (int64, error)
+	RemoveItem(ctx context.Context, req *models.CartItemPrimaryKey) (int64, error)
+	// Clear empties the cart req.CartId, returning the number of items removed
+	Clear(ctx context.Context, req *models.CartPrimaryKey) (int64, error)
+	// Merge adds the items of a visitor cart to the user's cart, creating it
+	// when needed, and deletes the visitor cart. It returns 0 when there is no
+	// such visitor cart
+	Merge(ctx context.Context, req *models.MergeCart) (int64, error)
+}
+
+type RefreshTokenRepoInterface interface {
+	Create(ctx context.Context, req *models.CreateRefreshToken) (string, error)
+	GetById(ctx context.Context, req *models.RefreshTokenPrimaryKey) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, req *models.RevokeRefreshToken) (int64, error)
+}
+
+type RevokedTokenRepoInterface interface {
+	Create(ctx context.Context, req *models.CreateRevokedToken) error
+	// IsRevoked reports a token revoked by its jti, or by a user-wide
+	// revocation made after req.IssuedAt, which is precise to the millisecond
+	IsRevoked(ctx context.Context, req *models.RevokedTokenPrimaryKey) (bool, error)
+	Purge(ctx context.Context, req *models.PurgeRevokedTokens) (int64, error)
+}
+
+type PasswordResetRepoInterface interface {
+	Create(ctx context.Context, req *models.CreatePasswordReset) (string, error)
+	GetPending(ctx context.Context, req *models.PasswordResetPrimaryKey) (*models.PasswordReset, error)
+	CountSince(ctx context.Context, req *models.PasswordResetCountRequest) (int, error)
+	AddAttempt(ctx context.Context, req *models.PasswordResetAttempt) (int, error)
+	Consume(ctx context.Context, req *models.PasswordResetAttempt) (int64, error)
+}
+
+type EmailVerificationRepoInterface interface {
+	Create(ctx context.Context, req *models.CreateEmailVerification) (string, error)
+	GetPending(ctx context.Context, req *models.EmailVerificationPrimaryKey) (*models.EmailVerification, error)
+	CountSince(ctx context.Context, req *models.EmailVerificationCountRequest) (int, error)
+	Consume(ctx context.Context, req *models.EmailVerificationPrimaryKey) (int64, error)
+}
+
+type LoginAttemptRepoInterface interface {
+	Create(ctx context.Context, req *models.CreateLoginAttempt) (string, error)
+	Update(ctx context.Context, req *models.UpdateLoginAttempt) (int64, error)
+	GetFailures(ctx context.Context, req *models.LoginFailureRequest) (*models.LoginFailures, error)
+	GetList(ctx context.Context, req *models.LoginAttemptGetListRequest) (*models.LoginAttemptGetListResponse, error)
+}